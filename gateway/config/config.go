@@ -1,43 +1,696 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Default configuration values
 const (
-	DefaultPort           = 5000
-	DefaultRoutesPath     = "config/routes.yaml"
-	DefaultConnectTimeout = 1 // seconds - per HLD §9
-	DefaultPublicKeyPath  = "keys/public.pem"
-	DefaultRedisAddr      = "redis:6379"
-	DefaultRateLimit      = 100 // requests per minute
+	DefaultConfigPath        = "config/gateway.yaml"
+	DefaultPort              = 5000
+	DefaultRoutesPath        = "config/routes.yaml"
+	DefaultConnectTimeout    = 1 // seconds - per HLD §9
+	DefaultPublicKeyPath     = "keys/public.pem"
+	DefaultJWKSRefresh       = 5 * time.Minute
+	DefaultJWTAlgorithms     = "RS256"
+	DefaultJWTClockSkew      = 30 * time.Second
+	DefaultJWTRolesClaim     = "roles"
+	DefaultRedisAddr         = "redis:6379"
+	DefaultRateLimit         = 100 // requests per minute
+	DefaultTLSMinVersion     = "1.2"
+	DefaultTLSReloadInterval = 30 * time.Second
+	DefaultClientUserField   = "cn"
+	DefaultClientIDField     = "ou"
+	DefaultShutdownGrace     = 30 * time.Second
+	DefaultShutdownDelay     = 5 * time.Second
+	DefaultHealthTimeout     = 2 * time.Second
+	DefaultHealthReadyChecks = "routes,keys,revocations"
+	DefaultUpstreamInterval  = 10 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20 // 1 MB
+	DefaultMaxConnections    = 10000
+	DefaultRouteAuditLimit   = 1000
+	DefaultRouteHistory      = 20
+	DefaultRouteResync       = 30 * time.Second
+	DefaultShadowMaxInFlight = 100
+	DefaultShadowMaxBody     = 1 << 20 // 1 MB
+	DefaultCacheMaxBody      = 1 << 20 // 1 MB
+	DefaultCoalesceMaxBody   = 1 << 20 // 1 MB
+	DefaultAPIKeyHeader      = "X-API-Key"
+	DefaultAPIKeyCacheTTL    = 30 * time.Second
+	DefaultRevocationRetain  = 24 * time.Hour
+	DefaultRevocationResync  = time.Minute
 )
 
-// Config holds the gateway configuration
+// Config holds the gateway configuration.
+// Precedence: defaults < config file < environment variables < CLI flags.
 type Config struct {
-	Port int
+	Server    ServerConfig    `yaml:"server"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Proxy     ProxyConfig     `yaml:"proxy"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Health    HealthConfig    `yaml:"health"`
+	Admin     AdminConfig     `yaml:"admin"`
+
+	// RouteStore shares routes between instances through Redis
+	RouteStore RouteStoreConfig `yaml:"route_store"`
+
+	// Cache limits the response cache; routes opt in with a cache block
+	Cache CacheConfig `yaml:"cache"`
+
+	// APIKeys configures API key auth; routes opt in with auth_methods
+	APIKeys APIKeysConfig `yaml:"api_keys"`
+
+	// Revocation configures the shared JWT revocation list
+	Revocation RevocationConfig `yaml:"revocation"`
+
+	// Routes are either inline or included from RoutesFile (inline wins)
+	RoutesFile string  `yaml:"routes_file"`
+	Routes     []Route `yaml:"routes,omitempty"`
+
+	// envErrors holds environment values that could not be parsed;
+	// Validate reports them
+	envErrors []error
+}
+
+// ServerConfig holds listener settings and limits
+type ServerConfig struct {
+	Port int `yaml:"port"`
+
+	// Limits against slowloris-style exhaustion
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"` // Whole request including body
+	IdleTimeout       time.Duration `yaml:"idle_timeout"` // Keep-alive wait between requests
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxConnections    int           `yaml:"max_connections"` // Concurrent connections (0 = unlimited)
+
+	// Graceful shutdown: after SIGTERM /health reports not-ready for
+	// ShutdownDelay so load balancers stop routing here, then in-flight
+	// requests and WebSockets get up to ShutdownGracePeriod to finish.
+	ShutdownDelay       time.Duration `yaml:"shutdown_delay"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+}
+
+// TLSConfig holds HTTPS listener settings. TLS is enabled when at least one
+// certificate is configured.
+type TLSConfig struct {
+	Certificates   []CertificatePair `yaml:"certificates"`
+	MinVersion     string            `yaml:"min_version"`     // "1.0" - "1.3"
+	CipherSuites   []string          `yaml:"cipher_suites"`   // IANA names, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	RedirectPort   int               `yaml:"redirect_port"`   // Plain HTTP port redirecting to HTTPS (0 = disabled)
+	ReloadInterval time.Duration     `yaml:"reload_interval"` // How often cert files are checked for changes
+
+	// Client certificate (mTLS) authentication
+	ClientCAFile    string `yaml:"client_ca_file"`    // CA bundle used to verify client certs (empty = mTLS disabled)
+	ClientUserField string `yaml:"client_user_field"` // Cert field mapped to X-User-ID (cn, ou, o, san_dns, san_email, san_uri)
+	ClientIDField   string `yaml:"client_id_field"`   // Cert field mapped to X-Client-ID
+}
+
+// CertificatePair is a certificate/key file pair served by SNI
+type CertificatePair struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// RedisConfig holds the shared state store connection
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// AuthConfig holds JWT validation settings
+type AuthConfig struct {
+	PublicKeyPath string `yaml:"public_key_path"`
+	Issuer        string `yaml:"issuer"` // Expected iss claim (empty = not checked)
+
+	// Audience lists accepted aud values; tokens must name at least one
+	// (empty = not checked)
+	Audience []string `yaml:"audience"`
+
+	// ClockSkew is the leeway for exp, nbf and iat between the gateway and
+	// the identity provider. MaxAge rejects tokens issued longer ago,
+	// whatever their exp (0 = no limit).
+	ClockSkew time.Duration `yaml:"clock_skew"`
+	MaxAge    time.Duration `yaml:"max_age"`
+
+	// RolesClaim is the claim holding the caller's roles for route
+	// authorization; dots select nested members (e.g. realm_access.roles)
+	RolesClaim string `yaml:"roles_claim"`
+
+	// JWKS replaces the public key with a JSON Web Key Set read from a file
+	// or http(s) URL and re-read every JWKSRefresh, so keys can be rotated
+	// without restarts
+	JWKS        string        `yaml:"jwks"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh"`
+
+	// Algorithms lists the accepted JWT signature algorithms. Each key is
+	// still only used with algorithms matching its type.
+	Algorithms []string `yaml:"algorithms"`
+}
+
+// RateLimitConfig holds the per-client sliding window limit
+type RateLimitConfig struct {
+	Limit int `yaml:"limit"` // Requests per minute
+
+	// TrustedProxies are load balancer IPs or CIDRs whose X-Forwarded-For
+	// entries identify anonymous callers; without them the connection's
+	// address is used
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// ProxyConfig holds upstream connection settings
+type ProxyConfig struct {
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+
+	// Shadow traffic: mirrors beyond these limits are dropped rather than
+	// slowing down the client request
+	ShadowMaxInFlight int   `yaml:"shadow_max_in_flight"` // Concurrent mirrored requests
+	ShadowMaxBody     int64 `yaml:"shadow_max_body"`      // Larger request bodies are not mirrored
+
+	// Coalesced responses larger than this are not shared; waiting
+	// requests are forwarded on their own instead
+	CoalesceMaxBody int64 `yaml:"coalesce_max_body"`
+}
+
+// TracingConfig controls pipeline visualization events
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// HealthConfig controls readiness checks: checks listed in ReadyChecks
+// (redis, routes, keys, upstreams, revocations) make /health/ready fail;
+// others are informational.
+type HealthConfig struct {
+	Timeout     time.Duration `yaml:"timeout"`
+	ReadyChecks []string      `yaml:"ready_checks"`
+	// UpstreamInterval is how long upstream dial results are reused, so
+	// readiness probes do not open connections on every call
+	UpstreamInterval time.Duration `yaml:"upstream_interval"`
+}
+
+// AdminConfig protects the /admin API. The API is disabled without a token.
+type AdminConfig struct {
+	Token string `yaml:"token"` // Sent by callers in X-Admin-Token
+}
+
+// Enabled reports whether the admin API is served
+func (a AdminConfig) Enabled() bool {
+	return a.Token != ""
+}
+
+// RouteStoreConfig controls Redis-backed routes. When enabled, the routes
+// file only seeds an empty store; the admin API edits the stored routes and
+// every instance swaps in changes as they are published.
+type RouteStoreConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	AuditLimit int           `yaml:"audit_limit"` // Audit entries kept in Redis
+	History    int           `yaml:"history"`     // Route table versions kept for rollback
+	Resync     time.Duration `yaml:"resync"`      // Poll interval covering missed notifications
+}
+
+// CacheConfig limits the Redis response cache
+type CacheConfig struct {
+	MaxBody int64 `yaml:"max_body"` // Larger responses are not cached
+}
+
+// APIKeysConfig sets where clients send API keys and how long validated
+// keys are cached by each instance
+type APIKeysConfig struct {
+	Header     string        `yaml:"header"`
+	QueryParam string        `yaml:"query_param"` // Empty = header only; query strings tend to end up in logs
+	CacheTTL   time.Duration `yaml:"cache_ttl"`   // Changes on other instances apply within this time
+}
+
+// RevocationConfig sets how long revocations are kept and how often each
+// instance reloads the list
+type RevocationConfig struct {
+	// MaxTokenLifetime, when set, is the longest time between a token's
+	// iat and exp: longer-lived tokens and tokens without iat or exp are
+	// rejected, and revocations are kept until the tokens they cover have
+	// expired. 0 accepts any lifetime.
+	MaxTokenLifetime time.Duration `yaml:"max_token_lifetime"`
+	// Retain is how long revocations without a known expiry are kept when
+	// MaxTokenLifetime is 0; tokens outliving it are accepted again
+	Retain time.Duration `yaml:"retain"`
+	Resync time.Duration `yaml:"resync"` // Full reload, covering missed notifications
+}
+
+// Lifetime is how long after issue a revoked token may still be presented
+func (c RevocationConfig) Lifetime() time.Duration {
+	if c.MaxTokenLifetime > 0 {
+		return c.MaxTokenLifetime
+	}
+	return c.Retain
+}
+
+// Options are command-line switches that are not configuration values
+type Options struct {
+	ConfigPath  string
+	PrintConfig bool
+}
+
+// Enabled reports whether the listener should serve HTTPS
+func (t TLSConfig) Enabled() bool {
+	return len(t.Certificates) > 0
+}
+
+// MTLSEnabled reports whether client certificates are requested and verified
+func (t TLSConfig) MTLSEnabled() bool {
+	return t.Enabled() && t.ClientCAFile != ""
 }
 
-// Load reads configuration from environment variables with defaults
-func Load() *Config {
+// Defaults returns the built-in configuration
+func Defaults() *Config {
 	return &Config{
-		Port: getEnvInt("SERVER_PORT", DefaultPort),
+		Server: ServerConfig{
+			Port:                DefaultPort,
+			ReadHeaderTimeout:   DefaultReadHeaderTimeout,
+			ReadTimeout:         DefaultReadTimeout,
+			IdleTimeout:         DefaultIdleTimeout,
+			MaxHeaderBytes:      DefaultMaxHeaderBytes,
+			MaxConnections:      DefaultMaxConnections,
+			ShutdownDelay:       DefaultShutdownDelay,
+			ShutdownGracePeriod: DefaultShutdownGrace,
+		},
+		TLS: TLSConfig{
+			MinVersion:      DefaultTLSMinVersion,
+			ReloadInterval:  DefaultTLSReloadInterval,
+			ClientUserField: DefaultClientUserField,
+			ClientIDField:   DefaultClientIDField,
+		},
+		Redis: RedisConfig{Addr: DefaultRedisAddr},
+		Auth: AuthConfig{
+			PublicKeyPath: DefaultPublicKeyPath,
+			ClockSkew:     DefaultJWTClockSkew,
+			RolesClaim:    DefaultJWTRolesClaim,
+			JWKSRefresh:   DefaultJWKSRefresh,
+			Algorithms:    strings.Split(DefaultJWTAlgorithms, ","),
+		},
+		RateLimit: RateLimitConfig{Limit: DefaultRateLimit},
+		Proxy: ProxyConfig{
+			ConnectTimeout:    DefaultConnectTimeout * time.Second,
+			ShadowMaxInFlight: DefaultShadowMaxInFlight,
+			ShadowMaxBody:     DefaultShadowMaxBody,
+			CoalesceMaxBody:   DefaultCoalesceMaxBody,
+		},
+		Tracing: TracingConfig{Enabled: true},
+		Health: HealthConfig{
+			Timeout:          DefaultHealthTimeout,
+			ReadyChecks:      strings.Split(DefaultHealthReadyChecks, ","),
+			UpstreamInterval: DefaultUpstreamInterval,
+		},
+		RouteStore: RouteStoreConfig{
+			AuditLimit: DefaultRouteAuditLimit,
+			History:    DefaultRouteHistory,
+			Resync:     DefaultRouteResync,
+		},
+		Cache:      CacheConfig{MaxBody: DefaultCacheMaxBody},
+		APIKeys:    APIKeysConfig{Header: DefaultAPIKeyHeader, CacheTTL: DefaultAPIKeyCacheTTL},
+		Revocation: RevocationConfig{Retain: DefaultRevocationRetain, Resync: DefaultRevocationResync},
+		RoutesFile: DefaultRoutesPath,
 	}
 }
 
+// Load builds the effective configuration from defaults, the config file
+// (--config, GATEWAY_CONFIG, or config/gateway.yaml if present),
+// environment variables and command-line flags, then validates it.
+func Load(args []string) (*Config, Options, error) {
+	fs := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	var opts Options
+	fs.StringVar(&opts.ConfigPath, "config", os.Getenv("GATEWAY_CONFIG"), "path to gateway config file (YAML or JSON)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	port := fs.Int("port", 0, "listen port")
+	routesFile := fs.String("routes", "", "routes file")
+	redisAddr := fs.String("redis-addr", "", "Redis address")
+	publicKey := fs.String("public-key", "", "JWT public key path")
+	rateLimit := fs.Int("rate-limit", 0, "requests per minute per client")
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	cfg := Defaults()
+
+	path := opts.ConfigPath
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigPath); err == nil {
+			path = DefaultConfigPath
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, opts, err
+		}
+		opts.ConfigPath = path
+	}
+
+	cfg.applyEnv()
+
+	// Only flags given explicitly override lower layers
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "routes":
+			cfg.RoutesFile = *routesFile
+			cfg.Routes = nil
+		case "redis-addr":
+			cfg.Redis.Addr = *redisAddr
+		case "public-key":
+			cfg.Auth.PublicKeyPath = *publicKey
+		case "rate-limit":
+			cfg.RateLimit.Limit = *rateLimit
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return cfg, opts, nil
+}
+
+// loadFile strictly decodes a config file over the current values.
+// JSON is accepted since it is a subset of YAML.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // Typos are errors, not silently ignored keys
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides values from environment variables that are set
+func (c *Config) applyEnv() {
+	c.Server.Port = c.getEnvInt("SERVER_PORT", c.Server.Port)
+	c.Server.ReadHeaderTimeout = c.getEnvDuration("SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout)
+	c.Server.ReadTimeout = c.getEnvDuration("SERVER_READ_TIMEOUT", c.Server.ReadTimeout)
+	c.Server.IdleTimeout = c.getEnvDuration("SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout)
+	c.Server.MaxHeaderBytes = c.getEnvInt("SERVER_MAX_HEADER_BYTES", c.Server.MaxHeaderBytes)
+	c.Server.MaxConnections = c.getEnvInt("SERVER_MAX_CONNECTIONS", c.Server.MaxConnections)
+	c.Server.ShutdownDelay = c.getEnvDuration("SHUTDOWN_DELAY", c.Server.ShutdownDelay)
+	c.Server.ShutdownGracePeriod = c.getEnvDuration("SHUTDOWN_GRACE_PERIOD", c.Server.ShutdownGracePeriod)
+
+	if pairs := c.parseCertificatePairs("TLS_CERTS"); pairs != nil {
+		c.TLS.Certificates = pairs
+	}
+	c.TLS.MinVersion = getEnv("TLS_MIN_VERSION", c.TLS.MinVersion)
+	if suites := getEnvList("TLS_CIPHER_SUITES"); suites != nil {
+		c.TLS.CipherSuites = suites
+	}
+	c.TLS.RedirectPort = c.getEnvInt("TLS_REDIRECT_PORT", c.TLS.RedirectPort)
+	c.TLS.ReloadInterval = c.getEnvDuration("TLS_RELOAD_INTERVAL", c.TLS.ReloadInterval)
+	c.TLS.ClientCAFile = getEnv("TLS_CLIENT_CA", c.TLS.ClientCAFile)
+	c.TLS.ClientUserField = getEnv("TLS_CLIENT_USER_FIELD", c.TLS.ClientUserField)
+	c.TLS.ClientIDField = getEnv("TLS_CLIENT_ID_FIELD", c.TLS.ClientIDField)
+
+	c.Redis.Addr = getEnv("REDIS_ADDR", c.Redis.Addr)
+	c.Redis.Password = getEnv("REDIS_PASSWORD", c.Redis.Password)
+	c.Redis.DB = c.getEnvInt("REDIS_DB", c.Redis.DB)
+
+	c.Auth.PublicKeyPath = getEnv("JWT_PUBLIC_KEY_PATH", c.Auth.PublicKeyPath)
+	c.Auth.Issuer = getEnv("JWT_ISSUER", c.Auth.Issuer)
+	if aud := getEnvList("JWT_AUDIENCE"); aud != nil {
+		c.Auth.Audience = aud
+	}
+	c.Auth.ClockSkew = c.getEnvDuration("JWT_CLOCK_SKEW", c.Auth.ClockSkew)
+	c.Auth.MaxAge = c.getEnvDuration("JWT_MAX_AGE", c.Auth.MaxAge)
+	c.Auth.RolesClaim = getEnv("JWT_ROLES_CLAIM", c.Auth.RolesClaim)
+	c.Auth.JWKS = getEnv("JWT_JWKS", c.Auth.JWKS)
+	c.Auth.JWKSRefresh = c.getEnvDuration("JWT_JWKS_REFRESH", c.Auth.JWKSRefresh)
+	if algs := getEnvList("JWT_ALGORITHMS"); algs != nil {
+		c.Auth.Algorithms = algs
+	}
+
+	c.RateLimit.Limit = c.getEnvInt("RATE_LIMIT_DEFAULT", c.RateLimit.Limit)
+	if proxies := getEnvList("RATE_LIMIT_TRUSTED_PROXIES"); proxies != nil {
+		c.RateLimit.TrustedProxies = proxies
+	}
+	c.Proxy.ConnectTimeout = c.getEnvDuration("PROXY_CONNECT_TIMEOUT", c.Proxy.ConnectTimeout)
+	c.Proxy.ShadowMaxInFlight = c.getEnvInt("PROXY_SHADOW_MAX_IN_FLIGHT", c.Proxy.ShadowMaxInFlight)
+	c.Proxy.ShadowMaxBody = int64(c.getEnvInt("PROXY_SHADOW_MAX_BODY", int(c.Proxy.ShadowMaxBody)))
+	c.Proxy.CoalesceMaxBody = int64(c.getEnvInt("PROXY_COALESCE_MAX_BODY", int(c.Proxy.CoalesceMaxBody)))
+	c.Tracing.Enabled = c.getEnvBool("TRACING_ENABLED", c.Tracing.Enabled)
+
+	c.Health.Timeout = c.getEnvDuration("HEALTH_CHECK_TIMEOUT", c.Health.Timeout)
+	c.Health.UpstreamInterval = c.getEnvDuration("HEALTH_UPSTREAM_INTERVAL", c.Health.UpstreamInterval)
+	if checks := getEnvList("HEALTH_READY_CHECKS"); checks != nil {
+		c.Health.ReadyChecks = checks
+	}
+
+	c.Admin.Token = getEnv("ADMIN_TOKEN", c.Admin.Token)
+
+	c.RouteStore.Enabled = c.getEnvBool("ROUTE_STORE_ENABLED", c.RouteStore.Enabled)
+	c.RouteStore.AuditLimit = c.getEnvInt("ROUTE_STORE_AUDIT_LIMIT", c.RouteStore.AuditLimit)
+	c.RouteStore.History = c.getEnvInt("ROUTE_STORE_HISTORY", c.RouteStore.History)
+	c.RouteStore.Resync = c.getEnvDuration("ROUTE_STORE_RESYNC", c.RouteStore.Resync)
+
+	c.Cache.MaxBody = int64(c.getEnvInt("CACHE_MAX_BODY", int(c.Cache.MaxBody)))
+
+	c.APIKeys.Header = getEnv("API_KEY_HEADER", c.APIKeys.Header)
+	c.APIKeys.QueryParam = getEnv("API_KEY_QUERY_PARAM", c.APIKeys.QueryParam)
+	c.APIKeys.CacheTTL = c.getEnvDuration("API_KEY_CACHE_TTL", c.APIKeys.CacheTTL)
+
+	c.Revocation.MaxTokenLifetime = c.getEnvDuration("REVOCATION_MAX_TOKEN_LIFETIME", c.Revocation.MaxTokenLifetime)
+	c.Revocation.Retain = c.getEnvDuration("REVOCATION_RETAIN", c.Revocation.Retain)
+	c.Revocation.Resync = c.getEnvDuration("REVOCATION_RESYNC", c.Revocation.Resync)
+
+	if path := os.Getenv("ROUTES_PATH"); path != "" {
+		c.RoutesFile = path
+		c.Routes = nil
+	}
+}
+
+// Validate checks value ranges and cross-field rules, reporting every problem
+// including unparsable environment values.
+// Route warnings (see CheckRoutes) do not fail validation.
+func (c *Config) Validate() error {
+	errs := append(slices.Clone(c.envErrors), c.settingsErrors()...)
+	for _, d := range CheckRoutes(c.Routes) {
+		if d.Severity == SeverityError {
+			errs = append(errs, fmt.Errorf("%s: %s", d.Field, d.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settingsErrors checks everything except routes. Each error starts with
+// the dotted field path followed by ": ".
+func (c *Config) settingsErrors() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port: %d out of range", c.Server.Port)
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout: must be positive")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout: must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout: must not be negative")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes: must be positive")
+	check(c.Server.MaxConnections >= 0, "server.max_connections: must not be negative")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay: must not be negative")
+	check(c.Server.ShutdownGracePeriod > 0, "server.shutdown_grace_period: must be positive")
+
+	switch c.TLS.MinVersion {
+	case "1.0", "1.1", "1.2", "1.3":
+	default:
+		errs = append(errs, fmt.Errorf("tls.min_version: unsupported version %q", c.TLS.MinVersion))
+	}
+	for i, p := range c.TLS.Certificates {
+		check(p.CertFile != "" && p.KeyFile != "", "tls.certificates[%d]: cert_file and key_file are required", i)
+	}
+	check(c.TLS.RedirectPort >= 0 && c.TLS.RedirectPort < 65536, "tls.redirect_port: %d out of range", c.TLS.RedirectPort)
+	check(c.TLS.RedirectPort == 0 || c.TLS.RedirectPort != c.Server.Port, "tls.redirect_port: must differ from server.port")
+	check(c.TLS.ReloadInterval > 0, "tls.reload_interval: must be positive")
+	check(c.TLS.ClientCAFile == "" || c.TLS.Enabled(), "tls.client_ca_file: requires tls.certificates")
+
+	check(c.Redis.Addr != "", "redis.addr: required")
+	check(c.Auth.PublicKeyPath != "" || c.Auth.JWKS != "", "auth.public_key_path: required unless auth.jwks is set")
+	check(c.Auth.ClockSkew >= 0, "auth.clock_skew: must not be negative")
+	check(c.Auth.MaxAge >= 0, "auth.max_age: must not be negative")
+	check(c.Auth.RolesClaim != "", "auth.roles_claim: required")
+	check(c.Auth.JWKSRefresh > 0, "auth.jwks_refresh: must be positive")
+	check(len(c.Auth.Algorithms) > 0, "auth.algorithms: at least one algorithm is required")
+	for _, alg := range c.Auth.Algorithms {
+		switch alg {
+		case "RS256", "PS256", "ES256", "ES384", "EdDSA":
+		default:
+			errs = append(errs, fmt.Errorf("auth.algorithms: unsupported algorithm %q (use RS256, PS256, ES256, ES384 or EdDSA)", alg))
+		}
+	}
+	check(c.RateLimit.Limit > 0, "rate_limit.limit: must be positive")
+	for _, p := range c.RateLimit.TrustedProxies {
+		_, prefixErr := netip.ParsePrefix(p)
+		_, addrErr := netip.ParseAddr(p)
+		check(prefixErr == nil || addrErr == nil, "rate_limit.trusted_proxies: %q is not an IP address or CIDR", p)
+	}
+	check(c.Proxy.ConnectTimeout > 0, "proxy.connect_timeout: must be positive")
+	check(c.Proxy.ShadowMaxInFlight > 0, "proxy.shadow_max_in_flight: must be positive")
+	check(c.Proxy.ShadowMaxBody >= 0, "proxy.shadow_max_body: must not be negative")
+	check(c.Proxy.CoalesceMaxBody > 0, "proxy.coalesce_max_body: must be positive")
+	check(c.Health.Timeout > 0, "health.timeout: must be positive")
+	check(c.Health.UpstreamInterval > 0, "health.upstream_interval: must be positive")
+	for _, name := range c.Health.ReadyChecks {
+		switch name {
+		case "redis", "routes", "keys", "upstreams", "revocations":
+		default:
+			errs = append(errs, fmt.Errorf("health.ready_checks: unknown check %q", name))
+		}
+	}
+
+	check(c.RouteStore.AuditLimit > 0, "route_store.audit_limit: must be positive")
+	check(c.RouteStore.History > 0, "route_store.history: must be positive")
+	check(c.RouteStore.Resync > 0, "route_store.resync: must be positive")
+	check(c.Cache.MaxBody > 0, "cache.max_body: must be positive")
+	check(c.APIKeys.Header != "", "api_keys.header: required")
+	check(c.APIKeys.CacheTTL > 0, "api_keys.cache_ttl: must be positive")
+	check(c.Revocation.MaxTokenLifetime >= 0, "revocation.max_token_lifetime: must not be negative")
+	check(c.Revocation.Retain > 0, "revocation.retain: must be positive")
+	check(c.Revocation.Resync > 0, "revocation.resync: must be positive")
+
+	check(len(c.Routes) > 0 || c.RoutesFile != "", "routes_file: either routes or routes_file is required")
+	return errs
+}
+
+// LoadRoutes returns the inline routes or reads the included routes file
+func (c *Config) LoadRoutes() (*RoutesConfig, error) {
+	if len(c.Routes) > 0 {
+		rc := &RoutesConfig{Routes: c.Routes}
+		rc.applyDefaults()
+		return rc, nil
+	}
+	return LoadRoutes(c.RoutesFile)
+}
+
+// YAML renders the effective configuration for --print-config.
+// Secrets are redacted.
+func (c *Config) YAML() ([]byte, error) {
+	redacted := *c
+	if redacted.Redis.Password != "" {
+		redacted.Redis.Password = "REDACTED"
+	}
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "REDACTED"
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&redacted); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
 // Address returns the full address string for the server
 func (c *Config) Address() string {
-	return "0.0.0.0:" + strconv.Itoa(c.Port)
+	return "0.0.0.0:" + strconv.Itoa(c.Server.Port)
+}
+
+// RedirectAddress returns the address of the HTTP→HTTPS redirect listener
+func (c *Config) RedirectAddress() string {
+	return "0.0.0.0:" + strconv.Itoa(c.TLS.RedirectPort)
+}
+
+// parseCertificatePairs parses "a.crt:a.key,b.crt:b.key" from key into
+// cert/key pairs
+func (c *Config) parseCertificatePairs(key string) []CertificatePair {
+	var pairs []CertificatePair
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		certFile, keyFile, ok := strings.Cut(item, ":")
+		if !ok || certFile == "" || keyFile == "" {
+			c.invalidEnv(key, item)
+			continue
+		}
+		pairs = append(pairs, CertificatePair{CertFile: certFile, KeyFile: keyFile})
+	}
+	return pairs
+}
+
+// getEnv returns the value of an environment variable or a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
 }
 
 // getEnvInt returns the value of an environment variable as int or a default value
-func getEnvInt(key string, defaultValue int) int {
+func (c *Config) getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
+		intValue, err := strconv.Atoi(value)
+		if err == nil {
 			return intValue
 		}
+		c.invalidEnv(key, value)
+	}
+	return defaultValue
+}
+
+// getEnvBool returns the value of an environment variable as bool or a default value
+func (c *Config) getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err == nil {
+			return b
+		}
+		c.invalidEnv(key, value)
 	}
 	return defaultValue
 }
+
+// getEnvDuration returns the value of an environment variable as duration or a default value
+func (c *Config) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			return d
+		}
+		c.invalidEnv(key, value)
+	}
+	return defaultValue
+}
+
+// invalidEnv records an environment value that could not be parsed
+func (c *Config) invalidEnv(key, value string) {
+	c.envErrors = append(c.envErrors, fmt.Errorf("%s: invalid value %q", key, value))
+}
+
+// getEnvList returns a comma-separated environment variable as a trimmed list
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
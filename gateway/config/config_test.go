@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file into a temp dir and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, _, err := Load([]string{"--config", writeConfig(t, "")})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != DefaultPort {
+		t.Errorf("Expected port %d, got %d", DefaultPort, cfg.Server.Port)
+	}
+	if cfg.Redis.Addr != DefaultRedisAddr {
+		t.Errorf("Expected redis addr '%s', got '%s'", DefaultRedisAddr, cfg.Redis.Addr)
+	}
+	if cfg.Proxy.ConnectTimeout != time.Second {
+		t.Errorf("Expected connect timeout 1s, got %v", cfg.Proxy.ConnectTimeout)
+	}
+	if cfg.RoutesFile != DefaultRoutesPath {
+		t.Errorf("Expected routes file '%s', got '%s'", DefaultRoutesPath, cfg.RoutesFile)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: 7000
+redis:
+  addr: "file-redis:6379"
+rate_limit:
+  limit: 50
+`)
+	t.Setenv("REDIS_ADDR", "env-redis:6379")
+	t.Setenv("RATE_LIMIT_DEFAULT", "75")
+
+	cfg, _, err := Load([]string{"--config", path, "--rate-limit", "25"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 7000 {
+		t.Errorf("Expected file to set port 7000, got %d", cfg.Server.Port)
+	}
+	if cfg.Redis.Addr != "env-redis:6379" {
+		t.Errorf("Expected env to override redis addr, got '%s'", cfg.Redis.Addr)
+	}
+	if cfg.RateLimit.Limit != 25 {
+		t.Errorf("Expected flag to override rate limit, got %d", cfg.RateLimit.Limit)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, `
+redis:
+  adress: "typo:6379"
+`)
+	_, _, err := Load([]string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "adress") {
+		t.Errorf("Expected unknown field error mentioning 'adress', got %v", err)
+	}
+}
+
+func TestLoadInlineRoutes(t *testing.T) {
+	path := writeConfig(t, `
+routes:
+  - path_prefix: "/api"
+    target: "http://api:8080"
+    timeout: 2s
+`)
+	cfg, _, err := Load([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	routes, err := cfg.LoadRoutes()
+	if err != nil {
+		t.Fatalf("LoadRoutes failed: %v", err)
+	}
+	if len(routes.Routes) != 1 || routes.Routes[0].Target != "http://api:8080" {
+		t.Errorf("Expected inline route, got %+v", routes.Routes)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := Defaults()
+	cfg.Server.Port = 0
+	cfg.RateLimit.Limit = -1
+	cfg.TLS.MinVersion = "1.4"
+	cfg.Auth.Algorithms = []string{"HS256"}
+	cfg.Auth.ClockSkew = -time.Second
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, field := range []string{"server.port", "rate_limit.limit", "tls.min_version", "auth.algorithms", "auth.clock_skew"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for %s, got: %v", field, err)
+		}
+	}
+}
+
+func TestYAMLRedactsSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.Redis.Password = "hunter2"
+
+	out, err := cfg.YAML()
+	if err != nil {
+		t.Fatalf("YAML failed: %v", err)
+	}
+	if strings.Contains(string(out), "hunter2") {
+		t.Error("Expected password to be redacted")
+	}
+	if !strings.Contains(string(out), "connect_timeout: 1s") {
+		t.Errorf("Expected durations rendered as strings, got:\n%s", out)
+	}
+}
+
+func TestLoadRejectsInvalidRoutesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown field", "routes:\n  - path_prefix: /a\n    target: http://a:80\n    stip_prefix: true\n", "stip_prefix"},
+		{"bad scheme", "routes:\n  - path_prefix: /a\n    target: ftp://a:21\n", "routes[0].target"},
+		{"duplicate prefix", "routes:\n  - path_prefix: /a\n    target: http://a:80\n  - path_prefix: /a\n    target: http://b:80\n", "routes[1].path_prefix"},
+		{"zero weights", "routes:\n  - path_prefix: /a\n    split:\n      - target: http://a:80\n        weight: 0\n      - target: http://b:80\n        weight: 0\n", "routes[0].split"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, _, err := Load([]string{"--config", writeConfig(t, ""), "--routes", writeRoutes(t, tc.content)})
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if _, err := cfg.LoadRoutes(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Expected routes error mentioning %s, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	t.Setenv("SERVER_READ_TIMEOUT", "30")
+	t.Setenv("RATE_LIMIT_DEFAULT", "abc")
+	t.Setenv("TRACING_ENABLED", "maybe")
+	t.Setenv("TLS_CERTS", "a.crt:a.key,b.crt")
+
+	_, _, err := Load([]string{"--config", writeConfig(t, "")})
+	if err == nil {
+		t.Fatal("Expected error for invalid environment values")
+	}
+	for _, want := range []string{
+		`SERVER_READ_TIMEOUT: invalid value "30"`,
+		`RATE_LIMIT_DEFAULT: invalid value "abc"`,
+		`TRACING_ENABLED: invalid value "maybe"`,
+		`TLS_CERTS: invalid value "b.crt"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got: %v", want, err)
+		}
+	}
+}
@@ -1,42 +1,469 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"os"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
+)
+
+// DefaultRouteTimeout applies to routes without a timeout
+const DefaultRouteTimeout = 30 * time.Second
+
+// DefaultShadowTimeout applies to shadow targets without a timeout
+const DefaultShadowTimeout = 5 * time.Second
+
+// Authentication methods a route can accept
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodMTLS   = "mtls"
+	AuthMethodAPIKey = "apikey"
+)
 
-	"gopkg.in/yaml.v3"
+// Auth modes of a route
+const (
+	AuthModeRequired = "required" // Callers must authenticate (default)
+	AuthModeOptional = "optional" // Credentials are checked when present; otherwise anonymous
+	AuthModeNone     = "none"     // Public: credentials are ignored
 )
 
 // Route represents a single route configuration
 type Route struct {
-	PathPrefix  string        `yaml:"path_prefix"`
-	Target      string        `yaml:"target"`
-	StripPrefix bool          `yaml:"strip_prefix"`
-	Timeout     time.Duration `yaml:"timeout"`
+	PathPrefix  string        `yaml:"path_prefix" json:"path_prefix"`
+	Target      string        `yaml:"target" json:"target"`
+	StripPrefix bool          `yaml:"strip_prefix" json:"strip_prefix"`
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	AuthMethods []string      `yaml:"auth_methods,omitempty" json:"auth_methods,omitempty"` // jwt, mtls, apikey; defaults to [jwt]
+	Auth        *RouteAuth    `yaml:"auth,omitempty" json:"auth,omitempty"`                 // Token requirements beyond the global auth settings
+	Split       *TrafficSplit `yaml:"split,omitempty" json:"split,omitempty"`               // Weighted upstream groups
+	Shadow      *Shadow       `yaml:"shadow,omitempty" json:"shadow,omitempty"`             // Mirror traffic to a second upstream
+	Fault       *Fault        `yaml:"fault,omitempty" json:"fault,omitempty"`               // Injected failures for resilience testing
+	Cache       *RouteCache   `yaml:"cache,omitempty" json:"cache,omitempty"`               // Cache GET responses in Redis
+	Coalesce    *Coalesce     `yaml:"coalesce,omitempty" json:"coalesce,omitempty"`         // Share one upstream call between identical GETs
+}
+
+// Shadow mirrors a sample of a route's requests to a second upstream.
+// Mirrored responses are discarded.
+type Shadow struct {
+	Target  string        `yaml:"target" json:"target"`
+	Percent float64       `yaml:"percent" json:"percent"` // Share of requests mirrored, 0-100
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// ForwardCredentials mirrors Cookie and Authorization, which are
+	// dropped by default because shadow upstreams are often less trusted
+	ForwardCredentials bool `yaml:"forward_credentials,omitempty" json:"forward_credentials,omitempty"`
+
+	// Compare diffs shadow responses against the primary (nil = fire and forget)
+	Compare *ShadowCompare `yaml:"compare,omitempty" json:"compare,omitempty"`
+}
+
+// ShadowCompare selects what must match between primary and shadow
+// responses. Status codes are always compared; JSON bodies are compared
+// structurally and other bodies byte for byte.
+type ShadowCompare struct {
+	Headers     []string `yaml:"headers,omitempty" json:"headers,omitempty"`           // Response headers that must match
+	IgnorePaths []string `yaml:"ignore_paths,omitempty" json:"ignore_paths,omitempty"` // JSON paths left out, e.g. meta.timestamp, items[*].id
+}
+
+// MarshalJSON renders the timeout as a duration string ("5s")
+func (s Shadow) MarshalJSON() ([]byte, error) {
+	type alias Shadow
+	return json.Marshal(struct {
+		alias
+		Timeout string `json:"timeout"`
+	}{alias(s), s.Timeout.String()})
+}
+
+// UnmarshalJSON accepts the timeout as a duration string ("5s")
+func (s *Shadow) UnmarshalJSON(data []byte) error {
+	type alias Shadow
+	aux := struct {
+		*alias
+		Timeout string `json:"timeout"`
+	}{alias: (*alias)(s)}
+	if err := unmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+	d, err := parseJSONDuration(aux.Timeout)
+	if err != nil {
+		return err
+	}
+	s.Timeout = d
+	return nil
+}
+
+// Fault injects failures into a route's requests for resilience testing.
+// With Header or ClientIDs set, only matching requests are affected;
+// otherwise every request is. Each fault is sampled independently.
+type Fault struct {
+	Header    string   `yaml:"header,omitempty" json:"header,omitempty"`         // Requests carrying this header (any value)
+	ClientIDs []string `yaml:"client_ids,omitempty" json:"client_ids,omitempty"` // Requests from these X-Client-ID values
+
+	Delay *FaultDelay `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Abort *FaultAbort `yaml:"abort,omitempty" json:"abort,omitempty"`
+	Reset *FaultReset `yaml:"reset,omitempty" json:"reset,omitempty"`
+}
+
+// FaultDelay holds requests before they are forwarded. The delay counts
+// against the route timeout.
+type FaultDelay struct {
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	Percent  float64       `yaml:"percent" json:"percent"` // Share of affected requests, 0-100
+}
+
+// FaultAbort answers requests with Status instead of forwarding them
+type FaultAbort struct {
+	Status  int     `yaml:"status" json:"status"`
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// FaultReset closes the client connection without a response
+type FaultReset struct {
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// MarshalJSON renders the duration as a duration string ("2s")
+func (d FaultDelay) MarshalJSON() ([]byte, error) {
+	type alias FaultDelay
+	return json.Marshal(struct {
+		alias
+		Duration string `json:"duration"`
+	}{alias(d), d.Duration.String()})
+}
+
+// UnmarshalJSON accepts the duration as a duration string ("2s")
+func (d *FaultDelay) UnmarshalJSON(data []byte) error {
+	type alias FaultDelay
+	aux := struct {
+		*alias
+		Duration string `json:"duration"`
+	}{alias: (*alias)(d)}
+	if err := unmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+	v, err := parseJSONDuration(aux.Duration)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// RouteCache caches a route's GET responses in Redis, honoring the
+// upstream's Cache-Control, Expires and Vary headers
+type RouteCache struct {
+	// TTL is the freshness of responses that set none (0 caches only
+	// responses with explicit freshness or validators)
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+	// StaleWhileRevalidate serves stale responses this long after they
+	// expire while refreshing them, unless the response sets its own
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate" json:"stale_while_revalidate"`
+	Key                  CacheKey      `yaml:"key" json:"key"`
+}
+
+// CacheKey selects the request parts that tell cached responses apart.
+// Method and path are always part of the key.
+type CacheKey struct {
+	IgnoreQuery bool     `yaml:"ignore_query,omitempty" json:"ignore_query,omitempty"`
+	Headers     []string `yaml:"headers,omitempty" json:"headers,omitempty"`     // e.g. Accept-Language
+	ClientID    bool     `yaml:"client_id,omitempty" json:"client_id,omitempty"` // X-Client-ID
+	UserID      bool     `yaml:"user_id,omitempty" json:"user_id,omitempty"`     // X-User-ID
+}
+
+// PerCaller reports whether keys separate callers, which allows caching
+// Cache-Control: private and authenticated responses
+func (k CacheKey) PerCaller() bool {
+	if k.ClientID || k.UserID {
+		return true
+	}
+	for _, h := range k.Headers {
+		h = strings.TrimSpace(h)
+		if strings.EqualFold(h, "X-User-ID") || strings.EqualFold(h, "X-Client-ID") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON renders the durations as duration strings ("30s")
+func (c RouteCache) MarshalJSON() ([]byte, error) {
+	type alias RouteCache
+	return json.Marshal(struct {
+		alias
+		TTL                  string `json:"ttl"`
+		StaleWhileRevalidate string `json:"stale_while_revalidate"`
+	}{alias(c), c.TTL.String(), c.StaleWhileRevalidate.String()})
+}
+
+// UnmarshalJSON accepts the durations as duration strings ("30s")
+func (c *RouteCache) UnmarshalJSON(data []byte) error {
+	type alias RouteCache
+	aux := struct {
+		*alias
+		TTL                  string `json:"ttl"`
+		StaleWhileRevalidate string `json:"stale_while_revalidate"`
+	}{alias: (*alias)(c)}
+	if err := unmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+	ttl, err := parseJSONDuration(aux.TTL)
+	if err != nil {
+		return err
+	}
+	swr, err := parseJSONDuration(aux.StaleWhileRevalidate)
+	if err != nil {
+		return err
+	}
+	c.TTL, c.StaleWhileRevalidate = ttl, swr
+	return nil
+}
+
+// RouteAuth tightens JWT validation for one route. Issuer and Audience
+// replace the global auth.issuer and auth.audience when set.
+type RouteAuth struct {
+	Mode     string   `yaml:"mode,omitempty" json:"mode,omitempty"` // required, optional or none; defaults to required
+	Issuer   string   `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+	Audience []string `yaml:"audience,omitempty" json:"audience,omitempty"` // Token aud must include one of these
+
+	// ClaimHeaders copy token claims into upstream request headers.
+	// Client-sent copies of these headers are always removed.
+	ClaimHeaders []ClaimHeader `yaml:"claim_headers,omitempty" json:"claim_headers,omitempty"`
+
+	// Authorization rules for every method; Methods adds rules for single
+	// methods, e.g. a write scope for POST
+	AuthzRule `yaml:",inline"`
+	Methods   map[string]AuthzRule `yaml:"methods,omitempty" json:"methods,omitempty"`
+}
+
+// AuthzRule lists what a token must carry: every scope in Scopes and role
+// in AllRoles, and at least one role in AnyRoles
+type AuthzRule struct {
+	Scopes   []string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	AnyRoles []string `yaml:"any_roles,omitempty" json:"any_roles,omitempty"`
+	AllRoles []string `yaml:"all_roles,omitempty" json:"all_roles,omitempty"`
+}
+
+func (r AuthzRule) empty() bool {
+	return len(r.Scopes) == 0 && len(r.AnyRoles) == 0 && len(r.AllRoles) == 0
+}
+
+// check returns the first requirement of r the caller does not meet
+func (r AuthzRule) check(scopes, roles []string) error {
+	for _, s := range r.Scopes {
+		if !slices.Contains(scopes, s) {
+			return fmt.Errorf("missing scope %s", s)
+		}
+	}
+	for _, role := range r.AllRoles {
+		if !slices.Contains(roles, role) {
+			return fmt.Errorf("missing role %s", role)
+		}
+	}
+	if len(r.AnyRoles) > 0 && !slices.ContainsFunc(r.AnyRoles, func(role string) bool { return slices.Contains(roles, role) }) {
+		return fmt.Errorf("requires one of the roles %s", strings.Join(r.AnyRoles, ", "))
+	}
+	return nil
+}
+
+// HasRules reports whether the route restricts callers by scope or role
+func (a *RouteAuth) HasRules() bool {
+	if a == nil {
+		return false
+	}
+	if !a.AuthzRule.empty() {
+		return true
+	}
+	for _, rule := range a.Methods {
+		if !rule.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks a caller's scopes and roles against the route rules and
+// those for method. The error names the first unmet requirement.
+func (a *RouteAuth) Authorize(method string, scopes, roles []string) error {
+	if a == nil {
+		return nil
+	}
+	if err := a.AuthzRule.check(scopes, roles); err != nil {
+		return err
+	}
+	if rule, ok := a.Methods[method]; ok {
+		if err := rule.check(scopes, roles); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// ClaimHeader maps a JWT claim to an upstream header. Claim may be a dotted
+// path into nested objects (e.g. realm_access.roles). Arrays are sent
+// comma-separated; missing claims and values that are not plain printable
+// text leave the header unset.
+type ClaimHeader struct {
+	Claim  string `yaml:"claim" json:"claim"`
+	Header string `yaml:"header" json:"header"` // e.g. X-Tenant-ID
+}
+
+// Coalesce collapses identical concurrent GETs of a route into one upstream
+// request whose response is sent to every waiting client. Requests are
+// identical when method, upstream URL, conditional and Range headers and
+// the listed Headers match.
+type Coalesce struct {
+	Headers []string `yaml:"headers,omitempty" json:"headers,omitempty"` // e.g. Accept-Language, X-User-ID
+}
+
+// Sticky assignment modes for traffic splits
+const (
+	StickyNone     = ""
+	StickyClientID = "client_id" // Hash of X-Client-ID
+	StickyCookie   = "cookie"    // Group remembered in a cookie
+)
+
+// TrafficSplit divides a route's traffic between upstream groups by weight,
+// e.g. 95% stable and 5% canary.
+type TrafficSplit struct {
+	Groups         []UpstreamGroup `yaml:"groups" json:"groups"`
+	Sticky         string          `yaml:"sticky,omitempty" json:"sticky,omitempty"`                   // "", client_id or cookie
+	Cookie         string          `yaml:"cookie,omitempty" json:"cookie,omitempty"`                   // Sticky cookie name
+	OverrideHeader string          `yaml:"override_header,omitempty" json:"override_header,omitempty"` // e.g. X-Canary
+}
+
+// UpstreamGroup is one destination of a traffic split
+type UpstreamGroup struct {
+	Name     string `yaml:"name" json:"name"`                             // Label on gateway_requests_total
+	Target   string `yaml:"target,omitempty" json:"target,omitempty"`     // Defaults to the route target
+	Weight   int    `yaml:"weight" json:"weight"`                         // Relative share of traffic
+	Override string `yaml:"override,omitempty" json:"override,omitempty"` // OverrideHeader value forcing this group
+}
+
+// MarshalJSON renders the timeout as a duration string ("5s") for the admin API
+func (r Route) MarshalJSON() ([]byte, error) {
+	type alias Route
+	return json.Marshal(struct {
+		alias
+		Timeout string `json:"timeout"`
+	}{alias(r), r.Timeout.String()})
+}
+
+// UnmarshalJSON accepts the timeout as a duration string ("5s")
+func (r *Route) UnmarshalJSON(data []byte) error {
+	type alias Route
+	aux := struct {
+		*alias
+		Timeout string `json:"timeout"`
+	}{alias: (*alias)(r)}
+	if err := unmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+	d, err := parseJSONDuration(aux.Timeout)
+	if err != nil {
+		return err
+	}
+	r.Timeout = d
+	return nil
+}
+
+// unmarshalStrict is json.Unmarshal rejecting unknown fields, as routes
+// files do. A decoder's DisallowUnknownFields does not reach custom
+// unmarshalers, so each of them applies it itself.
+func unmarshalStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// parseJSONDuration parses a duration string; empty means unset
+func parseJSONDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	return d, nil
+}
+
+// AuthMode returns the route's auth mode, required unless configured
+func (r *Route) AuthMode() string {
+	if r.Auth == nil || r.Auth.Mode == "" {
+		return AuthModeRequired
+	}
+	return r.Auth.Mode
+}
+
+// AcceptsAuth reports whether the route accepts the given auth method
+func (r *Route) AcceptsAuth(method string) bool {
+	if len(r.AuthMethods) == 0 {
+		return method == AuthMethodJWT
+	}
+	for _, m := range r.AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
 }
 
 // RoutesConfig holds all route configurations
 type RoutesConfig struct {
 	Routes []Route `yaml:"routes"`
+
+	// Version is the route store version these routes came from
+	// (0 when loaded from a file)
+	Version int64 `yaml:"-"`
 }
 
-// LoadRoutes reads route configuration from YAML file
-func LoadRoutes(path string) (*RoutesConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read routes file: %w", err)
-	}
+// Checksum identifies a route list by content: the hex SHA-256 of its JSON
+// form after defaults are applied, so equal tables on any instance match.
+func Checksum(routes []Route) string {
+	rc := RoutesConfig{Routes: append([]Route(nil), routes...)}
+	rc.applyDefaults()
+	data, _ := json.Marshal(rc.Routes)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
 
+// LoadRoutes strictly reads a routes file. Everything `gateway validate`
+// reports as an error fails the load, so the gateway never starts on routes
+// it would reject.
+func LoadRoutes(path string) (*RoutesConfig, error) {
 	var cfg RoutesConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse routes file: %w", err)
+	root, diags := decodeStrict(path, &cfg)
+	if root != nil {
+		diags = locate(path, root, append(diags, checkRoutesFile(cfg.Routes)...))
+	}
+	if err := diagnosticsError(diags); err != nil {
+		return nil, fmt.Errorf("invalid routes file: %w", err)
 	}
 
+	cfg.applyDefaults()
 	return &cfg, nil
 }
 
+// applyDefaults fills unset route values
+func (rc *RoutesConfig) applyDefaults() {
+	for i := range rc.Routes {
+		if rc.Routes[i].Timeout <= 0 {
+			rc.Routes[i].Timeout = DefaultRouteTimeout
+		}
+		if s := rc.Routes[i].Shadow; s != nil && s.Timeout <= 0 {
+			shadow := *s // Copy: the shadow may be shared with another table
+			shadow.Timeout = DefaultShadowTimeout
+			rc.Routes[i].Shadow = &shadow
+		}
+	}
+}
+
 // MatchRoute finds a route that matches the given path
 func (rc *RoutesConfig) MatchRoute(path string) *Route {
 	for i := range rc.Routes {
@@ -46,3 +473,53 @@ func (rc *RoutesConfig) MatchRoute(path string) *Route {
 	}
 	return nil
 }
+
+// RouteTable holds the live routes. Swap replaces them atomically. A
+// request is matched once and the result travels in its context (WithRoute),
+// so it keeps the route it matched even if the table changes mid-flight.
+type RouteTable struct {
+	current atomic.Pointer[RoutesConfig]
+}
+
+// NewRouteTable creates a table serving rc
+func NewRouteTable(rc *RoutesConfig) *RouteTable {
+	t := &RouteTable{}
+	t.Swap(rc)
+	return t
+}
+
+// Load returns the current routes. The result must not be modified.
+func (t *RouteTable) Load() *RoutesConfig {
+	return t.current.Load()
+}
+
+// Swap installs rc as the current routes after applying defaults
+func (t *RouteTable) Swap(rc *RoutesConfig) {
+	rc.applyDefaults()
+	t.current.Store(rc)
+}
+
+// MatchRoute finds a route in the current table
+func (t *RouteTable) MatchRoute(path string) *Route {
+	return t.Load().MatchRoute(path)
+}
+
+type routeKey struct{}
+
+// matchedRoute wraps the match so that "no route" can be stored as well
+type matchedRoute struct {
+	route *Route
+}
+
+// WithRoute records route, possibly nil, as the match of a request. Later
+// stages read it with RouteFromContext instead of matching again.
+func WithRoute(ctx context.Context, route *Route) context.Context {
+	return context.WithValue(ctx, routeKey{}, matchedRoute{route})
+}
+
+// RouteFromContext returns the route recorded by WithRoute; ok is false
+// if the request was not matched yet
+func RouteFromContext(ctx context.Context) (route *Route, ok bool) {
+	m, ok := ctx.Value(routeKey{}).(matchedRoute)
+	return m.route, ok
+}
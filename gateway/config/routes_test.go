@@ -1,9 +1,14 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadRoutes(t *testing.T) {
@@ -104,3 +109,200 @@ func TestMatchRoute(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteAcceptsAuth(t *testing.T) {
+	defaultRoute := &Route{PathPrefix: "/service-a"}
+	if !defaultRoute.AcceptsAuth(AuthMethodJWT) {
+		t.Error("Expected route without auth_methods to accept JWT")
+	}
+	if defaultRoute.AcceptsAuth(AuthMethodMTLS) {
+		t.Error("Expected route without auth_methods to reject mTLS")
+	}
+
+	either := &Route{PathPrefix: "/partner", AuthMethods: []string{AuthMethodJWT, AuthMethodMTLS}}
+	if !either.AcceptsAuth(AuthMethodJWT) || !either.AcceptsAuth(AuthMethodMTLS) {
+		t.Error("Expected route to accept both JWT and mTLS")
+	}
+
+	mtlsOnly := &Route{PathPrefix: "/partner", AuthMethods: []string{AuthMethodMTLS}}
+	if mtlsOnly.AcceptsAuth(AuthMethodJWT) {
+		t.Error("Expected mTLS-only route to reject JWT")
+	}
+
+	keys := &Route{PathPrefix: "/billing", AuthMethods: []string{AuthMethodJWT, AuthMethodAPIKey}}
+	if !keys.AcceptsAuth(AuthMethodAPIKey) || (&Route{}).AcceptsAuth(AuthMethodAPIKey) {
+		t.Error("Expected API keys only where listed")
+	}
+}
+
+func TestRouteAuthMode(t *testing.T) {
+	if mode := (&Route{}).AuthMode(); mode != AuthModeRequired {
+		t.Errorf("Expected required by default, got %s", mode)
+	}
+	if mode := (&Route{Auth: &RouteAuth{Issuer: "https://idp.example.com"}}).AuthMode(); mode != AuthModeRequired {
+		t.Errorf("Expected required without a mode, got %s", mode)
+	}
+	if mode := (&Route{Auth: &RouteAuth{Mode: AuthModeNone}}).AuthMode(); mode != AuthModeNone {
+		t.Errorf("Expected none, got %s", mode)
+	}
+}
+
+func TestRouteAuthorize(t *testing.T) {
+	auth := &RouteAuth{
+		AuthzRule: AuthzRule{Scopes: []string{"orders:read"}, AnyRoles: []string{"admin", "support"}},
+		Methods: map[string]AuthzRule{
+			"DELETE": {AllRoles: []string{"admin", "auditor"}},
+		},
+	}
+	tests := []struct {
+		name   string
+		method string
+		scopes []string
+		roles  []string
+		err    string
+	}{
+		{"allowed", "GET", []string{"orders:read"}, []string{"support"}, ""},
+		{"missing scope", "GET", nil, []string{"admin"}, "missing scope orders:read"},
+		{"no listed role", "GET", []string{"orders:read"}, []string{"viewer"}, "requires one of the roles admin, support"},
+		{"method rule", "DELETE", []string{"orders:read"}, []string{"admin"}, "DELETE: missing role auditor"},
+		{"method rule met", "DELETE", []string{"orders:read"}, []string{"admin", "auditor"}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := auth.Authorize(tc.method, tc.scopes, tc.roles)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Expected %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if !auth.HasRules() {
+		t.Error("Expected rules")
+	}
+	var none *RouteAuth
+	if none.HasRules() || none.Authorize("GET", nil, nil) != nil {
+		t.Error("Expected no rules without auth settings")
+	}
+	if (&RouteAuth{Issuer: "https://idp.example.com"}).HasRules() {
+		t.Error("Expected issuer alone not to count as a rule")
+	}
+}
+
+func TestRouteAuthYAMLAndJSON(t *testing.T) {
+	var route Route
+	err := yaml.Unmarshal([]byte(`
+path_prefix: /orders
+target: http://orders:8080
+auth:
+  scopes: [orders:read]
+  methods:
+    POST: {scopes: [orders:write]}
+`), &route)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if route.Auth == nil || !slices.Equal(route.Auth.Scopes, []string{"orders:read"}) || route.Auth.Methods["POST"].Scopes[0] != "orders:write" {
+		t.Fatalf("Expected inline rules, got %+v", route.Auth)
+	}
+
+	data, _ := json.Marshal(route.Auth)
+	if !strings.Contains(string(data), `"scopes":["orders:read"]`) {
+		t.Errorf("Expected flattened scopes in JSON, got %s", data)
+	}
+}
+
+func TestRouteJSONDuration(t *testing.T) {
+	route := Route{PathPrefix: "/api", Target: "http://api:8080", Timeout: 5 * time.Second}
+
+	data, err := json.Marshal(route)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"timeout":"5s"`) {
+		t.Errorf("Expected timeout as duration string, got %s", data)
+	}
+
+	var decoded Route
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Timeout != 5*time.Second || decoded.PathPrefix != "/api" {
+		t.Errorf("Expected round trip, got %+v", decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"timeout":"soon"}`), &decoded); err == nil {
+		t.Error("Expected error for invalid timeout")
+	}
+
+	route.Shadow = &Shadow{Target: "http://api-v2:8080", Percent: 10, Timeout: 2 * time.Second}
+	data, _ = json.Marshal(route)
+	if !strings.Contains(string(data), `"shadow":{"target":"http://api-v2:8080","percent":10,"timeout":"2s"}`) {
+		t.Errorf("Expected shadow timeout as duration string, got %s", data)
+	}
+	decoded = Route{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Shadow == nil || decoded.Shadow.Timeout != 2*time.Second {
+		t.Errorf("Expected shadow round trip, got %+v (%v)", decoded.Shadow, err)
+	}
+
+	route.Fault = &Fault{Delay: &FaultDelay{Duration: 1500 * time.Millisecond, Percent: 50}}
+	data, _ = json.Marshal(route)
+	if !strings.Contains(string(data), `"fault":{"delay":{"percent":50,"duration":"1.5s"}}`) {
+		t.Errorf("Expected fault delay as duration string, got %s", data)
+	}
+	decoded = Route{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Fault == nil || decoded.Fault.Delay.Duration != 1500*time.Millisecond {
+		t.Errorf("Expected fault round trip, got %+v (%v)", decoded.Fault, err)
+	}
+
+	route.Cache = &RouteCache{TTL: time.Minute, Key: CacheKey{UserID: true}}
+	data, _ = json.Marshal(route)
+	if !strings.Contains(string(data), `"ttl":"1m0s","stale_while_revalidate":"0s"`) {
+		t.Errorf("Expected cache durations as duration strings, got %s", data)
+	}
+	decoded = Route{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Cache == nil || decoded.Cache.TTL != time.Minute || !decoded.Cache.Key.UserID {
+		t.Errorf("Expected cache round trip, got %+v (%v)", decoded.Cache, err)
+	}
+}
+
+func TestRouteTableSwap(t *testing.T) {
+	table := NewRouteTable(&RoutesConfig{Routes: []Route{{PathPrefix: "/old", Target: "http://old:8080"}}})
+
+	before := table.MatchRoute("/old/items")
+	if before == nil {
+		t.Fatal("Expected /old to match")
+	}
+
+	table.Swap(&RoutesConfig{Routes: []Route{{PathPrefix: "/new", Target: "http://new:8080"}}})
+
+	if table.MatchRoute("/old/items") != nil {
+		t.Error("Expected /old to be gone after swap")
+	}
+	route := table.MatchRoute("/new/items")
+	if route == nil {
+		t.Fatal("Expected /new to match after swap")
+	}
+	if route.Timeout != DefaultRouteTimeout {
+		t.Errorf("Expected swap to apply default timeout, got %v", route.Timeout)
+	}
+	if before.Target != "http://old:8080" {
+		t.Error("Expected route matched before the swap to be unchanged")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	routes := []Route{{PathPrefix: "/api", Target: "http://api:8080"}}
+	withDefault := []Route{{PathPrefix: "/api", Target: "http://api:8080", Timeout: DefaultRouteTimeout}}
+
+	if Checksum(routes) != Checksum(withDefault) {
+		t.Error("Expected checksum to apply defaults before hashing")
+	}
+	if routes[0].Timeout != 0 {
+		t.Error("Expected Checksum not to modify its input")
+	}
+
+	changed := []Route{{PathPrefix: "/api", Target: "http://api-v2:8080"}}
+	if Checksum(routes) == Checksum(changed) {
+		t.Error("Expected different routes to have different checksums")
+	}
+}
@@ -0,0 +1,509 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is one problem found while validating a config file
+type Diagnostic struct {
+	File     string
+	Line     int // 0 when the location is unknown
+	Severity string
+	Field    string // Dotted path, e.g. routes[1].timeout
+	Message  string
+}
+
+// String formats the diagnostic as "file:line: severity: field: message"
+func (d Diagnostic) String() string {
+	loc := d.File
+	if d.Line > 0 {
+		loc += ":" + strconv.Itoa(d.Line)
+	}
+	if d.Field != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", loc, d.Severity, d.Field, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, d.Severity, d.Message)
+}
+
+// HasErrors reports whether any diagnostic is an error
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateConfigFile strictly decodes a gateway config file and checks its
+// settings and inline routes. The decoded config is returned when the file
+// parses, so callers can go on to validate an included routes file.
+func ValidateConfigFile(path string) (*Config, []Diagnostic) {
+	cfg := Defaults()
+	root, diags := decodeStrict(path, cfg)
+	if root == nil {
+		return nil, diags
+	}
+
+	for _, err := range cfg.settingsErrors() {
+		field, msg, _ := strings.Cut(err.Error(), ": ")
+		diags = append(diags, Diagnostic{Severity: SeverityError, Field: field, Message: msg})
+	}
+	diags = append(diags, CheckRoutes(cfg.Routes)...)
+	return cfg, locate(path, root, diags)
+}
+
+// ValidateRoutesFile strictly decodes a routes file and checks every route
+func ValidateRoutesFile(path string) []Diagnostic {
+	var rc RoutesConfig
+	root, diags := decodeStrict(path, &rc)
+	if root == nil {
+		return diags
+	}
+	return locate(path, root, append(diags, checkRoutesFile(rc.Routes)...))
+}
+
+// checkRoutesFile is CheckRoutes plus the rules for a standalone routes file
+func checkRoutesFile(routes []Route) []Diagnostic {
+	var diags []Diagnostic
+	if len(routes) == 0 {
+		diags = append(diags, Diagnostic{Severity: SeverityError, Field: "routes", Message: "no routes defined"})
+	}
+	return append(diags, CheckRoutes(routes)...)
+}
+
+// diagnosticsError joins the errors among diags, or returns nil
+func diagnosticsError(diags []Diagnostic) error {
+	var errs []error
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			errs = append(errs, errors.New(d.String()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckRoutes validates route values and ordering. Routes match by prefix
+// in file order, so a route whose prefix extends an earlier one is never
+// reached.
+func CheckRoutes(routes []Route) []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity string, i int, field, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Severity: severity,
+			Field:    fmt.Sprintf("routes[%d].%s", i, field),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	seen := make(map[string]int)
+	for i, r := range routes {
+		if r.PathPrefix == "" {
+			add(SeverityError, i, "path_prefix", "required")
+		} else if !strings.HasPrefix(r.PathPrefix, "/") {
+			add(SeverityError, i, "path_prefix", "must start with / (got %q)", r.PathPrefix)
+		}
+
+		if u, err := url.Parse(r.Target); r.Target == "" {
+			add(SeverityError, i, "target", "required")
+		} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(SeverityError, i, "target", "must be an absolute http(s) URL like http://service-a:6000 (got %q)", r.Target)
+		} else if u.Path != "" && u.Path != "/" {
+			add(SeverityWarning, i, "target", "path %q is prepended to every forwarded path", u.Path)
+		}
+
+		if r.Timeout < 0 {
+			add(SeverityError, i, "timeout", "must be positive (got %s)", r.Timeout)
+		} else if r.Timeout == 0 {
+			add(SeverityWarning, i, "timeout", "not set; defaults to %s", DefaultRouteTimeout)
+		}
+
+		for _, m := range r.AuthMethods {
+			if m != AuthMethodJWT && m != AuthMethodMTLS && m != AuthMethodAPIKey {
+				add(SeverityError, i, "auth_methods", "unknown method %q (use %s, %s or %s)", m, AuthMethodJWT, AuthMethodMTLS, AuthMethodAPIKey)
+			}
+		}
+
+		if a := r.Auth; a != nil {
+			switch a.Mode {
+			case "", AuthModeRequired, AuthModeOptional:
+			case AuthModeNone:
+				if a.Issuer != "" || len(a.Audience) > 0 || len(a.ClaimHeaders) > 0 || a.HasRules() || len(r.AuthMethods) > 0 {
+					add(SeverityWarning, i, "auth.mode", "none ignores credentials, so the other auth settings have no effect")
+				}
+			default:
+				add(SeverityError, i, "auth.mode", "unknown mode %q (use %s, %s or %s)", a.Mode, AuthModeRequired, AuthModeOptional, AuthModeNone)
+			}
+			for _, aud := range a.Audience {
+				if strings.TrimSpace(aud) == "" {
+					add(SeverityError, i, "auth.audience", "audience names must not be empty")
+				}
+			}
+			headers := make(map[string]bool)
+			for _, ch := range a.ClaimHeaders {
+				name := http.CanonicalHeaderKey(ch.Header)
+				switch {
+				case strings.TrimSpace(ch.Claim) == "":
+					add(SeverityError, i, "auth.claim_headers", "claim is required")
+				case !headerNameRe.MatchString(ch.Header):
+					add(SeverityError, i, "auth.claim_headers", "invalid header name %q", ch.Header)
+				case reservedHeaders[name]:
+					add(SeverityError, i, "auth.claim_headers", "header %s is set by the gateway or the connection", name)
+				case headers[name]:
+					add(SeverityError, i, "auth.claim_headers", "header %s is mapped twice", name)
+				}
+				headers[name] = true
+			}
+			for _, d := range checkAuthzRule(a.AuthzRule) {
+				add(d.Severity, i, "auth"+d.Field, "%s", d.Message)
+			}
+			for _, method := range slices.Sorted(maps.Keys(a.Methods)) {
+				rule := a.Methods[method]
+				if !httpMethods[method] {
+					add(SeverityError, i, "auth.methods", "unknown method %q (use upper case, e.g. POST)", method)
+				}
+				for _, d := range checkAuthzRule(rule) {
+					add(d.Severity, i, "auth.methods."+method+d.Field, "%s", d.Message)
+				}
+			}
+			jwtOnly := a.Issuer != "" || len(a.Audience) > 0 || len(a.ClaimHeaders) > 0
+			if jwtOnly && !r.AcceptsAuth(AuthMethodJWT) {
+				add(SeverityWarning, i, "auth", "issuer, audience and claim_headers only apply to JWTs, but the route does not accept jwt")
+			}
+			if a.HasRules() && r.AcceptsAuth(AuthMethodMTLS) {
+				add(SeverityWarning, i, "auth", "client certificates carry no scopes or roles, so mTLS callers are forbidden")
+			}
+		}
+
+		if s := r.Shadow; s != nil {
+			if u, err := url.Parse(s.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add(SeverityError, i, "shadow.target", "must be an absolute http(s) URL (got %q)", s.Target)
+			}
+			if s.Percent <= 0 || s.Percent > 100 {
+				add(SeverityError, i, "shadow.percent", "must be greater than 0 and at most 100 (got %g)", s.Percent)
+			}
+			if s.Timeout < 0 {
+				add(SeverityError, i, "shadow.timeout", "must be positive (got %s)", s.Timeout)
+			}
+			if c := s.Compare; c != nil {
+				for _, h := range c.Headers {
+					if strings.TrimSpace(h) == "" {
+						add(SeverityError, i, "shadow.compare.headers", "header names must not be empty")
+					}
+				}
+				for _, p := range c.IgnorePaths {
+					if !ignorePathRe.MatchString(p) {
+						add(SeverityError, i, "shadow.compare.ignore_paths", "invalid path %q (use e.g. meta.timestamp or items[*].id)", p)
+					}
+				}
+			}
+		}
+
+		if r.Split != nil {
+			for _, d := range checkSplit(r.Split) {
+				add(d.Severity, i, "split"+d.Field, "%s", d.Message)
+			}
+		}
+
+		if c := r.Cache; c != nil {
+			if c.TTL < 0 {
+				add(SeverityError, i, "cache.ttl", "must not be negative (got %s)", c.TTL)
+			}
+			if c.StaleWhileRevalidate < 0 {
+				add(SeverityError, i, "cache.stale_while_revalidate", "must not be negative (got %s)", c.StaleWhileRevalidate)
+			}
+			for _, h := range c.Key.Headers {
+				if strings.TrimSpace(h) == "" {
+					add(SeverityError, i, "cache.key.headers", "header names must not be empty")
+				}
+			}
+			if r.AuthMode() != AuthModeNone && !c.Key.PerCaller() {
+				add(SeverityWarning, i, "cache.key", "authenticated responses are cached only with Cache-Control: public or s-maxage; set user_id or client_id to cache them per caller")
+			}
+		}
+
+		if c := r.Coalesce; c != nil {
+			for _, h := range c.Headers {
+				if strings.TrimSpace(h) == "" {
+					add(SeverityError, i, "coalesce.headers", "header names must not be empty")
+				}
+			}
+		}
+
+		if r.Fault != nil {
+			for _, d := range checkFault(r.Fault) {
+				add(d.Severity, i, "fault"+d.Field, "%s", d.Message)
+			}
+		}
+
+		if r.PathPrefix == "" {
+			continue
+		}
+		if j, dup := seen[r.PathPrefix]; dup {
+			add(SeverityError, i, "path_prefix", "duplicate of routes[%d] (%q)", j, r.PathPrefix)
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if p := routes[j].PathPrefix; p != "" && strings.HasPrefix(r.PathPrefix, p) {
+				add(SeverityWarning, i, "path_prefix", "shadowed by routes[%d] (%q) and never matched; move it before that route", j, p)
+				break
+			}
+		}
+		seen[r.PathPrefix] = i
+	}
+	return diags
+}
+
+// checkSplit validates a traffic split. Fields are relative to the split
+// (e.g. ".groups[1].weight").
+func checkSplit(split *TrafficSplit) []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity, field, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch split.Sticky {
+	case StickyNone, StickyClientID, StickyCookie:
+	default:
+		add(SeverityError, ".sticky", "unknown mode %q (use %s or %s)", split.Sticky, StickyClientID, StickyCookie)
+	}
+	if len(split.Groups) == 0 {
+		add(SeverityError, ".groups", "at least one group is required")
+	}
+
+	names := make(map[string]bool)
+	total := 0
+	for g, group := range split.Groups {
+		field := fmt.Sprintf(".groups[%d]", g)
+		if group.Name == "" {
+			add(SeverityError, field+".name", "required")
+		} else if names[group.Name] {
+			add(SeverityError, field+".name", "duplicate group %q", group.Name)
+		}
+		names[group.Name] = true
+
+		if group.Weight < 0 {
+			add(SeverityError, field+".weight", "must not be negative (got %d)", group.Weight)
+		}
+		total += max(group.Weight, 0)
+
+		if group.Target != "" {
+			if u, err := url.Parse(group.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add(SeverityError, field+".target", "must be an absolute http(s) URL (got %q)", group.Target)
+			}
+		}
+		if group.Override != "" && split.OverrideHeader == "" {
+			add(SeverityError, field+".override", "requires split.override_header")
+		}
+	}
+	if len(split.Groups) > 0 && total == 0 {
+		add(SeverityError, ".groups", "weights must add up to more than 0")
+	}
+	return diags
+}
+
+// checkFault validates fault injection. Fields are relative to the fault
+// (e.g. ".abort.status").
+func checkFault(fault *Fault) []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity, field, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	percent := func(field string, p float64) {
+		if p <= 0 || p > 100 {
+			add(SeverityError, field+".percent", "must be greater than 0 and at most 100 (got %g)", p)
+		}
+	}
+
+	if fault.Delay == nil && fault.Abort == nil && fault.Reset == nil {
+		add(SeverityError, "", "at least one of delay, abort or reset is required")
+	}
+	if d := fault.Delay; d != nil {
+		if d.Duration <= 0 {
+			add(SeverityError, ".delay.duration", "must be positive (got %s)", d.Duration)
+		}
+		percent(".delay", d.Percent)
+	}
+	if a := fault.Abort; a != nil {
+		if a.Status < 400 || a.Status > 599 {
+			add(SeverityError, ".abort.status", "must be a 4xx or 5xx status (got %d)", a.Status)
+		}
+		percent(".abort", a.Percent)
+	}
+	if r := fault.Reset; r != nil {
+		percent(".reset", r.Percent)
+	}
+
+	for _, id := range fault.ClientIDs {
+		if strings.TrimSpace(id) == "" {
+			add(SeverityError, ".client_ids", "client IDs must not be empty")
+		}
+	}
+	if fault.Header == "" && len(fault.ClientIDs) == 0 {
+		add(SeverityWarning, "", "no header or client_ids set; faults apply to all requests")
+	}
+	return diags
+}
+
+// checkAuthzRule reports empty scope and role names
+func checkAuthzRule(rule AuthzRule) []Diagnostic {
+	var diags []Diagnostic
+	blank := func(n string) bool { return strings.TrimSpace(n) == "" }
+	for _, f := range []struct {
+		field string
+		names []string
+	}{{".scopes", rule.Scopes}, {".any_roles", rule.AnyRoles}, {".all_roles", rule.AllRoles}} {
+		if slices.ContainsFunc(f.names, blank) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Field: f.field, Message: "names must not be empty"})
+		}
+	}
+	return diags
+}
+
+// httpMethods are the methods authorization rules can be set for
+var httpMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// ignorePathRe matches JSON paths like data.items[*].id or [0].name
+var ignorePathRe = regexp.MustCompile(`^(\[(\*|\d+)\]|[^.\[\]]+)(\.[^.\[\]]+|\[(\*|\d+)\])*$`)
+
+// headerNameRe matches HTTP header field names (RFC 9110 tokens)
+var headerNameRe = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// reservedHeaders cannot carry claims: the gateway sets them itself or they
+// control the connection and message framing
+var reservedHeaders = map[string]bool{
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Cookie":            true,
+	"Host":              true,
+	"Te":                true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"X-Client-Id":       true,
+	"X-Request-Id":      true,
+	"X-User-Id":         true,
+}
+
+var lineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// decodeStrict decodes path into out rejecting unknown fields and returns
+// the node tree for locating diagnostics. A nil node means parsing failed.
+func decodeStrict(path string, out interface{}) (*yaml.Node, []Diagnostic) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []Diagnostic{{File: path, Severity: SeverityError, Message: err.Error()}}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlDiagnostics(path, err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && err != io.EOF {
+		return &root, yamlDiagnostics(path, err)
+	}
+	return &root, nil
+}
+
+// yamlDiagnostics converts yaml errors ("line 7: field adress not found in
+// type config.RedisConfig") into diagnostics
+func yamlDiagnostics(path string, err error) []Diagnostic {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+
+	diags := make([]Diagnostic, 0, len(msgs))
+	for _, msg := range msgs {
+		d := Diagnostic{File: path, Severity: SeverityError, Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := lineRe.FindStringSubmatch(msg); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Message = m[2]
+		}
+		if strings.HasPrefix(d.Message, "field ") && strings.Contains(d.Message, " not found in type ") {
+			name := strings.TrimPrefix(d.Message[:strings.Index(d.Message, " not found")], "field ")
+			d.Message = fmt.Sprintf("unknown field %q (check spelling)", name)
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// locate fills in file and line for diagnostics whose field path exists,
+// then orders them by line
+func locate(path string, root *yaml.Node, diags []Diagnostic) []Diagnostic {
+	for i := range diags {
+		diags[i].File = path
+		if diags[i].Line == 0 && diags[i].Field != "" {
+			diags[i].Line = findLine(root, diags[i].Field)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Line < diags[j].Line })
+	return diags
+}
+
+var segmentRe = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)\]`)
+
+// findLine walks a path like routes[1].timeout and returns the line of the
+// deepest node found
+func findLine(root *yaml.Node, field string) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+
+	for _, m := range segmentRe.FindAllStringSubmatch(field, -1) {
+		var next *yaml.Node
+		switch {
+		case m[2] != "" && node.Kind == yaml.SequenceNode:
+			if idx, _ := strconv.Atoi(m[2]); idx < len(node.Content) {
+				next = node.Content[idx]
+			}
+		case m[1] != "" && node.Kind == yaml.MappingNode:
+			for k := 0; k+1 < len(node.Content); k += 2 {
+				if node.Content[k].Value == m[1] {
+					next = node.Content[k+1]
+					line = node.Content[k].Line
+					break
+				}
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+		if node.Kind != yaml.ScalarNode {
+			line = node.Line
+		}
+	}
+	return line
+}
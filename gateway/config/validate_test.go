@@ -0,0 +1,364 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRoutes(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write routes: %v", err)
+	}
+	return path
+}
+
+// findDiag returns the first diagnostic for a field containing substr
+func findDiag(diags []Diagnostic, field, substr string) *Diagnostic {
+	for i := range diags {
+		if diags[i].Field == field && strings.Contains(diags[i].Message, substr) {
+			return &diags[i]
+		}
+	}
+	return nil
+}
+
+func TestValidateRoutesFileValid(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    strip_prefix: true
+    timeout: 5s
+`)
+	if diags := ValidateRoutesFile(path); len(diags) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestValidateRoutesFileUnknownField(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    stip_prefix: true
+    timeout: 5s
+`)
+	diags := ValidateRoutesFile(path)
+	if !HasErrors(diags) {
+		t.Fatal("Expected error for unknown field")
+	}
+	if diags[0].Line != 4 || !strings.Contains(diags[0].Message, "stip_prefix") {
+		t.Errorf("Expected unknown field on line 4, got %s", diags[0])
+	}
+}
+
+func TestValidateRoutesFileChecks(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    timeout: 5s
+  - path_prefix: "/service-a/v2"
+    target: "service-b:6001"
+  - path_prefix: "/service-a"
+    target: "http://service-c:6002"
+    timeout: -1s
+`)
+	diags := ValidateRoutesFile(path)
+
+	tests := []struct {
+		field, substr, severity string
+		line                    int
+	}{
+		{"routes[1].target", "absolute http(s) URL", SeverityError, 6},
+		{"routes[1].timeout", "defaults to", SeverityWarning, 5},
+		{"routes[1].path_prefix", "shadowed by routes[0]", SeverityWarning, 5},
+		{"routes[2].path_prefix", "duplicate of routes[0]", SeverityError, 7},
+		{"routes[2].timeout", "must be positive", SeverityError, 9},
+	}
+	for _, tc := range tests {
+		d := findDiag(diags, tc.field, tc.substr)
+		if d == nil {
+			t.Errorf("Expected %s diagnostic for %s, got %v", tc.substr, tc.field, diags)
+			continue
+		}
+		if d.Severity != tc.severity || d.Line != tc.line {
+			t.Errorf("Expected %s on line %d, got %s", tc.severity, tc.line, d)
+		}
+	}
+}
+
+func TestValidateRoutesFileSplit(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    timeout: 5s
+    split:
+      sticky: session
+      groups:
+        - name: stable
+          weight: 95
+        - name: stable
+          target: "service-b-v2"
+          weight: -5
+          override: "true"
+`)
+	diags := ValidateRoutesFile(path)
+
+	tests := []struct {
+		field, substr string
+		line          int
+	}{
+		{"routes[0].split.sticky", "unknown mode", 6},
+		{"routes[0].split.groups[1].name", "duplicate group", 10},
+		{"routes[0].split.groups[1].target", "absolute http(s) URL", 11},
+		{"routes[0].split.groups[1].weight", "must not be negative", 12},
+		{"routes[0].split.groups[1].override", "requires split.override_header", 13},
+	}
+	for _, tc := range tests {
+		d := findDiag(diags, tc.field, tc.substr)
+		if d == nil {
+			t.Errorf("Expected %s diagnostic for %s, got %v", tc.substr, tc.field, diags)
+			continue
+		}
+		if d.Severity != SeverityError || d.Line != tc.line {
+			t.Errorf("Expected error on line %d, got %s", tc.line, d)
+		}
+	}
+}
+
+func TestValidateRoutesFileShadowCompare(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    shadow:
+      target: "http://service-b-next:6001"
+      percent: 10
+      compare:
+        headers: [""]
+        ignore_paths: ["meta.timestamp", "items[*].id", "items..id"]
+`)
+	diags := ValidateRoutesFile(path)
+
+	if d := findDiag(diags, "routes[0].shadow.compare.headers", "must not be empty"); d == nil || d.Line != 8 {
+		t.Errorf("Expected headers error on line 8, got %v", diags)
+	}
+	d := findDiag(diags, "routes[0].shadow.compare.ignore_paths", `"items..id"`)
+	if d == nil || d.Line != 9 {
+		t.Errorf("Expected ignore_paths error on line 9, got %v", diags)
+	}
+	if findDiag(diags, "routes[0].shadow.compare.ignore_paths", `"items[*].id"`) != nil {
+		t.Error("Expected items[*].id to be accepted")
+	}
+}
+
+func TestValidateRoutesFileFault(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    timeout: 5s
+    fault:
+      delay:
+        duration: 0s
+        percent: 50
+      abort:
+        status: 200
+        percent: 150
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    timeout: 5s
+    fault:
+      header: X-Fault-Inject
+`)
+	diags := ValidateRoutesFile(path)
+
+	tests := []struct {
+		field, substr, severity string
+		line                    int
+	}{
+		{"routes[0].fault", "apply to all requests", SeverityWarning, 6},
+		{"routes[0].fault.delay.duration", "must be positive", SeverityError, 7},
+		{"routes[0].fault.abort.status", "4xx or 5xx", SeverityError, 10},
+		{"routes[0].fault.abort.percent", "at most 100", SeverityError, 11},
+		{"routes[1].fault", "at least one of delay, abort or reset", SeverityError, 16},
+	}
+	for _, tc := range tests {
+		d := findDiag(diags, tc.field, tc.substr)
+		if d == nil {
+			t.Errorf("Expected %s diagnostic for %s, got %v", tc.substr, tc.field, diags)
+			continue
+		}
+		if d.Severity != tc.severity || d.Line != tc.line {
+			t.Errorf("Expected %s on line %d, got %s", tc.severity, tc.line, d)
+		}
+	}
+}
+
+func TestValidateRoutesFileCoalesce(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    timeout: 5s
+    coalesce:
+      headers: ["Accept-Language", ""]
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    timeout: 5s
+    coalesce:
+      headers: ["x-user-id"]
+`)
+	diags := ValidateRoutesFile(path)
+
+	if d := findDiag(diags, "routes[0].coalesce.headers", "must not be empty"); d == nil || d.Line != 6 {
+		t.Errorf("Expected headers error on line 6, got %v", diags)
+	}
+	// Authenticated callers never share responses, so no warning is needed
+	if findDiag(diags, "routes[0].coalesce", "shared between callers") != nil {
+		t.Error("Expected no shared response warning")
+	}
+}
+
+func TestValidateRoutesFileCacheAuth(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    timeout: 5s
+    cache:
+      ttl: 1m
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    timeout: 5s
+    cache:
+      ttl: 1m
+      key:
+        user_id: true
+  - path_prefix: "/public"
+    target: "http://public:6002"
+    timeout: 5s
+    auth:
+      mode: none
+    cache:
+      ttl: 1m
+`)
+	diags := ValidateRoutesFile(path)
+
+	if d := findDiag(diags, "routes[0].cache.key", "authenticated responses"); d == nil || d.Severity != SeverityWarning {
+		t.Errorf("Expected shared authenticated cache warning, got %v", diags)
+	}
+	if findDiag(diags, "routes[1].cache.key", "authenticated responses") != nil {
+		t.Error("Expected no warning when entries are keyed by user")
+	}
+	if findDiag(diags, "routes[2].cache.key", "authenticated responses") != nil {
+		t.Error("Expected no warning for routes without auth")
+	}
+}
+
+func TestValidateRoutesFileAuth(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+    timeout: 5s
+    auth:
+      issuer: "https://partner.example.com"
+      audience: ["orders", ""]
+      claim_headers:
+        - {claim: tenant, header: X-Tenant-ID}
+        - {claim: org, header: x-tenant-id}
+        - {claim: sub, header: X-User-ID}
+        - {claim: plan, header: "X Plan"}
+        - {claim: "", header: X-Roles}
+      scopes: [""]
+      methods:
+        post: {scopes: [orders:write]}
+        DELETE: {any_roles: [admin, " "]}
+  - path_prefix: "/service-b"
+    target: "http://service-b:6001"
+    timeout: 5s
+    auth_methods: [mtls]
+    auth:
+      audience: ["billing"]
+  - path_prefix: "/service-c"
+    target: "http://service-c:6002"
+    timeout: 5s
+    auth_methods: [jwt, mtls]
+    auth:
+      any_roles: [admin]
+  - path_prefix: "/public"
+    target: "http://public:8000"
+    timeout: 5s
+    auth:
+      mode: anonymous
+  - path_prefix: "/status"
+    target: "http://status:8000"
+    timeout: 5s
+    auth:
+      mode: none
+      scopes: [status:read]
+`)
+	diags := ValidateRoutesFile(path)
+
+	if d := findDiag(diags, "routes[0].auth.audience", "must not be empty"); d == nil || d.Line != 7 {
+		t.Errorf("Expected audience error on line 7, got %v", diags)
+	}
+	for _, msg := range []string{"mapped twice", "set by the gateway", "invalid header name", "claim is required"} {
+		if findDiag(diags, "routes[0].auth.claim_headers", msg) == nil {
+			t.Errorf("Expected claim_headers error %q, got %v", msg, diags)
+		}
+	}
+	if findDiag(diags, "routes[0].auth.scopes", "must not be empty") == nil {
+		t.Errorf("Expected empty scope error, got %v", diags)
+	}
+	if findDiag(diags, "routes[0].auth.methods", `unknown method "post"`) == nil {
+		t.Errorf("Expected lower case method error, got %v", diags)
+	}
+	if findDiag(diags, "routes[0].auth.methods.DELETE.any_roles", "must not be empty") == nil {
+		t.Errorf("Expected empty role error, got %v", diags)
+	}
+	if d := findDiag(diags, "routes[1].auth", "does not accept jwt"); d == nil || d.Severity != SeverityWarning {
+		t.Errorf("Expected warning for auth on an mTLS-only route, got %v", diags)
+	}
+	if d := findDiag(diags, "routes[2].auth", "mTLS callers are forbidden"); d == nil || d.Severity != SeverityWarning {
+		t.Errorf("Expected warning for roles on an mTLS route, got %v", diags)
+	}
+	if d := findDiag(diags, "routes[3].auth.mode", `unknown mode "anonymous"`); d == nil || d.Line != 34 {
+		t.Errorf("Expected mode error on line 34, got %v", diags)
+	}
+	if d := findDiag(diags, "routes[4].auth.mode", "no effect"); d == nil || d.Severity != SeverityWarning {
+		t.Errorf("Expected warning for rules on a public route, got %v", diags)
+	}
+}
+
+func TestValidateConfigFileLocatesSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	os.WriteFile(path, []byte(`server:
+  port: 70000
+rate_limit:
+  limit: 0
+`), 0600)
+
+	cfg, diags := ValidateConfigFile(path)
+	if cfg == nil {
+		t.Fatal("Expected config to be decoded")
+	}
+	if d := findDiag(diags, "server.port", "out of range"); d == nil || d.Line != 2 {
+		t.Errorf("Expected server.port error on line 2, got %v", diags)
+	}
+	if d := findDiag(diags, "rate_limit.limit", "must be positive"); d == nil || d.Line != 4 {
+		t.Errorf("Expected rate_limit.limit error on line 4, got %v", diags)
+	}
+}
+
+func TestLoadRoutesDefaultsTimeout(t *testing.T) {
+	path := writeRoutes(t, `routes:
+  - path_prefix: "/service-a"
+    target: "http://service-a:6000"
+`)
+	routes, err := LoadRoutes(path)
+	if err != nil {
+		t.Fatalf("LoadRoutes failed: %v", err)
+	}
+	if routes.Routes[0].Timeout != DefaultRouteTimeout {
+		t.Errorf("Expected default timeout %v, got %v", DefaultRouteTimeout, routes.Routes[0].Timeout)
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/pkg/apikey"
+)
+
+// apiKeyRequest describes a new API key
+type apiKeyRequest struct {
+	ClientID  string     `json:"client_id"`
+	Owner     string     `json:"owner"`
+	Scopes    []string   `json:"scopes"`
+	Plan      string     `json:"plan"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Enabled   *bool      `json:"enabled"` // Defaults to true
+}
+
+// APIKeysAdminHandler serves /admin/apikeys:
+//
+//	GET              all keys, or one with ?id=
+//	POST             create a key; the response holds the key, shown only once
+//	PATCH  ?id=      enable or disable a key: {"enabled": false}
+//	DELETE ?id=      revoke a key for good
+//
+// Keys are stored in Redis; other instances see changes within
+// api_keys.cache_ttl.
+func APIKeysAdminHandler(store *apikey.Store, keyAuth *apikey.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if r.Method != http.MethodGet && r.Method != http.MethodPost && id == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "id query parameter is required")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if id != "" {
+				key, err := store.Get(r.Context(), id)
+				if err != nil {
+					writeAPIKeyError(w, r, err)
+					return
+				}
+				writeJSON(w, http.StatusOK, key)
+				return
+			}
+			keys, err := store.List(r.Context())
+			if err != nil {
+				writeAPIKeyError(w, r, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+
+		case http.MethodPost:
+			var req apiKeyRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid API key request: "+err.Error())
+				return
+			}
+			if msg := req.validate(); msg != "" {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", msg)
+				return
+			}
+			meta := apikey.Key{
+				ClientID:  req.ClientID,
+				Owner:     req.Owner,
+				Scopes:    req.Scopes,
+				Plan:      req.Plan,
+				ExpiresAt: req.ExpiresAt,
+				Enabled:   req.Enabled == nil || *req.Enabled,
+			}
+			token, key, err := store.Create(r.Context(), meta)
+			if err != nil {
+				writeAPIKeyError(w, r, err)
+				return
+			}
+			log.Printf("API key %s for client %s created by %s", key.ID, key.ClientID, adminUser(r))
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"key": token, "api_key": key})
+
+		case http.MethodPatch:
+			var req struct {
+				Enabled *bool `json:"enabled"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", `body must be {"enabled": true|false}`)
+				return
+			}
+			key, err := store.SetEnabled(r.Context(), id, *req.Enabled)
+			if err != nil {
+				writeAPIKeyError(w, r, err)
+				return
+			}
+			keyAuth.Invalidate(id)
+			log.Printf("API key %s enabled=%t by %s", id, *req.Enabled, adminUser(r))
+			writeJSON(w, http.StatusOK, key)
+
+		case http.MethodDelete:
+			if err := store.Delete(r.Context(), id); err != nil {
+				writeAPIKeyError(w, r, err)
+				return
+			}
+			keyAuth.Invalidate(id)
+			log.Printf("API key %s revoked by %s", id, adminUser(r))
+			writeJSON(w, http.StatusOK, map[string]interface{}{"revoked": id})
+
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET, POST, PATCH or DELETE")
+		}
+	}
+}
+
+// APIKeysRotateHandler serves POST /admin/apikeys/rotate?id=ID&grace=1h.
+// The new key is returned once; the old one keeps working for grace
+// (default 0: it stops at once).
+func APIKeysRotateHandler(store *apikey.Store, keyAuth *apikey.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use POST")
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "id query parameter is required")
+			return
+		}
+		var grace time.Duration
+		if v := r.URL.Query().Get("grace"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "grace must be a duration like 1h")
+				return
+			}
+			grace = d
+		}
+
+		token, key, err := store.Rotate(r.Context(), id, grace)
+		if err != nil {
+			writeAPIKeyError(w, r, err)
+			return
+		}
+		keyAuth.Invalidate(id)
+		log.Printf("API key %s rotated by %s (grace %s)", id, adminUser(r), grace)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": token, "api_key": key})
+	}
+}
+
+// validate returns a message for the first invalid field, or ""
+func (req *apiKeyRequest) validate() string {
+	if !headerSafe(req.ClientID) {
+		return "client_id is required and must be printable ASCII"
+	}
+	if !headerSafe(req.Owner) {
+		return "owner is required and must be printable ASCII"
+	}
+	for _, s := range req.Scopes {
+		if strings.TrimSpace(s) == "" {
+			return "scopes must not be empty"
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return "expires_at must be in the future"
+	}
+	return ""
+}
+
+// headerSafe reports whether s can be sent as X-Client-ID or X-User-ID
+func headerSafe(s string) bool {
+	if s == "" || len(s) > 256 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case apikey.ErrNotFound:
+		writeError(w, r, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case apikey.ErrConflict:
+		writeError(w, r, http.StatusConflict, "CONFLICT", err.Error())
+	default:
+		writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+	}
+}
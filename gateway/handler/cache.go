@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/observability"
+	"github.com/distributed-api-gateway/gateway/pkg/cache"
+	"github.com/distributed-api-gateway/gateway/pkg/trace"
+	"github.com/distributed-api-gateway/gateway/proxy"
+)
+
+// Cache lookup results, the result label of gateway_cache_requests_total
+const (
+	CacheHit         = "hit"         // Fresh entry served
+	CacheStale       = "stale"       // Stale entry served while refreshing
+	CacheRevalidated = "revalidated" // Expired entry confirmed by the upstream (304)
+	CacheMiss        = "miss"        // Forwarded; the response may be stored
+	CacheBypass      = "bypass"      // Request asked not to be served from cache
+	CacheError       = "error"       // Redis failed; forwarded without caching
+)
+
+// CacheHeader tells clients how the gateway cache answered
+const CacheHeader = "X-Cache"
+
+// ResponseCache serves GET requests of routes with a cache block from Redis
+type ResponseCache struct {
+	store     *cache.Store
+	forwarder *proxy.Forwarder
+	maxBody   int64
+	now       func() time.Time
+}
+
+// NewResponseCache caches responses up to maxBody bytes. forwarder
+// refreshes stale entries in the background.
+func NewResponseCache(store *cache.Store, forwarder *proxy.Forwarder, maxBody int64) *ResponseCache {
+	return &ResponseCache{store: store, forwarder: forwarder, maxBody: maxBody, now: time.Now}
+}
+
+// cacheLookup carries the cache state of a forwarded request
+type cacheLookup struct {
+	cache   *ResponseCache
+	route   *config.Route
+	service string
+	key     string
+	result  string
+	entry   *cache.Entry // Expired entry being revalidated
+	noStore bool
+	writer  *cacheWriter
+}
+
+// Lookup answers r from the cache if it can. Otherwise it returns the
+// state needed to store the upstream response (nil when the route or
+// method is not cached). route is the route with the target of the split
+// group chosen for r, if any; entries are kept apart per group.
+func (c *ResponseCache) Lookup(w http.ResponseWriter, r *http.Request, route *config.Route, group string) (*cacheLookup, bool) {
+	if c == nil || route.Cache == nil || r.Method != http.MethodGet {
+		return nil, false
+	}
+	start := time.Now()
+	l := &cacheLookup{cache: c, route: route, service: route.PathPrefix, key: cache.Key(route, r, group), result: CacheMiss}
+
+	skipLookup, skipStore := cache.Bypass(r.Header)
+	if skipLookup {
+		l.result, l.noStore = CacheBypass, skipStore
+		l.trace(r.Context(), trace.StatusSkipped, time.Since(start), nil)
+		return l, false
+	}
+
+	entry, err := c.store.Get(r.Context(), l.key, r.Header)
+	if err != nil {
+		log.Printf("Cache lookup failed: %v", err)
+		l.result, l.noStore = CacheError, true
+		l.trace(r.Context(), trace.StatusFailed, time.Since(start), nil)
+		return l, false
+	}
+
+	now := c.now()
+	switch {
+	case entry == nil:
+	case entry.IsFresh(now):
+		l.result = CacheHit
+	case entry.ServeStale(now):
+		l.result = CacheStale
+	case entry.HasValidators():
+		l.entry = entry // Revalidated by the forwarded request
+	}
+
+	if l.result == CacheHit || l.result == CacheStale {
+		l.trace(r.Context(), trace.StatusSuccess, time.Since(start), entry)
+		l.record()
+		serveEntry(w, r, entry, l.result, now)
+		if l.result == CacheStale {
+			go c.refresh(r.Clone(context.WithoutCancel(r.Context())), route, l.key, entry)
+		}
+		return nil, true
+	}
+	l.trace(r.Context(), trace.StatusSkipped, time.Since(start), entry)
+	return l, false
+}
+
+// Request adds the validators of an expired entry to the upstream request
+func (l *cacheLookup) Request(r *http.Request) *http.Request {
+	if l == nil || l.entry == nil {
+		return r
+	}
+	conditional := r.Clone(r.Context())
+	l.entry.Conditional(conditional.Header)
+	return conditional
+}
+
+// Writer records the upstream response for storing
+func (l *cacheLookup) Writer(w http.ResponseWriter) http.ResponseWriter {
+	if l == nil {
+		return w
+	}
+	l.writer = &cacheWriter{
+		ResponseWriter: w,
+		before:         w.Header().Clone(),
+		revalidating:   l.entry != nil,
+		limit:          l.cache.maxBody,
+	}
+	return l.writer
+}
+
+// Finish stores the forwarded response, or answers from the entry the
+// upstream confirmed with 304. err is the error returned by Forward.
+func (l *cacheLookup) Finish(w http.ResponseWriter, r *http.Request, err error) {
+	if l == nil {
+		return
+	}
+	if err != nil || l.writer == nil {
+		l.record()
+		return
+	}
+
+	cw, now := l.writer, l.cache.now()
+	var entry *cache.Entry
+	if cw.notModified {
+		l.result = CacheRevalidated
+		entry = l.entry
+		entry.Revalidated(cw.header, l.route.Cache, now)
+		serveEntry(w, r, entry, l.result, now)
+	} else if ok, _ := cache.Storable(cw.status, cw.header, l.route.Cache, authenticated(r)); ok && !l.noStore && !cw.truncated {
+		entry = cache.NewEntry(cw.status, cw.header, cw.body.Bytes(), l.route.Cache, now)
+	}
+	l.record()
+
+	if entry != nil {
+		// Stored after the client is answered
+		go l.cache.put(context.WithoutCancel(r.Context()), l.key, r, entry)
+	}
+}
+
+func (l *cacheLookup) record() {
+	observability.CacheRequests.WithLabelValues(l.service, l.result).Inc()
+}
+
+func (l *cacheLookup) trace(ctx context.Context, status trace.Status, d time.Duration, entry *cache.Entry) {
+	details := map[string]interface{}{
+		"service": l.service,
+		"result":  l.result,
+		"key":     l.key, // For POST /admin/cache/purge
+	}
+	if entry != nil {
+		details["age"] = int(entry.Age(l.cache.now()).Seconds())
+		if l.entry != nil {
+			details["revalidate"] = true
+		}
+	}
+	trace.EmitStep(ctx, trace.StepCache, status, d, details)
+}
+
+func (c *ResponseCache) put(ctx context.Context, key string, r *http.Request, entry *cache.Entry) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := c.store.Put(ctx, key, r, entry); err != nil {
+		log.Printf("Cache store failed: %v", err)
+	}
+}
+
+// refresh revalidates a stale entry in the background with a detached
+// copy of the request, sent to the same split group as the entry. A Redis
+// lock keeps concurrent requests on any instance from refreshing it at the
+// same time.
+func (c *ResponseCache) refresh(r *http.Request, route *config.Route, key string, entry *cache.Entry) {
+	ctx := r.Context()
+	if ok, err := c.store.Lock(ctx, key, route.Timeout); err != nil || !ok {
+		return
+	}
+
+	entry.Conditional(r.Header)
+
+	w := &bufferWriter{header: make(http.Header)}
+	cw := &cacheWriter{ResponseWriter: w, before: http.Header{}, revalidating: true, limit: c.maxBody}
+	if err := c.forwarder.Forward(cw, r, route); err != nil {
+		log.Printf("Cache refresh of %s failed: %v", r.URL.Path, err)
+		return
+	}
+
+	now := c.now()
+	if cw.notModified {
+		entry.Revalidated(cw.header, route.Cache, now)
+	} else if ok, _ := cache.Storable(cw.status, cw.header, route.Cache, authenticated(r)); ok && !cw.truncated {
+		entry = cache.NewEntry(cw.status, cw.header, cw.body.Bytes(), route.Cache, now)
+	} else {
+		return
+	}
+	c.put(ctx, key, r, entry)
+}
+
+// authenticated reports whether Auth identified the caller of r; it
+// removes the identity headers from anonymous requests
+func authenticated(r *http.Request) bool {
+	return r.Header.Get("X-User-ID") != "" || r.Header.Get("X-Client-ID") != ""
+}
+
+// serveEntry writes a cached response, or 304 if the client's conditional
+// request matches it
+func serveEntry(w http.ResponseWriter, r *http.Request, entry *cache.Entry, result string, now time.Time) {
+	h := w.Header()
+	for name, values := range entry.Header {
+		h[name] = values
+	}
+	h.Set("Age", strconv.Itoa(int(entry.Age(now).Seconds())))
+	h.Set(CacheHeader, strings.ToUpper(result))
+
+	if entry.NotModified(r.Header) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body)
+}
+
+// cacheWriter passes the upstream response through while recording it.
+// A 304 answering the gateway's own revalidation is held back.
+type cacheWriter struct {
+	http.ResponseWriter
+	before       http.Header // Set by middleware before forwarding
+	revalidating bool
+	limit        int64
+
+	status      int
+	header      http.Header // Set by the upstream
+	body        bytes.Buffer
+	truncated   bool
+	notModified bool
+}
+
+func (c *cacheWriter) WriteHeader(code int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = code
+	c.header = addedHeaders(c.before, c.ResponseWriter.Header())
+	for _, name := range cache.TagHeaders {
+		c.ResponseWriter.Header().Del(name) // For purging only
+	}
+
+	if code == http.StatusNotModified && c.revalidating {
+		c.notModified = true
+		// Drop the 304's headers; the stored response is written instead
+		h := c.ResponseWriter.Header()
+		for name := range h {
+			delete(h, name)
+		}
+		for name, values := range c.before {
+			h[name] = values
+		}
+		return
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *cacheWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.notModified {
+		return len(b), nil
+	}
+	room := c.limit - int64(c.body.Len())
+	if int64(len(b)) > room {
+		c.truncated = true
+	}
+	if room > 0 {
+		c.body.Write(b[:min(int64(len(b)), room)])
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// addedHeaders returns the header values in after that were not in before
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		prev := before[name]
+		if len(values) >= len(prev) && slices.Equal(values[:len(prev)], prev) {
+			if rest := values[len(prev):]; len(rest) > 0 {
+				added[name] = append([]string(nil), rest...)
+			}
+			continue
+		}
+		added[name] = append([]string(nil), values...)
+	}
+	return added
+}
+
+// bufferWriter is the response writer of background refreshes
+type bufferWriter struct {
+	header http.Header
+}
+
+func (b *bufferWriter) Header() http.Header         { return b.header }
+func (b *bufferWriter) WriteHeader(int)             {}
+func (b *bufferWriter) Write(p []byte) (int, error) { return len(p), nil }
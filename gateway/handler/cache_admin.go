@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/distributed-api-gateway/gateway/pkg/cache"
+)
+
+// purgeRequest selects cached responses to delete; exactly one field is set
+type purgeRequest struct {
+	Key       string   `json:"key"`        // Cache key, as in the CACHE trace step
+	Route     string   `json:"route"`      // Route path prefix
+	URLPrefix string   `json:"url_prefix"` // Request path prefix, on any route
+	Tags      []string `json:"tags"`       // Surrogate keys sent by the backend
+}
+
+// CachePurgeHandler serves POST /admin/cache/purge. The cache lives in
+// Redis, so a purge takes effect on every instance at once.
+func CachePurgeHandler(store *cache.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use POST")
+			return
+		}
+
+		var req purgeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid purge request: "+err.Error())
+			return
+		}
+
+		set := 0
+		for _, present := range []bool{req.Key != "", req.Route != "", req.URLPrefix != "", len(req.Tags) > 0} {
+			if present {
+				set++
+			}
+		}
+		if set != 1 {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "set exactly one of key, route, url_prefix or tags")
+			return
+		}
+
+		var by string
+		var purged int
+		var err error
+		switch {
+		case req.Key != "":
+			by = "key"
+			if !strings.HasPrefix(req.Key, cache.KeyPrefix+"/") {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "key must be a cache key like cache:/prefix:/path:hash")
+				return
+			}
+			purged, err = store.PurgeKey(r.Context(), req.Key)
+		case req.Route != "":
+			by = "route"
+			purged, err = store.PurgeRoute(r.Context(), req.Route)
+		case req.URLPrefix != "":
+			by = "url_prefix"
+			if !strings.HasPrefix(req.URLPrefix, "/") {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "url_prefix must start with /")
+				return
+			}
+			purged, err = store.PurgeURLPrefix(r.Context(), req.URLPrefix)
+		default:
+			by = "tags"
+			purged, err = store.PurgeTags(r.Context(), req.Tags)
+		}
+		if err != nil {
+			writeErrorDetails(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error(), map[string]interface{}{
+				"purged": purged,
+			})
+			return
+		}
+
+		log.Printf("Cache purge by %s by %s: %d keys deleted", by, adminUser(r), purged)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"by":     by,
+			"purged": purged,
+		})
+	}
+}
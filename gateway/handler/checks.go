@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/health"
+	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+	"github.com/distributed-api-gateway/gateway/pkg/revocation"
+)
+
+// Readiness check names, used in the HEALTH_READY_CHECKS policy
+const (
+	CheckRedis       = "redis"
+	CheckRoutes      = "routes"
+	CheckKeys        = "keys"
+	CheckUpstreams   = "upstreams"
+	CheckRevocations = "revocations"
+)
+
+// RedisCheck pings Redis. When it fails, rate limiting and circuit
+// breaking fail open.
+func RedisCheck(client *redis.Client) health.CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		start := time.Now()
+		if err := client.Ping(ctx); err != nil {
+			return map[string]interface{}{"fail_open": true}, err
+		}
+		return map[string]interface{}{"ping_ms": float64(time.Since(start).Microseconds()) / 1000}, nil
+	}
+}
+
+// RoutesCheck reports the loaded route table
+func RoutesCheck(table *config.RouteTable) health.CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		routes := table.Load()
+		details := map[string]interface{}{"count": len(routes.Routes)}
+		if len(routes.Routes) == 0 {
+			return details, errors.New("no routes loaded")
+		}
+		return details, nil
+	}
+}
+
+// KeysCheck reports whether JWT verification keys are loaded
+func KeysCheck(validator *jwt.Validator) health.CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		count := validator.KeyCount()
+		details := map[string]interface{}{"count": count}
+		if count == 0 {
+			return details, errors.New("no JWT keys loaded")
+		}
+		return details, nil
+	}
+}
+
+// RevocationsCheck fails until the revocation list has been loaded from
+// Redis; before that, revoked tokens are accepted
+func RevocationsCheck(list *revocation.List) health.CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		synced := list.Synced()
+		details := map[string]interface{}{"count": list.Len()}
+		if synced.IsZero() {
+			return details, errors.New("revocation list not loaded")
+		}
+		details["synced_seconds_ago"] = int(time.Since(synced).Seconds())
+		return details, nil
+	}
+}
+
+// UpstreamsCheck opens a TCP connection to each route, split group and
+// shadow target, reusing the results for interval so the public readiness
+// endpoint cannot be used to make the gateway dial its upstreams on every
+// call. Details map each upstream to "up" or "down"; dial errors, which
+// name internal hosts, are only logged. Shadow targets are reported but
+// never fail the check, as their responses never reach clients.
+func UpstreamsCheck(table *config.RouteTable, interval time.Duration) health.CheckFunc {
+	u := &upstreamCheck{table: table, interval: interval, sem: make(chan struct{}, 1), failing: make(map[string]string)}
+	return u.run
+}
+
+// upstream is one target probed by the upstreams check
+type upstream struct {
+	name   string // e.g. /service-b, /service-b split:canary, /service-b shadow
+	target string
+	shadow bool
+}
+
+// upstreamCheck caches the results of the last round of dials
+type upstreamCheck struct {
+	table    *config.RouteTable
+	interval time.Duration
+	sem      chan struct{} // Held by the caller refreshing the results
+
+	routes  *config.RoutesConfig // Table the results belong to
+	expires time.Time
+	details map[string]interface{}
+	err     error
+	failing map[string]string // Dial errors of down targets, logged on change
+}
+
+func (u *upstreamCheck) run(ctx context.Context) (map[string]interface{}, error) {
+	select {
+	case u.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-u.sem }()
+
+	routes := u.table.Load()
+	if routes != u.routes || !time.Now().Before(u.expires) {
+		u.details, u.err = u.probe(ctx, routes)
+		u.routes, u.expires = routes, time.Now().Add(u.interval)
+	}
+	return u.details, u.err
+}
+
+// probe dials every target once, however many routes share it
+func (u *upstreamCheck) probe(ctx context.Context, routes *config.RoutesConfig) (map[string]interface{}, error) {
+	var targets []upstream
+	for _, route := range routes.Routes {
+		targets = append(targets, upstream{name: route.PathPrefix, target: route.Target})
+		if route.Split != nil {
+			for _, g := range route.Split.Groups {
+				targets = append(targets, upstream{name: route.PathPrefix + " split:" + g.Name, target: g.Target})
+			}
+		}
+		if route.Shadow != nil {
+			targets = append(targets, upstream{name: route.PathPrefix + " shadow", target: route.Shadow.Target, shadow: true})
+		}
+	}
+
+	unique := make(map[string]bool)
+	for _, t := range targets {
+		unique[t.target] = true
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	dialErrs := make(map[string]error, len(unique))
+	for target := range unique {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			err := dialTarget(ctx, target)
+			mu.Lock()
+			dialErrs[target] = err
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	details := make(map[string]interface{}, len(targets))
+	failing := make(map[string]string)
+	down := 0
+	for _, t := range targets {
+		err := dialErrs[t.target]
+		if err == nil {
+			details[t.name] = "up"
+			continue
+		}
+		details[t.name] = "down"
+		failing[t.name] = err.Error()
+		if u.failing[t.name] != err.Error() {
+			log.Printf("Upstream %s (%s) unreachable: %v", t.name, t.target, err)
+		}
+		if !t.shadow {
+			down++
+		}
+	}
+	u.failing = failing
+
+	if down > 0 {
+		return details, errors.New("one or more upstreams unreachable")
+	}
+	return details, nil
+}
+
+func dialTarget(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
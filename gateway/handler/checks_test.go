@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// countingUpstream accepts connections and counts them
+func countingUpstream(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			conn.Close()
+		}
+	}()
+	return "http://" + ln.Addr().String(), &accepted
+}
+
+// closedUpstream returns a target nothing listens on
+func closedUpstream(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "http://" + addr
+}
+
+// waitAccepted waits for the upstream to see n connections in total
+func waitAccepted(t *testing.T, accepted *atomic.Int32, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for accepted.Load() < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := accepted.Load(); got != n {
+		t.Errorf("Expected %d connections, got %d", n, got)
+	}
+}
+
+func TestUpstreamsCheckReusesResults(t *testing.T) {
+	target, accepted := countingUpstream(t)
+	table := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{
+		{PathPrefix: "/service-a", Target: target},
+		{PathPrefix: "/service-b", Target: target},
+	}})
+	check := UpstreamsCheck(table, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if _, err := check(context.Background()); err != nil {
+			t.Fatalf("Expected upstreams up, got %v", err)
+		}
+	}
+	waitAccepted(t, accepted, 1)
+
+	// A new route table is probed at once
+	table.Swap(&config.RoutesConfig{Routes: []config.Route{{PathPrefix: "/service-c", Target: target}}})
+	details, err := check(context.Background())
+	if err != nil || details["/service-c"] != "up" {
+		t.Errorf("Expected the new table probed, got %v (%v)", details, err)
+	}
+	waitAccepted(t, accepted, 2)
+}
+
+func TestUpstreamsCheckHidesDialErrors(t *testing.T) {
+	down := closedUpstream(t)
+	table := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{PathPrefix: "/service-a", Target: down}}})
+
+	details, err := UpstreamsCheck(table, time.Hour)(context.Background())
+	if err == nil || details["/service-a"] != "down" {
+		t.Fatalf("Expected /service-a down, got %v (%v)", details, err)
+	}
+	host := strings.TrimPrefix(down, "http://")
+	if strings.Contains(err.Error(), host) {
+		t.Errorf("Expected the dial error not to be reported, got %q", err)
+	}
+}
+
+func TestUpstreamsCheckSplitAndShadowTargets(t *testing.T) {
+	up, _ := countingUpstream(t)
+	down := closedUpstream(t)
+
+	shadowDown := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-a",
+		Target:     up,
+		Shadow:     &config.Shadow{Target: down, Percent: 10},
+	}}})
+	details, err := UpstreamsCheck(shadowDown, time.Hour)(context.Background())
+	if err != nil || details["/service-a shadow"] != "down" || details["/service-a"] != "up" {
+		t.Errorf("Expected a down shadow reported without failing, got %v (%v)", details, err)
+	}
+
+	splitDown := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-a",
+		Target:     up,
+		Split: &config.TrafficSplit{Groups: []config.UpstreamGroup{
+			{Name: "stable", Target: up, Weight: 95},
+			{Name: "canary", Target: down, Weight: 5},
+		}},
+	}}})
+	details, err = UpstreamsCheck(splitDown, time.Hour)(context.Background())
+	if err == nil || details["/service-a split:canary"] != "down" || details["/service-a split:stable"] != "up" {
+		t.Errorf("Expected a down split group to fail the check, got %v (%v)", details, err)
+	}
+}
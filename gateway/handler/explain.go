@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/middleware"
+	"github.com/distributed-api-gateway/gateway/pkg/apikey"
+	"github.com/distributed-api-gateway/gateway/pkg/circuitbreaker"
+	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/mtls"
+	"github.com/distributed-api-gateway/gateway/pkg/ratelimit"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+	"github.com/distributed-api-gateway/gateway/proxy"
+)
+
+// ExplainRequest describes a hypothetical request to the gateway
+type ExplainRequest struct {
+	Method     string            `json:"method"`
+	Host       string            `json:"host"`
+	Path       string            `json:"path"` // May include ?query
+	Headers    map[string]string `json:"headers"`
+	RemoteAddr string            `json:"remote_addr"` // Defaults to 127.0.0.1:0
+}
+
+// ExplainResponse describes how the gateway would handle the request
+type ExplainResponse struct {
+	Matched        bool            `json:"matched"`
+	Reason         string          `json:"reason,omitempty"`
+	Route          *config.Route   `json:"route,omitempty"`
+	UpstreamURL    string          `json:"upstream_url,omitempty"`
+	Split          *ExplainSplit   `json:"split,omitempty"`
+	Middleware     []string        `json:"middleware"`
+	Auth           *ExplainAuth    `json:"auth,omitempty"`
+	RateLimitKey   string          `json:"rate_limit_key,omitempty"`
+	CircuitBreaker *ExplainBreaker `json:"circuit_breaker,omitempty"`
+	Routes         []string        `json:"configured_prefixes,omitempty"` // Listed when nothing matched
+}
+
+// ExplainAuth is the outcome of authenticating the supplied headers
+type ExplainAuth struct {
+	Mode     string   `json:"mode"` // required, optional or none
+	Methods  []string `json:"methods"`
+	Method   string   `json:"method,omitempty"` // Method the chain would authenticate with
+	Result   string   `json:"result"`           // ok, anonymous, failed, forbidden, skipped, unavailable
+	UserID   string   `json:"user_id,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	ClaimHeaders map[string]string `json:"claim_headers,omitempty"` // Upstream headers set from token claims
+}
+
+// ExplainSplit is the traffic split group picked for the request. Unless
+// the override header or a sticky assignment applies, the pick is random.
+type ExplainSplit struct {
+	Group  string `json:"group"`
+	Target string `json:"target"`
+}
+
+// ExplainBreaker is the current circuit breaker state for the route
+type ExplainBreaker struct {
+	Service string `json:"service"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ExplainHandler answers POST /admin/routes/explain without sending traffic
+// upstream or touching rate limit counters. certAuth may be nil when mTLS
+// is not configured.
+func ExplainHandler(table *config.RouteTable, validator *jwt.Validator, certAuth *mtls.Authenticator, keyAuth *apikey.Authenticator, redisClient *redis.Client, proxies ratelimit.Proxies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use POST")
+			return
+		}
+
+		var req ExplainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "body must be JSON with at least a path")
+			return
+		}
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+
+		// Build the request as the middleware chain would see it
+		sim := httptest.NewRequest(req.Method, "http://gateway"+req.Path, nil)
+		sim.Host = req.Host
+		for k, v := range req.Headers {
+			sim.Header.Set(k, v)
+		}
+		if req.RemoteAddr != "" {
+			sim.RemoteAddr = req.RemoteAddr
+		}
+
+		resp := ExplainResponse{Middleware: []string{"cors"}}
+		if strings.EqualFold(req.Method, http.MethodOptions) {
+			resp.Reason = "OPTIONS is answered by the CORS preflight handler and never proxied"
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+
+		routes := table.Load()
+		route := routes.MatchRoute(sim.URL.Path)
+		resp.Middleware = append(resp.Middleware, "trace", "metrics", "auth")
+		if route == nil {
+			resp.Reason = "no route path_prefix matches " + sim.URL.Path + "; the proxy returns 404 NOT_FOUND after auth"
+			for _, rt := range routes.Routes {
+				resp.Routes = append(resp.Routes, rt.PathPrefix)
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+
+		resp.Matched = true
+		resp.Route = route
+		resp.Middleware = append(resp.Middleware, routeStages(route, sim.Method)...)
+		resp.Auth = explainAuth(r.Context(), sim, route, validator, certAuth, keyAuth)
+		resp.RateLimitKey = middleware.RateLimitKey(sim, proxies)
+
+		upstream := *route
+		if route.Split != nil {
+			choice := proxy.ChooseSplit(route, sim, rand.Float64)
+			resp.Split = &ExplainSplit{Group: choice.Group, Target: choice.Target}
+			upstream.Target = choice.Target
+		}
+		resp.UpstreamURL = proxy.TargetURL(&upstream, sim.URL.Path, sim.URL.RawQuery)
+
+		group := ""
+		if resp.Split != nil {
+			group = resp.Split.Group
+		}
+		circuit := BreakerName(route.PathPrefix, group)
+		breaker := circuitbreaker.NewBreaker(redisClient, circuit)
+		state, err := breaker.State(r.Context())
+		resp.CircuitBreaker = &ExplainBreaker{Service: circuit, State: string(state)}
+		if err != nil {
+			resp.CircuitBreaker.Error = err.Error()
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// routeStages lists the stages after auth that handle a request to route,
+// in the order the proxy handler runs them. Shadow and fault stages are
+// sampled per request.
+func routeStages(route *config.Route, method string) []string {
+	stages := []string{"rate_limit"}
+	if route.Split != nil {
+		stages = append(stages, "split")
+	}
+	if route.Cache != nil && method == http.MethodGet {
+		stages = append(stages, "cache")
+	}
+	stages = append(stages, "circuit_breaker")
+	if route.Shadow != nil {
+		stages = append(stages, "shadow")
+	}
+	if route.Fault != nil {
+		stages = append(stages, "fault")
+	}
+	if route.Coalesce != nil && method == http.MethodGet {
+		stages = append(stages, "coalesce")
+	}
+	return append(stages, "proxy")
+}
+
+// explainAuth authenticates sim with the method middleware.Auth would pick
+// and applies the resulting identity headers to sim, so the rate limit key
+// matches the real chain. Client certificates cannot be simulated.
+func explainAuth(ctx context.Context, sim *http.Request, route *config.Route, validator *jwt.Validator, certAuth *mtls.Authenticator, keyAuth *apikey.Authenticator) *ExplainAuth {
+	methods := route.AuthMethods
+	if len(methods) == 0 {
+		methods = []string{config.AuthMethodJWT}
+	}
+
+	// Client-sent identity headers are replaced by the auth middleware
+	sim.Header.Del("X-User-ID")
+	sim.Header.Del("X-Client-ID")
+	middleware.ApplyClaimHeaders(sim, route, nil)
+	decision := middleware.DecideAuth(sim, route, certAuth, keyAuth)
+	if keyAuth != nil && route.AcceptsAuth(config.AuthMethodAPIKey) {
+		keyAuth.Strip(sim)
+	}
+	result := &ExplainAuth{Mode: decision.Mode, Methods: methods, Method: decision.Method}
+
+	if decision.Anonymous {
+		result.Result = "anonymous"
+		if decision.Mode == config.AuthModeOptional && route.Auth.Authorize(sim.Method, nil, nil) != nil {
+			result.Result = "failed"
+			result.Error = "authentication required for " + sim.Method
+		}
+		return result
+	}
+
+	var scopes, roles []string
+	switch decision.Method {
+	case config.AuthMethodMTLS:
+		result.Result = "skipped"
+		result.Error = "client certificates cannot be simulated"
+		return result
+
+	case config.AuthMethodAPIKey:
+		if decision.APIKey == "" {
+			result.Result = "failed"
+			result.Error = "missing API key"
+			return result
+		}
+		key, err := keyAuth.Authenticate(ctx, decision.APIKey)
+		if err != nil {
+			result.Result = "failed"
+			if err == apikey.ErrUnavailable {
+				result.Result = "unavailable"
+			}
+			result.Error = err.Error()
+			return result
+		}
+		result.UserID, result.ClientID = key.Owner, key.ClientID
+		scopes = key.Scopes
+
+	case config.AuthMethodJWT:
+		token := middleware.BearerToken(sim)
+		if token == "" {
+			result.Result = "failed"
+			result.Error = "missing authorization token"
+			return result
+		}
+		claims, err := validator.ValidateFor(token, middleware.TokenRequirements(route))
+		if err != nil {
+			result.Result = "failed"
+			result.Error = err.Error()
+			return result
+		}
+		result.UserID, result.ClientID = claims.Sub, claims.ClientID
+		result.ClaimHeaders = middleware.ApplyClaimHeaders(sim, route, claims)
+		scopes, roles = claims.Scopes, claims.Roles
+
+	default:
+		result.Result = "failed"
+		result.Error = "no supported authentication method"
+		return result
+	}
+
+	result.Result = "ok"
+	if result.ClientID != "" {
+		sim.Header.Set("X-Client-ID", result.ClientID)
+	}
+	if err := route.Auth.Authorize(sim.Method, scopes, roles); err != nil {
+		result.Result = "forbidden"
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/apikey"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+func explain(t *testing.T, h http.HandlerFunc, req ExplainRequest) ExplainResponse {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodPost, "/admin/routes/explain", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	return resp
+}
+
+func TestExplainAuthenticatesAPIKeys(t *testing.T) {
+	_, client := redistest.New(t)
+	store := apikey.NewStore(client)
+	keyAuth := apikey.NewAuthenticator(store, "X-API-Key", "", time.Minute)
+	token, _, err := store.Create(context.Background(), apikey.Key{ClientID: "acme", Owner: "alice", Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{
+		{PathPrefix: "/optional", Target: "http://optional:6000", AuthMethods: []string{config.AuthMethodJWT, config.AuthMethodAPIKey}, Auth: &config.RouteAuth{Mode: config.AuthModeOptional}},
+		{PathPrefix: "/both", Target: "http://both:6000", AuthMethods: []string{config.AuthMethodJWT, config.AuthMethodAPIKey}},
+	}})
+	h := ExplainHandler(routes, nil, nil, keyAuth, client, nil)
+
+	for _, path := range []string{"/optional/items", "/both/items"} {
+		resp := explain(t, h, ExplainRequest{Path: path, Headers: map[string]string{"X-API-Key": token}})
+		if a := resp.Auth; a == nil || a.Method != config.AuthMethodAPIKey || a.Result != "ok" || a.ClientID != "acme" {
+			t.Errorf("%s: expected API key authentication, got %+v", path, a)
+		}
+		if resp.RateLimitKey != "client:acme" {
+			t.Errorf("%s: expected the key's client to be rate limited, got %q", path, resp.RateLimitKey)
+		}
+	}
+
+	resp := explain(t, h, ExplainRequest{Path: "/both/items", Headers: map[string]string{"X-API-Key": "not-a-key"}})
+	if a := resp.Auth; a == nil || a.Method != config.AuthMethodAPIKey || a.Result != "failed" {
+		t.Errorf("Expected an invalid key to fail, got %+v", a)
+	}
+	resp = explain(t, h, ExplainRequest{Path: "/optional/items"})
+	if a := resp.Auth; a == nil || a.Result != "anonymous" {
+		t.Errorf("Expected anonymous access without credentials, got %+v", a)
+	}
+}
+
+func TestExplainListsRouteStages(t *testing.T) {
+	_, client := redistest.New(t)
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-a",
+		Target:     "http://service-a:6000",
+		Auth:       &config.RouteAuth{Mode: config.AuthModeNone},
+		Split:      &config.TrafficSplit{Groups: []config.UpstreamGroup{{Name: "stable", Target: "http://service-a:6000", Weight: 1}}},
+		Cache:      &config.RouteCache{TTL: time.Minute},
+		Shadow:     &config.Shadow{Target: "http://shadow:6000", Percent: 10},
+		Fault:      &config.Fault{Header: "X-Fault"},
+		Coalesce:   &config.Coalesce{},
+	}}})
+	h := ExplainHandler(routes, nil, nil, nil, client, nil)
+
+	want := []string{"cors", "trace", "metrics", "auth", "rate_limit", "split", "cache", "circuit_breaker", "shadow", "fault", "coalesce", "proxy"}
+	if got := explain(t, h, ExplainRequest{Path: "/service-a/items"}).Middleware; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	want = []string{"cors", "trace", "metrics", "auth", "rate_limit", "split", "circuit_breaker", "shadow", "fault", "proxy"}
+	if got := explain(t, h, ExplainRequest{Method: http.MethodPost, Path: "/service-a/items"}).Middleware; !slices.Equal(got, want) {
+		t.Errorf("Expected cache and coalesce skipped for POST, got %v", got)
+	}
+}
@@ -1,19 +1,58 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 
+	"github.com/distributed-api-gateway/gateway/pkg/health"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// HealthHandler returns a handler for the /health endpoint
-func HealthHandler() http.HandlerFunc {
+// HealthHandler returns a handler for the /health endpoint.
+// Reports 503 once shutdown starts so load balancers stop routing here.
+func HealthHandler(lifecycle *Lifecycle) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		if lifecycle.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"draining"}`))
+			return
+		}
 		w.Write([]byte(`{"status":"healthy"}`))
 	}
 }
 
+// LivenessHandler returns a handler for /health/live.
+// The process is alive as long as it can serve HTTP, even while draining.
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"alive"}`))
+	}
+}
+
+// ReadinessHandler returns a handler for /health/ready that runs dependency
+// checks. Returns 503 when draining or when a critical check fails.
+func ReadinessHandler(lifecycle *Lifecycle, checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+		body := struct {
+			health.Report
+			Draining bool `json:"draining,omitempty"`
+		}{Report: report, Draining: lifecycle.Draining()}
+
+		if body.Draining {
+			body.Status = health.StatusNotReady
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if body.Status != health.StatusReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
 // MetricsHandler returns Prometheus metrics handler
 func MetricsHandler() http.Handler {
 	return promhttp.Handler()
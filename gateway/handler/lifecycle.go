@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// socketCloseTimeout bounds the wait for WebSockets to close once told to
+const socketCloseTimeout = time.Second
+
+// Lifecycle tracks whether the gateway is serving or draining, and the
+// long-lived trace WebSockets that http.Server.Shutdown does not wait for
+// (they are hijacked connections).
+type Lifecycle struct {
+	draining atomic.Bool
+
+	ctx     context.Context // Cancelled by CloseSockets
+	cancel  context.CancelFunc
+	sockets atomic.Int64 // Open WebSockets
+}
+
+// NewLifecycle creates a lifecycle in the serving state
+func NewLifecycle() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Draining reports whether shutdown has started
+func (l *Lifecycle) Draining() bool {
+	return l.draining.Load()
+}
+
+// StartDraining marks the instance not ready and refuses new WebSockets;
+// open ones stay up until CloseSockets
+func (l *Lifecycle) StartDraining() {
+	l.draining.Store(true)
+}
+
+// CloseSockets tells open WebSockets to close
+func (l *Lifecycle) CloseSockets() {
+	l.cancel()
+}
+
+// Closing is closed when open WebSockets should close
+func (l *Lifecycle) Closing() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+// DrainSockets gives open WebSockets until ctx expires to finish, then
+// closes the rest and waits up to socketCloseTimeout for them to go away
+func (l *Lifecycle) DrainSockets(ctx context.Context) error {
+	if l.WaitSockets(ctx) == nil {
+		return nil
+	}
+	l.CloseSockets()
+	closeCtx, cancel := context.WithTimeout(context.Background(), socketCloseTimeout)
+	defer cancel()
+	return l.WaitSockets(closeCtx)
+}
+
+// WaitSockets blocks until all tracked WebSockets have closed or ctx expires
+func (l *Lifecycle) WaitSockets(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for l.sockets.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// trackSocket registers a WebSocket before it is upgraded; call release
+// when it closes. ok is false once draining has started: the check comes
+// after registering, so a socket is either refused or counted by
+// WaitSockets.
+func (l *Lifecycle) trackSocket() (release func(), ok bool) {
+	l.sockets.Add(1)
+	if l.draining.Load() {
+		l.sockets.Add(-1)
+		return nil, false
+	}
+	return func() { l.sockets.Add(-1) }, true
+}
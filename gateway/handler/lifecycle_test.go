@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+func TestLifecycleDrainOrder(t *testing.T) {
+	l := NewLifecycle()
+	if l.Draining() {
+		t.Fatal("Expected a new lifecycle to be serving")
+	}
+	release, ok := l.trackSocket()
+	if !ok {
+		t.Fatal("Expected sockets to be tracked while serving")
+	}
+
+	go func() {
+		<-l.Closing()
+		release()
+	}()
+
+	l.StartDraining()
+	if !l.Draining() {
+		t.Error("Expected draining after StartDraining")
+	}
+	if _, ok := l.trackSocket(); ok {
+		t.Error("Expected new sockets refused while draining")
+	}
+
+	// Open sockets are not told to close until the grace period ends
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.WaitSockets(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the socket kept open while draining, got %v", err)
+	}
+	select {
+	case <-l.Closing():
+		t.Error("Expected sockets not closed when draining starts")
+	default:
+	}
+
+	grace, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.DrainSockets(grace); err != nil {
+		t.Errorf("Expected the socket closed at the end of the grace period, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("Expected DrainSockets to wait for the grace period before closing")
+	}
+}
+
+func TestTraceWebSocketClosesOnDrain(t *testing.T) {
+	_, client := redistest.New(t)
+	l := NewLifecycle()
+	srv := httptest.NewServer(TraceWebSocket(client.Raw(), l))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/trace/abc"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil || msg["type"] != "subscribed" {
+		t.Fatalf("Expected subscription confirmed, got %v (%v)", msg, err)
+	}
+
+	l.StartDraining()
+
+	// Still delivering trace events during the grace period
+	client.Raw().Publish(context.Background(), msg["channel"].(string), `{"step":"proxy"}`)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != `{"step":"proxy"}` {
+		t.Fatalf("Expected the socket open while draining, got %q (%v)", data, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- l.DrainSockets(ctx) }()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going away close, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Expected the socket released after closing, got %v", err)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for sockets opened while draining, got %v", err)
+	}
+}
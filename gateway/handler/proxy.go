@@ -3,7 +3,9 @@ package handler
 import (
 	"encoding/json"
 	"log"
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/distributed-api-gateway/gateway/config"
@@ -27,33 +29,70 @@ type ErrorDetail struct {
 	Details map[string]interface{} `json:"details,omitempty"`
 }
 
-// ProxyHandler creates a handler for proxying requests to backend services
-func ProxyHandler(routes *config.RoutesConfig, forwarder *proxy.Forwarder, redisClient *redis.Client) http.HandlerFunc {
+// ProxyHandler creates a handler for proxying requests to backend services.
+// Requests are forwarded through coalescer, which shares upstream calls of
+// routes with a coalesce block. Routes with a shadow target are mirrored
+// through mirror, and routes with a cache block are answered from responses
+// when possible (nil disables caching).
+func ProxyHandler(routes *config.RouteTable, coalescer *proxy.Coalescer, mirror *proxy.Mirror, responses *ResponseCache, redisClient *redis.Client) http.HandlerFunc {
+	var mu sync.Mutex
 	breakers := make(map[string]*circuitbreaker.Breaker)
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Match route
-		route := routes.MatchRoute(r.URL.Path)
+		// Use the route the auth middleware matched, so auth and proxying
+		// agree even if the table was swapped in between
+		route, matched := config.RouteFromContext(r.Context())
+		if !matched {
+			route = routes.MatchRoute(r.URL.Path)
+		}
 		if route == nil {
 			writeError(w, r, http.StatusNotFound, "NOT_FOUND", "Route not found")
 			return
 		}
 
-		// Get or create circuit breaker for this service
+		// Pick the upstream group of a traffic split
+		split := ""
+		if route.Split != nil {
+			choice := proxy.ChooseSplit(route, r, rand.Float64)
+			if choice.Cookie != nil {
+				http.SetCookie(w, choice.Cookie)
+			}
+			if labels := observability.LabelsFromContext(r.Context()); labels != nil {
+				labels.Split = choice.Group
+			}
+			split = choice.Group
+			selected := *route
+			selected.Target = choice.Target
+			route = &selected
+		}
+
+		// Answer from the response cache before touching the upstream;
+		// each split group has its own entries
+		lookup, served := responses.Lookup(w, r, route, split)
+		if served {
+			trace.EmitStep(r.Context(), trace.StepComplete, trace.StatusSuccess, 0, nil)
+			return
+		}
+
+		// Get or create circuit breaker for this service; split groups
+		// have their own, so a failing canary leaves the stable group open
 		service := route.PathPrefix // Use path prefix as service identifier
-		breaker, ok := breakers[service]
+		circuit := BreakerName(service, split)
+		mu.Lock()
+		breaker, ok := breakers[circuit]
 		if !ok {
-			breaker = circuitbreaker.NewBreaker(redisClient, service)
-			breakers[service] = breaker
+			breaker = circuitbreaker.NewBreaker(redisClient, circuit)
+			breakers[circuit] = breaker
 		}
+		mu.Unlock()
 
 		// Check circuit state and record metric
 		cbStart := time.Now()
 		cbResult := breaker.Allow(r.Context())
-		updateCircuitBreakerMetric(service, cbResult.State)
+		updateCircuitBreakerMetric(circuit, cbResult.State)
 		if !cbResult.Allowed {
 			trace.EmitStep(r.Context(), trace.StepCircuit, trace.StatusFailed, time.Since(cbStart), map[string]interface{}{
-				"service": service,
+				"service": circuit,
 				"state":   string(cbResult.State),
 				"reason":  "circuit open",
 			})
@@ -61,24 +100,74 @@ func ProxyHandler(routes *config.RoutesConfig, forwarder *proxy.Forwarder, redis
 			return
 		}
 		trace.EmitStep(r.Context(), trace.StepCircuit, trace.StatusSuccess, time.Since(cbStart), map[string]interface{}{
-			"service": service,
+			"service": circuit,
 			"state":   string(cbResult.State),
 		})
 
+		// Mirror before forwarding; the shadow outcome never reaches the breaker
+		exchange := mirror.Send(r, route)
+
+		// Pick faults to inject for resilience testing
+		var faults proxy.FaultPlan
+		if route.Fault != nil {
+			faults = proxy.ChooseFaults(route, r, rand.Float64)
+			if kinds := faults.Kinds(); len(kinds) > 0 {
+				for _, kind := range kinds {
+					observability.FaultsInjected.WithLabelValues(service, kind).Inc()
+				}
+				trace.EmitStep(r.Context(), trace.StepFault, trace.StatusSuccess, 0, map[string]interface{}{
+					"service": service,
+					"faults":  kinds,
+					"delay":   faults.Delay.String(),
+					"status":  faults.Abort,
+				})
+			}
+		}
+
 		// Forward request
 		fwdStart := time.Now()
-		if err := forwarder.Forward(w, r, route); err != nil {
-			breaker.RecordFailure(r.Context()) // Record failure for circuit breaker
-			if proxyErr, ok := err.(*proxy.ProxyError); ok {
+		out := exchange.Capture(w)
+		fwdRoute, err := proxy.InjectFaults(r.Context(), faults, route)
+		coalesced := false
+		if err == nil {
+			coalesced, err = coalescer.Forward(lookup.Writer(out), lookup.Request(r), fwdRoute)
+		}
+		if coalesced {
+			observability.CoalescedRequests.WithLabelValues(service).Inc()
+		}
+		lookup.Finish(out, r, err)
+		exchange.Done(err)
+		// Waiters of a coalesced request share the leader's upstream call, so
+		// only the leader's outcome counts towards the circuit breaker
+		record := !coalesced
+		if err == proxy.ErrFaultReset {
+			breaker.RecordFailure(r.Context())
+			trace.EmitStep(r.Context(), trace.StepForward, trace.StatusFailed, time.Since(fwdStart), map[string]interface{}{
+				"service": service,
+				"error":   err.Error(),
+			})
+			resetConnection(w)
+			return
+		}
+		if err != nil {
+			proxyErr, ok := err.(*proxy.ProxyError)
+			if record && (!ok || proxyErr.Code >= 500) {
+				breaker.RecordFailure(r.Context()) // Record failure for circuit breaker
+			}
+			if ok {
 				code := "BAD_GATEWAY"
 				if proxyErr.Code == http.StatusGatewayTimeout {
 					code = "GATEWAY_TIMEOUT"
 				}
+				if proxyErr.Fault == proxy.FaultAbort {
+					code = "FAULT_INJECTED"
+				}
 				trace.EmitStep(r.Context(), trace.StepForward, trace.StatusFailed, time.Since(fwdStart), map[string]interface{}{
 					"service":     service,
 					"target":      route.Target,
 					"error":       proxyErr.Message,
 					"status_code": proxyErr.Code,
+					"coalesced":   coalesced,
 				})
 				writeError(w, r, proxyErr.Code, code, proxyErr.Message)
 				return
@@ -93,23 +182,33 @@ func ProxyHandler(routes *config.RoutesConfig, forwarder *proxy.Forwarder, redis
 		}
 
 		trace.EmitStep(r.Context(), trace.StepForward, trace.StatusSuccess, time.Since(fwdStart), map[string]interface{}{
-			"service": service,
-			"target":  route.Target,
+			"service":   service,
+			"target":    route.Target,
+			"split":     split,
+			"coalesced": coalesced,
 		})
 
 		// Emit complete event
 		trace.EmitStep(r.Context(), trace.StepComplete, trace.StatusSuccess, 0, nil)
 
-		breaker.RecordSuccess(r.Context()) // Record success
+		if record {
+			breaker.RecordSuccess(r.Context()) // Record success
+		}
 	}
 }
 
 // writeError writes a standard error response per LLD format
 func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	writeErrorDetails(w, r, statusCode, code, message, nil)
+}
+
+// writeErrorDetails writes a standard error response with extra context
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, details map[string]interface{}) {
 	resp := ErrorResponse{
 		Error: ErrorDetail{
 			Code:    code,
 			Message: message,
+			Details: details,
 		},
 		RequestID: r.Header.Get("X-Request-ID"), // From client if provided
 	}
@@ -118,6 +217,16 @@ func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, me
 	json.NewEncoder(w).Encode(resp)
 }
 
+// BreakerName names the circuit breaker of a route's path prefix and the
+// split group a request was sent to ("" without a split), e.g.
+// /service-b@canary
+func BreakerName(pathPrefix, group string) string {
+	if group == "" {
+		return pathPrefix
+	}
+	return pathPrefix + "@" + group
+}
+
 func updateCircuitBreakerMetric(service string, state circuitbreaker.State) {
 	var value float64
 	switch state {
@@ -130,3 +239,14 @@ func updateCircuitBreakerMetric(service string, state circuitbreaker.State) {
 	}
 	observability.CircuitBreakerState.WithLabelValues(service).Set(value)
 }
+
+// resetConnection closes the client connection without a response. The
+// connection is hijacked so the handler chain returns normally; HTTP/2
+// streams cannot be hijacked and are aborted instead.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler) // net/http drops the stream without logging
+	}
+	conn.Close()
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/middleware"
+	"github.com/distributed-api-gateway/gateway/observability"
+	"github.com/distributed-api-gateway/gateway/pkg/cache"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+	"github.com/distributed-api-gateway/gateway/proxy"
+)
+
+func TestProxyCoalescedFailureCountsOnce(t *testing.T) {
+	server, client := redistest.New(t)
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{
+		{PathPrefix: "/service-a", Target: backend.URL, Timeout: 500 * time.Millisecond, Coalesce: &config.Coalesce{}},
+	}})
+	h := ProxyHandler(routes, proxy.NewCoalescer(proxy.NewForwarder(), 1<<20), nil, nil, client)
+
+	const n = 3
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(http.MethodGet, "/service-a/items", nil))
+			codes[i] = rec.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusGatewayTimeout {
+			t.Errorf("Request %d: expected 504, got %d", i, code)
+		}
+	}
+	failures := 0
+	for _, key := range server.Keys() {
+		if strings.HasPrefix(key, "circuit:/service-a:window:") {
+			n, _ := strconv.Atoi(server.HGet(key, "failures"))
+			failures += n
+		}
+	}
+	if failures != 1 {
+		t.Errorf("Expected the shared upstream failure counted once, got %d", failures)
+	}
+}
+
+func TestProxyUsesMatchedRoute(t *testing.T) {
+	_, client := redistest.New(t)
+	matched := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("matched"))
+	}))
+	defer matched.Close()
+
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{
+		{PathPrefix: "/service-a", Target: "http://127.0.0.1:1"},
+	}})
+	h := ProxyHandler(routes, proxy.NewCoalescer(proxy.NewForwarder(), 1<<20), nil, nil, client)
+
+	// Matched by the auth middleware before the table was swapped
+	route := &config.Route{PathPrefix: "/service-a", Target: matched.URL, Timeout: time.Second}
+	r := httptest.NewRequest(http.MethodGet, "/service-a/items", nil)
+	rec := httptest.NewRecorder()
+	h(rec, r.WithContext(config.WithRoute(r.Context(), route)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "matched" {
+		t.Errorf("Expected the matched route proxied, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// A path unknown when it was matched stays unknown
+	r = httptest.NewRequest(http.MethodGet, "/service-a/items", nil)
+	rec = httptest.NewRecorder()
+	h(rec, r.WithContext(config.WithRoute(r.Context(), nil)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestProxyCacheKeepsSplitGroupsApart(t *testing.T) {
+	server, client := redistest.New(t)
+	backend := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte(body))
+		}))
+	}
+	stable, canary := backend("stable"), backend("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-a",
+		Target:     stable.URL,
+		Timeout:    time.Second,
+		Auth:       &config.RouteAuth{Mode: config.AuthModeNone},
+		Cache:      &config.RouteCache{TTL: time.Minute},
+		Split: &config.TrafficSplit{
+			OverrideHeader: "X-Canary",
+			Groups: []config.UpstreamGroup{
+				{Name: "stable", Target: stable.URL, Weight: 100},
+				{Name: "canary", Target: canary.URL, Weight: 0, Override: "yes"},
+			},
+		},
+	}}})
+	forwarder := proxy.NewForwarder()
+	responses := NewResponseCache(cache.NewStore(client), forwarder, 1<<20)
+	h := ProxyHandler(routes, proxy.NewCoalescer(forwarder, 1<<20), nil, responses, client)
+
+	get := func(canary bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/service-a/items", nil)
+		if canary {
+			r.Header.Set("X-Canary", "yes")
+		}
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+	// Entries are stored after the response is written
+	waitEntries := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			entries := 0
+			for _, key := range server.Keys() {
+				if strings.HasPrefix(key, cache.KeyPrefix+"/service-a:") {
+					entries++
+				}
+			}
+			if entries >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d cache entries, got %d", n, entries)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if rec := get(true); rec.Body.String() != "canary" {
+		t.Fatalf("Expected the canary response, got %q", rec.Body.String())
+	}
+	waitEntries(1)
+	if rec := get(false); rec.Body.String() != "stable" {
+		t.Errorf("Expected the stable group not to be served the canary entry, got %q", rec.Body.String())
+	}
+	waitEntries(2)
+	if rec := get(true); rec.Body.String() != "canary" || rec.Header().Get(CacheHeader) != "HIT" {
+		t.Errorf("Expected the canary entry served to the canary group, got %q (%s)", rec.Body.String(), rec.Header().Get(CacheHeader))
+	}
+}
+
+func TestProxyCanaryFailuresLeaveStableServing(t *testing.T) {
+	_, client := redistest.New(t)
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stable"))
+	}))
+	defer stable.Close()
+
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-a",
+		Target:     stable.URL,
+		Timeout:    time.Second,
+		Split: &config.TrafficSplit{
+			OverrideHeader: "X-Canary",
+			Groups: []config.UpstreamGroup{
+				{Name: "stable", Target: stable.URL, Weight: 100},
+				{Name: "canary", Target: "http://127.0.0.1:1", Weight: 0, Override: "yes"},
+			},
+		},
+	}}})
+	h := ProxyHandler(routes, proxy.NewCoalescer(proxy.NewForwarder(), 1<<20), nil, nil, client)
+	get := func(canary bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/service-a/items", nil)
+		if canary {
+			r.Header.Set("X-Canary", "yes")
+		}
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+
+	for i := 0; i < 10; i++ {
+		get(true)
+	}
+	if rec := get(true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the canary circuit open, got %d", rec.Code)
+	}
+	if rec := get(false); rec.Code != http.StatusOK || rec.Body.String() != "stable" {
+		t.Errorf("Expected the stable group still served, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestProxyFaultResetIsCounted(t *testing.T) {
+	_, client := redistest.New(t)
+	routes := config.NewRouteTable(&config.RoutesConfig{Routes: []config.Route{{
+		PathPrefix: "/service-reset",
+		Target:     "http://127.0.0.1:1",
+		Timeout:    time.Second,
+		Fault:      &config.Fault{Reset: &config.FaultReset{Percent: 100}},
+	}}})
+	h := ProxyHandler(routes, proxy.NewCoalescer(proxy.NewForwarder(), 1<<20), nil, nil, client)
+	srv := httptest.NewServer(middleware.Metrics()(http.HandlerFunc(h)))
+	defer srv.Close()
+
+	resets := observability.RequestsTotal.WithLabelValues("/service-reset", http.MethodGet, middleware.StatusReset, "")
+	before := testutil.ToFloat64(resets)
+	if resp, err := http.Get(srv.URL + "/service-reset/items"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the connection reset, got %d", resp.StatusCode)
+	}
+	if got := testutil.ToFloat64(resets) - before; got != 1 {
+		t.Errorf("Expected the reset counted once, got %v", got)
+	}
+}
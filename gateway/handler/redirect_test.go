@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		name string
+		port int
+		host string
+		want string
+	}{
+		{"default port", 443, "example.com:8080", "https://example.com/items?id=1"},
+		{"custom port", 8443, "example.com:8080", "https://example.com:8443/items?id=1"},
+		{"host without port", 8443, "example.com", "https://example.com:8443/items?id=1"},
+		{"ipv6 with port", 8443, "[::1]:8080", "https://[::1]:8443/items?id=1"},
+		{"ipv6 without port", 8443, "[::1]", "https://[::1]:8443/items?id=1"},
+		{"ipv6 default port", 443, "[::1]", "https://[::1]/items?id=1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items?id=1", nil)
+			r.Host = tc.host
+			rec := httptest.NewRecorder()
+			RedirectHTTPS(tc.port)(rec, r)
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("Expected 308, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tc.want {
+				t.Errorf("Expected Location %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
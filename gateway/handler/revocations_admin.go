@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/pkg/revocation"
+)
+
+// revocationRequest revokes one token by jti or all tokens of a subject
+type revocationRequest struct {
+	JTI       string     `json:"jti"`
+	ExpiresAt *time.Time `json:"expires_at"` // The token's exp; default now + max token lifetime
+	Subject   string     `json:"subject"`
+	Before    *time.Time `json:"before"` // Default now
+	Reason    string     `json:"reason"`
+}
+
+// RevocationsAdminHandler serves /admin/revocations:
+//
+//	GET                         revocations known to this instance
+//	POST                        revoke: {"jti": "..."} or {"subject": "..."}
+//	DELETE ?jti=X|?subject=Y    lift a revocation
+//
+// Revocations reach every instance through Redis Pub/Sub. maxLifetime is
+// the longest token lifetime; entries are kept until the tokens they cover
+// have expired, allowing for clockSkew on iat and exp.
+func RevocationsAdminHandler(list *revocation.List, maxLifetime, clockSkew time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"revocations": list.Entries()})
+
+		case http.MethodPost:
+			var req revocationRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid revocation request: "+err.Error())
+				return
+			}
+			if (req.JTI == "") == (req.Subject == "") {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "set exactly one of jti and subject")
+				return
+			}
+
+			now := time.Now()
+			var entry *revocation.Entry
+			var err error
+			if req.JTI != "" {
+				if req.Before != nil {
+					writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "before only applies to subject revocations")
+					return
+				}
+				// An unknown token was issued by now+skew at the latest
+				expires := now.Add(maxLifetime + 2*clockSkew)
+				if req.ExpiresAt != nil {
+					if !req.ExpiresAt.Add(clockSkew).After(now) {
+						writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "expires_at is in the past; the token has already expired")
+						return
+					}
+					expires = req.ExpiresAt.Add(clockSkew)
+				}
+				entry, err = list.RevokeToken(r.Context(), req.JTI, expires, adminUser(r), req.Reason)
+			} else {
+				if req.ExpiresAt != nil {
+					writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "expires_at only applies to jti revocations")
+					return
+				}
+				before := now
+				if req.Before != nil {
+					before = *req.Before
+				}
+				expires := before.Add(maxLifetime + clockSkew)
+				if !expires.After(now) {
+					writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "before is older than the max token lifetime; those tokens have already expired")
+					return
+				}
+				entry, err = list.RevokeSubject(r.Context(), req.Subject, before, expires, adminUser(r), req.Reason)
+			}
+			if err == revocation.ErrConflict {
+				writeError(w, r, http.StatusConflict, "CONFLICT", err.Error())
+				return
+			}
+			if err != nil {
+				writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+				return
+			}
+			log.Printf("Revoked %s %s by %s", entry.Kind, entry.ID, adminUser(r))
+			writeJSON(w, http.StatusCreated, entry)
+
+		case http.MethodDelete:
+			kind, id := revocation.KindJTI, r.URL.Query().Get("jti")
+			if sub := r.URL.Query().Get("subject"); sub != "" {
+				kind, id = revocation.KindSubject, sub
+			}
+			if id == "" || (kind == revocation.KindSubject && r.URL.Query().Get("jti") != "") {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "set exactly one of the jti and subject query parameters")
+				return
+			}
+			if err := list.Remove(r.Context(), kind, id); err != nil {
+				if err == revocation.ErrNotFound {
+					writeError(w, r, http.StatusNotFound, "NOT_FOUND", err.Error())
+					return
+				}
+				writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+				return
+			}
+			log.Printf("Lifted revocation of %s %s by %s", kind, id, adminUser(r))
+			writeJSON(w, http.StatusOK, map[string]interface{}{"removed": map[string]string{"kind": kind, "id": id}})
+
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET, POST or DELETE")
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/routestore"
+)
+
+// AdminUserHeader names the operator. Everyone holding the admin token can
+// send any name, so it is recorded as claimed, never as the actor.
+const AdminUserHeader = "X-Admin-User"
+
+// AdminActor is the identity proven by the shared admin token
+const AdminActor = "admin"
+
+const defaultAuditLimit = 100
+
+// RoutesAdminHandler serves /admin/routes:
+//
+//	GET                          current routes; ETag carries the version
+//	POST                         create a route (appended last)
+//	PUT    ?path_prefix=/prefix  replace a route
+//	DELETE ?path_prefix=/prefix  delete a route
+//
+// Writes require If-Match with the version they were based on and fail
+// with 409 VERSION_CONFLICT if the routes changed since.
+func RoutesAdminHandler(store *routestore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			snap, err := store.Get(r.Context())
+			if err != nil {
+				writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+				return
+			}
+			w.Header().Set("ETag", versionTag(snap.Version))
+			writeJSON(w, http.StatusOK, snap)
+			return
+		}
+
+		change := routestore.Change{
+			PathPrefix:   r.URL.Query().Get("path_prefix"),
+			Actor:        AdminActor,
+			ClaimedActor: claimedAdminUser(r),
+			RemoteAddr:   r.RemoteAddr,
+		}
+		switch r.Method {
+		case http.MethodPost:
+			change.Action = routestore.ActionCreate
+		case http.MethodPut:
+			change.Action = routestore.ActionUpdate
+		case http.MethodDelete:
+			change.Action = routestore.ActionDelete
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET, POST, PUT or DELETE")
+			return
+		}
+
+		if change.Action != routestore.ActionCreate && change.PathPrefix == "" {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "path_prefix query parameter is required")
+			return
+		}
+		if change.Action != routestore.ActionDelete {
+			// Unknown fields are rejected, as in routes files, so a typo
+			// is not silently dropped
+			var route config.Route
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&route); err != nil {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "invalid route: "+err.Error())
+				return
+			}
+			change.Route = &route
+		}
+
+		expected, ok := parseVersionTag(r.Header.Get("If-Match"))
+		if !ok {
+			writeError(w, r, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "If-Match with the current routes version is required")
+			return
+		}
+
+		snap, diags, err := store.Apply(r.Context(), expected, change)
+		if err != nil {
+			writeStoreError(w, r, snap, err)
+			return
+		}
+
+		status := http.StatusOK
+		if change.Action == routestore.ActionCreate {
+			status = http.StatusCreated
+		}
+		w.Header().Set("ETag", versionTag(snap.Version))
+		writeJSON(w, status, map[string]interface{}{
+			"version":  snap.Version,
+			"checksum": snap.Checksum,
+			"routes":   snap.Routes,
+			"warnings": diagnosticMessages(diags),
+		})
+	}
+}
+
+// RoutesAuditHandler serves GET /admin/routes/audit?limit=N, newest first
+func RoutesAuditHandler(store *routestore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET")
+			return
+		}
+
+		limit := defaultAuditLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "limit must be a positive integer")
+				return
+			}
+			limit = n
+		}
+
+		entries, err := store.Audit(r.Context(), limit)
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+	}
+}
+
+// RoutesVersionsHandler serves GET /admin/routes/versions: the kept route
+// table versions with checksums, newest first
+func RoutesVersionsHandler(store *routestore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET")
+			return
+		}
+
+		history, err := store.History(r.Context())
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"versions": history})
+	}
+}
+
+// RoutesRollbackHandler serves POST /admin/routes/rollback with a body of
+// {"version": N}. The routes of version N are stored as a new version, so
+// rollbacks are themselves audited and can be rolled back. If-Match is
+// required as for other writes.
+func RoutesRollbackHandler(store *routestore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use POST")
+			return
+		}
+
+		var body struct {
+			Version int64 `json:"version"`
+		}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&body); err != nil || body.Version <= 0 {
+			writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", "body must be {\"version\": N}")
+			return
+		}
+		expected, ok := parseVersionTag(r.Header.Get("If-Match"))
+		if !ok {
+			writeError(w, r, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "If-Match with the current routes version is required")
+			return
+		}
+
+		snap, _, err := store.Apply(r.Context(), expected, routestore.Change{
+			Action:       routestore.ActionRollback,
+			Version:      body.Version,
+			Actor:        AdminActor,
+			ClaimedActor: claimedAdminUser(r),
+			RemoteAddr:   r.RemoteAddr,
+		})
+		if err != nil {
+			writeStoreError(w, r, snap, err)
+			return
+		}
+		w.Header().Set("ETag", versionTag(snap.Version))
+		writeJSON(w, http.StatusOK, snap)
+	}
+}
+
+// RoutesViewHandler serves GET /routes: the table this instance is serving,
+// so operators can confirm every instance applied the same version
+func RoutesViewHandler(table *config.RouteTable, source string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use GET")
+			return
+		}
+
+		routes := table.Load()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"source":   source,
+			"version":  routes.Version,
+			"checksum": config.Checksum(routes.Routes),
+			"routes":   routes.Routes,
+		})
+	}
+}
+
+// writeStoreError maps route store errors to API errors. Anything not
+// caused by the request, such as a Redis failure, is a 503.
+func writeStoreError(w http.ResponseWriter, r *http.Request, current *routestore.Snapshot, err error) {
+	var verr *routestore.ValidationError
+	switch {
+	case errors.Is(err, routestore.ErrVersionConflict):
+		details := map[string]interface{}{}
+		if current != nil {
+			details["current_version"] = current.Version
+			w.Header().Set("ETag", versionTag(current.Version))
+		}
+		writeErrorDetails(w, r, http.StatusConflict, "VERSION_CONFLICT", err.Error(), details)
+	case errors.Is(err, routestore.ErrRouteExists):
+		writeError(w, r, http.StatusConflict, "ROUTE_EXISTS", err.Error())
+	case errors.Is(err, routestore.ErrRouteNotFound), errors.Is(err, routestore.ErrVersionNotFound):
+		writeError(w, r, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.As(err, &verr):
+		writeErrorDetails(w, r, http.StatusBadRequest, "INVALID_ROUTE", err.Error(), map[string]interface{}{
+			"problems": diagnosticMessages(verr.Diagnostics),
+		})
+	case errors.Is(err, routestore.ErrRouteRequired):
+		writeError(w, r, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+	default:
+		writeError(w, r, http.StatusServiceUnavailable, "STORE_UNAVAILABLE", err.Error())
+	}
+}
+
+// adminUser describes the caller for logs: the admin identity, plus the
+// claimed operator name marked as unverified
+func adminUser(r *http.Request) string {
+	if user := claimedAdminUser(r); user != "" {
+		return fmt.Sprintf("%s (claims %q, unverified)", AdminActor, user)
+	}
+	return AdminActor
+}
+
+// claimedAdminUser returns the operator name sent in X-Admin-User
+func claimedAdminUser(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(AdminUserHeader))
+}
+
+func diagnosticMessages(diags []config.Diagnostic) []string {
+	msgs := make([]string, 0, len(diags))
+	for _, d := range diags {
+		msgs = append(msgs, d.Severity+": "+d.Field+": "+d.Message)
+	}
+	return msgs
+}
+
+func versionTag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseVersionTag accepts "3", W/"3" or a bare 3
+func parseVersionTag(tag string) (int64, bool) {
+	tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	return version, err == nil && version >= 0
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+	"github.com/distributed-api-gateway/gateway/pkg/routestore"
+)
+
+func TestRoutesAdmin(t *testing.T) {
+	server, client := redistest.New(t)
+	store := routestore.NewStore(client, 10, 3)
+	seed := []config.Route{{PathPrefix: "/service-a", Target: "http://service-a:6000", Timeout: time.Second}}
+	if _, err := store.Sync(context.Background(), seed); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	routes := RoutesAdminHandler(store)
+
+	send := func(h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("If-Match", `"1"`)
+		r.Header.Set(AdminUserHeader, "alice")
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+
+	// A misspelled field is rejected rather than dropped
+	rec := send(routes, http.MethodPost, "/admin/routes", `{"path_prefix": "/service-b", "target": "http://service-b:6001", "timout": "5s"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "timout") {
+		t.Errorf("Expected 400 naming the unknown field, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The audit trail records the credential; the header only as a claim
+	rec = send(routes, http.MethodPost, "/admin/routes", `{"path_prefix": "/service-b", "target": "http://service-b:6001"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %s", rec.Code, rec.Body.String())
+	}
+	entries, _ := store.Audit(context.Background(), 1)
+	if len(entries) != 1 || entries[0].Actor != AdminActor || entries[0].ClaimedActor != "alice" {
+		t.Errorf("Expected actor admin claiming alice, got %+v", entries)
+	}
+
+	// A Redis failure while reading history is not the caller's fault
+	server.Del(routestore.HistoryKey)
+	server.Set(routestore.HistoryKey, "not a list")
+	r := httptest.NewRequest(http.MethodPost, "/admin/routes/rollback", strings.NewReader(`{"version": 1}`))
+	r.Header.Set("If-Match", `"2"`)
+	rec = httptest.NewRecorder()
+	RoutesRollbackHandler(store)(rec, r)
+	var resp ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if rec.Code != http.StatusServiceUnavailable || resp.Error.Code != "STORE_UNAVAILABLE" {
+		t.Errorf("Expected 503 STORE_UNAVAILABLE, got %d %+v", rec.Code, resp)
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/distributed-api-gateway/gateway/observability"
+	"github.com/distributed-api-gateway/gateway/pkg/trace"
+	"github.com/distributed-api-gateway/gateway/proxy"
+)
+
+// ShadowObserver records mirrored requests and response comparisons as
+// metrics, and comparisons as SHADOW trace steps of the original request
+type ShadowObserver struct{}
+
+// Mirrored implements proxy.MirrorObserver
+func (ShadowObserver) Mirrored(service, result string) {
+	observability.ShadowRequests.WithLabelValues(service, result).Inc()
+}
+
+// Compared implements proxy.MirrorObserver
+func (ShadowObserver) Compared(ctx context.Context, service string, c proxy.Comparison) {
+	observability.ShadowComparisons.WithLabelValues(service, c.Result).Inc()
+
+	status := trace.StatusFailed
+	switch c.Result {
+	case proxy.CompareMatch:
+		status = trace.StatusSuccess
+	case proxy.CompareSkipped:
+		status = trace.StatusSkipped
+	}
+	trace.EmitStep(ctx, trace.StepShadow, status, 0, map[string]interface{}{
+		"service":     service,
+		"result":      c.Result,
+		"differences": c.Differences,
+	})
+}
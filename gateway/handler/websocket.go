@@ -21,7 +21,8 @@ var upgrader = websocket.Upgrader{
 
 // TraceWebSocket handles WebSocket connections for trace streaming.
 // Endpoint: GET /ws/trace/{traceId}
-func TraceWebSocket(redisClient *goredis.Client) http.HandlerFunc {
+// Connections are closed with "going away" when the lifecycle starts draining.
+func TraceWebSocket(redisClient *goredis.Client, lifecycle *Lifecycle) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract trace ID from path: /ws/trace/{traceId}
 		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
@@ -31,6 +32,14 @@ func TraceWebSocket(redisClient *goredis.Client) http.HandlerFunc {
 		}
 		traceID := parts[2]
 
+		// Registered before the upgrade, so shutdown waits for it
+		release, ok := lifecycle.trackSocket()
+		if !ok {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
 		// Upgrade to WebSocket
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -117,6 +126,12 @@ func TraceWebSocket(redisClient *goredis.Client) http.HandlerFunc {
 			case <-done:
 				return
 
+			case <-lifecycle.Closing():
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "gateway shutting down"),
+					time.Now().Add(time.Second))
+				return
+
 			case <-ctx.Done():
 				return
 			}
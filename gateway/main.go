@@ -2,72 +2,314 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/distributed-api-gateway/gateway/config"
 	"github.com/distributed-api-gateway/gateway/handler"
 	"github.com/distributed-api-gateway/gateway/middleware"
+	"github.com/distributed-api-gateway/gateway/observability"
+	"github.com/distributed-api-gateway/gateway/pkg/apikey"
+	"github.com/distributed-api-gateway/gateway/pkg/cache"
+	"github.com/distributed-api-gateway/gateway/pkg/certs"
+	"github.com/distributed-api-gateway/gateway/pkg/connlimit"
+	"github.com/distributed-api-gateway/gateway/pkg/health"
 	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/mtls"
 	"github.com/distributed-api-gateway/gateway/pkg/ratelimit"
 	"github.com/distributed-api-gateway/gateway/pkg/redis"
+	"github.com/distributed-api-gateway/gateway/pkg/revocation"
+	"github.com/distributed-api-gateway/gateway/pkg/routestore"
 	"github.com/distributed-api-gateway/gateway/pkg/trace"
 	"github.com/distributed-api-gateway/gateway/proxy"
 )
 
 func main() {
-	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if opts.PrintConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("Failed to render configuration: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+	if opts.ConfigPath != "" {
+		log.Printf("Loaded config from %s", opts.ConfigPath)
+	}
 
 	// Load routes
-	routes, err := config.LoadRoutes(config.DefaultRoutesPath)
+	routes, err := cfg.LoadRoutes()
 	if err != nil {
 		log.Fatalf("Failed to load routes: %v", err)
 	}
 	log.Printf("Loaded %d routes", len(routes.Routes))
 
-	// Setup JWT validator
-	validator, err := jwt.NewValidator(config.DefaultPublicKeyPath, "")
-	if err != nil {
-		log.Fatalf("Failed to load JWT public key: %v", err)
+	// Setup JWT validator; a JWKS is refreshed in the background so signing
+	// keys can be rotated without restarts
+	policy := jwt.Policy{
+		Issuer:     cfg.Auth.Issuer,
+		Audience:   cfg.Auth.Audience,
+		ClockSkew:  cfg.Auth.ClockSkew,
+		MaxAge:     cfg.Auth.MaxAge,
+		RolesClaim: cfg.Auth.RolesClaim,
+	}
+	if policy.Issuer == "" && len(policy.Audience) == 0 {
+		log.Printf("JWT issuer and audience not set, tokens from any issuer are accepted")
+	}
+	var validator *jwt.Validator
+	if cfg.Auth.JWKS != "" {
+		validator, err = jwt.NewJWKSValidator(cfg.Auth.JWKS, policy, cfg.Auth.Algorithms)
+		if err != nil {
+			log.Fatalf("Invalid JWKS source: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = validator.Refresh(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("JWKS unavailable, JWT auth will fail until it loads: %v", err)
+		}
+		log.Printf("JWT auth enabled with JWKS %s (%d keys, refresh every %s)", cfg.Auth.JWKS, validator.KeyCount(), cfg.Auth.JWKSRefresh)
+	} else {
+		validator, err = jwt.NewValidator(cfg.Auth.PublicKeyPath, policy, cfg.Auth.Algorithms)
+		if err != nil {
+			log.Fatalf("Failed to load JWT public key: %v", err)
+		}
+		log.Printf("JWT auth enabled")
+	}
+
+	// Setup client certificate auth (verified during the TLS handshake)
+	var certAuth *mtls.Authenticator
+	if cfg.TLS.MTLSEnabled() {
+		certAuth, err = mtls.NewAuthenticator(cfg.TLS.ClientUserField, cfg.TLS.ClientIDField)
+		if err != nil {
+			log.Fatalf("Invalid mTLS configuration: %v", err)
+		}
+		log.Printf("mTLS auth enabled (user=%s, client=%s)", cfg.TLS.ClientUserField, cfg.TLS.ClientIDField)
 	}
-	log.Printf("JWT auth enabled")
 
 	// Setup Redis and rate limiter
-	redisClient := redis.New(config.DefaultRedisAddr)
+	redisClient := redis.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	err = redisClient.Ping(ctx)
 	cancel()
 	if err != nil {
 		log.Printf("Redis unavailable, rate limiting will fail-open: %v", err)
 	} else {
-		log.Printf("Redis connected at %s", config.DefaultRedisAddr)
+		log.Printf("Redis connected at %s", cfg.Redis.Addr)
+	}
+	limiter := ratelimit.NewLimiter(redisClient, cfg.RateLimit.Limit)
+	proxies, err := ratelimit.ParseProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	// API keys live in Redis; validated keys are cached per instance
+	keyStore := apikey.NewStore(redisClient)
+	keyAuth := apikey.NewAuthenticator(keyStore, cfg.APIKeys.Header, cfg.APIKeys.QueryParam, cfg.APIKeys.CacheTTL)
+
+	// Revoked JWTs are checked against a local copy of the Redis list
+	revocations := revocation.NewList(redisClient)
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	if err := revocations.Sync(ctx); err != nil {
+		log.Printf("Revocation list not loaded, not ready until it loads: %v", err)
+	} else {
+		log.Printf("Revocation list loaded (%d entries)", revocations.Len())
+	}
+	cancel()
+	validator.SetRevocations(revocations, cfg.Revocation.MaxTokenLifetime)
+
+	// Live route table; with the route store enabled it follows Redis
+	routeTable := config.NewRouteTable(routes)
+	var routeStore *routestore.Store
+	if cfg.RouteStore.Enabled {
+		routeStore = routestore.NewStore(redisClient, cfg.RouteStore.AuditLimit, cfg.RouteStore.History)
+		log.Printf("Route store enabled (resync every %s)", cfg.RouteStore.Resync)
 	}
-	limiter := ratelimit.NewLimiter(redisClient, config.DefaultRateLimit)
 
 	// Setup trace publisher for pipeline visualization
-	tracePublisher := trace.NewPublisher(redisClient.Raw())
-	log.Printf("Trace visualization enabled")
+	tracePublisher := trace.NewPublisher(nil)
+	if cfg.Tracing.Enabled {
+		tracePublisher = trace.NewPublisher(redisClient.Raw())
+		log.Printf("Trace visualization enabled")
+	}
 
 	// Create handlers and middleware chain: Trace → Metrics → Auth → RateLimit → Proxy
-	forwarder := proxy.NewForwarder()
-	proxyHandler := handler.ProxyHandler(routes, forwarder, redisClient)
-	rateLimitMiddleware := middleware.RateLimit(limiter)
-	authMiddleware := middleware.Auth(validator)
+	forwarder := proxy.NewForwarderWithConnectTimeout(cfg.Proxy.ConnectTimeout)
+	mirror := proxy.NewMirror(cfg.Proxy.ShadowMaxInFlight, cfg.Proxy.ShadowMaxBody, handler.ShadowObserver{})
+	cacheStore := cache.NewStore(redisClient)
+	responses := handler.NewResponseCache(cacheStore, forwarder, cfg.Cache.MaxBody)
+	coalescer := proxy.NewCoalescer(forwarder, cfg.Proxy.CoalesceMaxBody)
+	proxyHandler := handler.ProxyHandler(routeTable, coalescer, mirror, responses, redisClient)
+	rateLimitMiddleware := middleware.RateLimit(limiter, proxies)
+	authMiddleware := middleware.Auth(validator, certAuth, keyAuth, routeTable)
 	metricsMiddleware := middleware.Metrics()
 	traceMiddleware := middleware.Trace(tracePublisher)
 	log.Printf("Circuit breaker enabled")
 
+	// Readiness checks; HEALTH_READY_CHECKS decides which ones are critical
+	checker := health.NewChecker(cfg.Health.Timeout, cfg.Health.ReadyChecks)
+	checker.Register(handler.CheckRedis, handler.RedisCheck(redisClient))
+	checker.Register(handler.CheckRoutes, handler.RoutesCheck(routeTable))
+	checker.Register(handler.CheckKeys, handler.KeysCheck(validator))
+	checker.Register(handler.CheckUpstreams, handler.UpstreamsCheck(routeTable, cfg.Health.UpstreamInterval))
+	checker.Register(handler.CheckRevocations, handler.RevocationsCheck(revocations))
+	log.Printf("Readiness gated on: %v", cfg.Health.ReadyChecks)
+
 	// Create router
+	lifecycle := handler.NewLifecycle()
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", handler.HealthHandler())
+	mux.HandleFunc("/health", handler.HealthHandler(lifecycle))
+	mux.HandleFunc("/health/live", handler.LivenessHandler())
+	mux.HandleFunc("/health/ready", handler.ReadinessHandler(lifecycle, checker))
 	mux.Handle("/metrics", handler.MetricsHandler())
-	mux.HandleFunc("/ws/trace/", handler.TraceWebSocket(redisClient.Raw()))
+	mux.HandleFunc("/ws/trace/", handler.TraceWebSocket(redisClient.Raw(), lifecycle))
+	if cfg.Admin.Enabled() {
+		adminAuth := handler.AdminAuth(cfg.Admin.Token)
+		mux.Handle("/admin/routes/explain", adminAuth(handler.ExplainHandler(routeTable, validator, certAuth, keyAuth, redisClient, proxies)))
+		routeSource := "file"
+		if routeStore != nil {
+			routeSource = "redis"
+			mux.Handle("/admin/routes", adminAuth(handler.RoutesAdminHandler(routeStore)))
+			mux.Handle("/admin/routes/audit", adminAuth(handler.RoutesAuditHandler(routeStore)))
+			mux.Handle("/admin/routes/versions", adminAuth(handler.RoutesVersionsHandler(routeStore)))
+			mux.Handle("/admin/routes/rollback", adminAuth(handler.RoutesRollbackHandler(routeStore)))
+		}
+		mux.Handle("/routes", adminAuth(handler.RoutesViewHandler(routeTable, routeSource)))
+		mux.Handle("/admin/cache/purge", adminAuth(handler.CachePurgeHandler(cacheStore)))
+		mux.Handle("/admin/apikeys", adminAuth(handler.APIKeysAdminHandler(keyStore, keyAuth)))
+		mux.Handle("/admin/apikeys/rotate", adminAuth(handler.APIKeysRotateHandler(keyStore, keyAuth)))
+		mux.Handle("/admin/revocations", adminAuth(handler.RevocationsAdminHandler(revocations, cfg.Revocation.Lifetime(), cfg.Auth.ClockSkew)))
+		log.Printf("Admin API enabled")
+	}
 	mux.Handle("/", traceMiddleware(metricsMiddleware(authMiddleware(rateLimitMiddleware(proxyHandler)))))
 
+	// Stop on SIGTERM (container stop) or SIGINT (Ctrl+C)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	go validator.Watch(ctx, cfg.Auth.JWKSRefresh)
+	go revocations.Watch(ctx, redisClient.Raw(), cfg.Revocation.Resync)
+
+	if routeStore != nil {
+		go routeStore.Watch(ctx, redisClient.Raw(), cfg.RouteStore.Resync, routes.Routes, func(snap *routestore.Snapshot) {
+			routeTable.Swap(&config.RoutesConfig{Routes: snap.Routes, Version: snap.Version})
+			log.Printf("Applied routes version %d (%d routes, by %s)", snap.Version, len(snap.Routes), snap.UpdatedBy)
+		})
+	}
+
 	// Start server with CORS support for visualizer
-	log.Printf("Starting gateway on %s", cfg.Address())
-	if err := http.ListenAndServe(cfg.Address(), middleware.CORS(mux)); err != nil {
+	server := &http.Server{
+		Addr:              cfg.Address(),
+		Handler:           middleware.CORS(mux),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	var redirectServer *http.Server
+	serverErr := make(chan error, 2)
+
+	ln, err := net.Listen("tcp", cfg.Address())
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", cfg.Address(), err)
+	}
+	listener := connlimit.NewListener(ln, cfg.Server.MaxConnections, observability.OpenConnections)
+	log.Printf("Connection limit: %d (0 = unlimited)", cfg.Server.MaxConnections)
+
+	if !cfg.TLS.Enabled() {
+		log.Printf("Starting gateway on %s", cfg.Address())
+		go func() { serverErr <- server.Serve(listener) }()
+	} else {
+		// HTTPS with SNI certificate selection and hot reload
+		pairs := make([]certs.Pair, 0, len(cfg.TLS.Certificates))
+		for _, c := range cfg.TLS.Certificates {
+			pairs = append(pairs, certs.Pair{CertFile: c.CertFile, KeyFile: c.KeyFile})
+		}
+		certStore, err := certs.NewStore(pairs)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		server.TLSConfig, err = certs.ServerConfig(certStore, cfg.TLS.MinVersion, cfg.TLS.CipherSuites)
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		if cfg.TLS.MTLSEnabled() {
+			server.TLSConfig.ClientCAs, err = mtls.LoadCAPool(cfg.TLS.ClientCAFile)
+			if err != nil {
+				log.Fatalf("Failed to load client CA: %v", err)
+			}
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		go certStore.Watch(ctx, cfg.TLS.ReloadInterval)
+		log.Printf("TLS enabled with %d certificate(s), min version %s", len(pairs), cfg.TLS.MinVersion)
+
+		if cfg.TLS.RedirectPort != 0 {
+			redirectServer = &http.Server{
+				Addr:              cfg.RedirectAddress(),
+				Handler:           handler.RedirectHTTPS(cfg.Server.Port),
+				ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+				IdleTimeout:       cfg.Server.IdleTimeout,
+				MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+			}
+			log.Printf("Redirecting HTTP on %s to HTTPS", cfg.RedirectAddress())
+			go func() { serverErr <- redirectServer.ListenAndServe() }()
+		}
+
+		log.Printf("Starting gateway on %s (HTTPS)", cfg.Address())
+		go func() { serverErr <- server.ServeTLS(listener, "", "") }()
+	}
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process immediately
+
+	shutdown(cfg, lifecycle, redisClient, server, redirectServer)
+}
+
+// shutdown drains the gateway: report not-ready, stop accepting connections,
+// wait for in-flight requests and trace WebSockets, then close Redis.
+func shutdown(cfg *config.Config, lifecycle *handler.Lifecycle, redisClient *redis.Client, servers ...*http.Server) {
+	log.Printf("Shutdown signal received, draining for up to %s", cfg.Server.ShutdownDelay+cfg.Server.ShutdownGracePeriod)
+	lifecycle.StartDraining()
+
+	// Keep serving while load balancers observe the failing health check
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGracePeriod)
+	defer cancel()
+
+	for _, srv := range servers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Server shutdown incomplete: %v", err)
+		}
+	}
+	// Trace WebSockets get the rest of the grace period before being closed
+	if err := lifecycle.DrainSockets(ctx); err != nil {
+		log.Printf("Trace WebSockets still open at shutdown: %v", err)
+	}
+
+	if err := redisClient.Close(); err != nil {
+		log.Printf("Redis close error: %v", err)
 	}
+	log.Printf("Gateway stopped")
 }
@@ -1,60 +1,312 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/apikey"
 	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/mtls"
 	"github.com/distributed-api-gateway/gateway/pkg/trace"
 )
 
-// Auth returns middleware that validates JWT tokens.
-// On success, adds X-User-ID and X-Client-ID headers.
-func Auth(validator *jwt.Validator) func(http.Handler) http.Handler {
+// RouteMatcher resolves the route configuration for a request path
+type RouteMatcher interface {
+	MatchRoute(path string) *config.Route
+}
+
+// Auth returns middleware that authenticates callers by JWT, client
+// certificate or API key, depending on the auth methods of the matched
+// route.
+// On success, adds X-User-ID and X-Client-ID headers. Routes with auth
+// mode none, and optional routes called without credentials, pass
+// anonymous requests on without identity headers.
+// certAuth may be nil when mTLS is not configured.
+func Auth(validator *jwt.Validator, certAuth *mtls.Authenticator, keyAuth *apikey.Authenticator, routes RouteMatcher) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
+			// Match once; the proxy uses the same route even if the table
+			// is swapped meanwhile. Unknown paths fall through to the
+			// proxy's 404 after JWT auth.
+			route, matched := config.RouteFromContext(r.Context())
+			if !matched {
+				route = routes.MatchRoute(r.URL.Path)
+				r = r.WithContext(config.WithRoute(r.Context(), route))
+			}
+			if route == nil {
+				route = &config.Route{}
+			}
+			// Claim headers only ever come from a verified token
+			ApplyClaimHeaders(r, route, nil)
+			decision := DecideAuth(r, route, certAuth, keyAuth)
+
+			// API keys are never forwarded upstream
+			if keyAuth != nil && route.AcceptsAuth(config.AuthMethodAPIKey) {
+				keyAuth.Strip(r)
+			}
+
+			if decision.Anonymous {
+				// Anonymous callers may still need to log in for some methods
+				if decision.Mode == config.AuthModeOptional && route.Auth.Authorize(r.Method, nil, nil) != nil {
+					trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
+						"mode":  decision.Mode,
+						"error": "authentication required for " + r.Method,
+					})
+					writeAuthError(w, "UNAUTHORIZED", "authentication required for "+r.Method)
+					return
+				}
+				trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusSkipped, time.Since(start), map[string]interface{}{
+					"mode":      decision.Mode,
+					"anonymous": true,
+				})
+				clearIdentityHeaders(r)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch decision.Method {
+			case config.AuthMethodMTLS:
+				id, err := certAuth.Authenticate(r.TLS)
+				if err != nil {
+					trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
+						"method": config.AuthMethodMTLS,
+						"error":  err.Error(),
+					})
+					writeAuthError(w, "UNAUTHORIZED", err.Error())
+					return
+				}
+
+				trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusSuccess, time.Since(start), map[string]interface{}{
+					"method":    config.AuthMethodMTLS,
+					"user_id":   id.UserID,
+					"client_id": id.ClientID,
+					"subject":   id.Subject,
+				})
+				setIdentityHeaders(r, id.UserID, id.ClientID)
+				if !authorize(w, r, route, nil, nil) {
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+
+			case config.AuthMethodAPIKey:
+				authenticateKey(w, r, next, keyAuth, decision.APIKey, route, start)
+				return
+
+			case "":
+				trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
+					"error": "no supported authentication method",
+				})
+				writeAuthError(w, "UNAUTHORIZED", "no supported authentication method")
+				return
+			}
+
 			// Extract token from Authorization header
-			token := extractToken(r)
+			token := BearerToken(r)
 			if token == "" {
 				trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
 					"error": "missing authorization token",
 				})
-				writeAuthError(w, "missing authorization token")
+				writeAuthError(w, "UNAUTHORIZED", "missing authorization token")
 				return
 			}
 
-			// Validate token
-			claims, err := validator.Validate(token)
+			// Validate token against the route's issuer and audience
+			claims, err := validator.ValidateFor(token, TokenRequirements(route))
 			if err != nil {
+				code := "UNAUTHORIZED"
+				var jwtErr *jwt.Error
+				if errors.As(err, &jwtErr) {
+					code = jwtErr.Code
+				}
 				trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
 					"error": err.Error(),
+					"code":  code,
 				})
-				writeAuthError(w, err.Error())
+				writeAuthError(w, code, err.Error())
 				return
 			}
 
+			// Add user info to request headers for downstream
+			setIdentityHeaders(r, claims.Sub, claims.ClientID)
+			claimHeaders := ApplyClaimHeaders(r, route, claims)
+
 			// Emit success trace
-			trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusSuccess, time.Since(start), map[string]interface{}{
+			details := map[string]interface{}{
+				"method":    config.AuthMethodJWT,
 				"user_id":   claims.Sub,
 				"client_id": claims.ClientID,
-			})
-
-			// Add user info to request headers for downstream
-			r.Header.Set("X-User-ID", claims.Sub)
-			if claims.ClientID != "" {
-				r.Header.Set("X-Client-ID", claims.ClientID)
 			}
+			if len(claimHeaders) > 0 {
+				details["claim_headers"] = claimHeaders
+			}
+			trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusSuccess, time.Since(start), details)
 
+			if !authorize(w, r, route, claims.Scopes, claims.Roles) {
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// extractToken gets token from "Authorization: Bearer <token>"
-func extractToken(r *http.Request) string {
+// AuthDecision is how Auth will handle a request, decided before any
+// credential is verified
+type AuthDecision struct {
+	Mode      string // Route auth mode: required, optional or none
+	Anonymous bool   // Passed on without identity
+	Method    string // Method that authenticates the caller; "" when the route accepts none that is configured
+	APIKey    string // Key presented, when the route accepts API keys
+}
+
+// DecideAuth picks the auth method for r on route the way Auth does: a
+// client certificate first, then an API key, then a JWT. Optional routes
+// called without credentials are anonymous. The explain endpoint uses it
+// so its answer follows the real chain. certAuth and keyAuth may be nil
+// when the method is not configured.
+func DecideAuth(r *http.Request, route *config.Route, certAuth *mtls.Authenticator, keyAuth *apikey.Authenticator) AuthDecision {
+	acceptsJWT := route.AcceptsAuth(config.AuthMethodJWT)
+	acceptsMTLS := certAuth != nil && route.AcceptsAuth(config.AuthMethodMTLS)
+	acceptsAPIKey := keyAuth != nil && route.AcceptsAuth(config.AuthMethodAPIKey)
+
+	d := AuthDecision{Mode: route.AuthMode()}
+	if acceptsAPIKey {
+		d.APIKey = keyAuth.Extract(r)
+	}
+	presented := (acceptsMTLS && mtls.HasCertificate(r.TLS)) || d.APIKey != "" || (acceptsJWT && BearerToken(r) != "")
+
+	switch {
+	case d.Mode == config.AuthModeNone || (d.Mode == config.AuthModeOptional && !presented):
+		d.Anonymous = true
+	case acceptsMTLS && (mtls.HasCertificate(r.TLS) || (!acceptsJWT && d.APIKey == "")):
+		d.Method = config.AuthMethodMTLS
+	case d.APIKey != "" || (acceptsAPIKey && !acceptsJWT):
+		d.Method = config.AuthMethodAPIKey
+	case acceptsJWT:
+		d.Method = config.AuthMethodJWT
+	}
+	return d
+}
+
+// authenticateKey authenticates a request by API key and passes it on
+func authenticateKey(w http.ResponseWriter, r *http.Request, next http.Handler, keyAuth *apikey.Authenticator, token string, route *config.Route, start time.Time) {
+	if token == "" {
+		trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
+			"method": config.AuthMethodAPIKey,
+			"error":  "missing API key",
+		})
+		writeAuthError(w, "UNAUTHORIZED", "missing API key")
+		return
+	}
+
+	key, err := keyAuth.Authenticate(r.Context(), token)
+	if err != nil {
+		trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusFailed, time.Since(start), map[string]interface{}{
+			"method": config.AuthMethodAPIKey,
+			"error":  err.Error(),
+		})
+		if err == apikey.ErrUnavailable {
+			writeAuthUnavailable(w, err.Error())
+			return
+		}
+		writeAuthError(w, "UNAUTHORIZED", err.Error())
+		return
+	}
+
+	trace.EmitStep(r.Context(), trace.StepAuth, trace.StatusSuccess, time.Since(start), map[string]interface{}{
+		"method":    config.AuthMethodAPIKey,
+		"user_id":   key.Owner,
+		"client_id": key.ClientID,
+		"key_id":    key.ID,
+		"plan":      key.Plan,
+	})
+	setIdentityHeaders(r, key.Owner, key.ClientID)
+	if !authorize(w, r, route, key.Scopes, nil) {
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// authorize applies the route's scope and role rules to an authenticated
+// caller and answers 403 when they are not met. Routes without rules emit
+// no AUTHZ step.
+func authorize(w http.ResponseWriter, r *http.Request, route *config.Route, scopes, roles []string) bool {
+	if !route.Auth.HasRules() {
+		return true
+	}
+	start := time.Now()
+	if err := route.Auth.Authorize(r.Method, scopes, roles); err != nil {
+		trace.EmitStep(r.Context(), trace.StepAuthz, trace.StatusFailed, time.Since(start), map[string]interface{}{
+			"error": err.Error(),
+		})
+		writeForbidden(w, err.Error())
+		return false
+	}
+	trace.EmitStep(r.Context(), trace.StepAuthz, trace.StatusSuccess, time.Since(start), map[string]interface{}{
+		"scopes": scopes,
+		"roles":  roles,
+	})
+	return true
+}
+
+// TokenRequirements returns the issuer and audience a route requires of
+// JWTs; empty fields fall back to the global auth settings
+func TokenRequirements(route *config.Route) jwt.Requirements {
+	if route == nil || route.Auth == nil {
+		return jwt.Requirements{}
+	}
+	return jwt.Requirements{Issuer: route.Auth.Issuer, Audience: route.Auth.Audience}
+}
+
+// ApplyClaimHeaders removes client-sent copies of the route's claim headers
+// and, when claims is not nil, sets them from the token. Returns the
+// headers set; claims that are missing or unsafe as header values are
+// skipped.
+func ApplyClaimHeaders(r *http.Request, route *config.Route, claims *jwt.Claims) map[string]string {
+	if route.Auth == nil || len(route.Auth.ClaimHeaders) == 0 {
+		return nil
+	}
+	for _, ch := range route.Auth.ClaimHeaders {
+		r.Header.Del(ch.Header)
+	}
+	if claims == nil {
+		return nil
+	}
+	set := make(map[string]string)
+	for _, ch := range route.Auth.ClaimHeaders {
+		if value, ok := claims.HeaderValue(ch.Claim); ok {
+			r.Header.Set(ch.Header, value)
+			set[http.CanonicalHeaderKey(ch.Header)] = value
+		}
+	}
+	return set
+}
+
+// setIdentityHeaders replaces any client-sent identity headers with the
+// authenticated identity. X-Client-ID also keys rate limiting.
+func setIdentityHeaders(r *http.Request, userID, clientID string) {
+	r.Header.Set("X-User-ID", userID)
+	r.Header.Del("X-Client-ID")
+	if clientID != "" {
+		r.Header.Set("X-Client-ID", clientID)
+	}
+}
+
+// clearIdentityHeaders removes client-sent identity headers from anonymous
+// requests, so rate limiting falls back to the client IP
+func clearIdentityHeaders(r *http.Request) {
+	r.Header.Del("X-User-ID")
+	r.Header.Del("X-Client-ID")
+}
+
+// BearerToken gets token from "Authorization: Bearer <token>"
+func BearerToken(r *http.Request) string {
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
 		return ""
@@ -66,8 +318,24 @@ func extractToken(r *http.Request) string {
 	return parts[1]
 }
 
-func writeAuthError(w http.ResponseWriter, message string) {
+func writeAuthError(w http.ResponseWriter, code, message string) {
+	writeJSONError(w, http.StatusUnauthorized, code, message)
+}
+
+func writeAuthUnavailable(w http.ResponseWriter, message string) {
+	writeJSONError(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", message)
+}
+
+func writeForbidden(w http.ResponseWriter, message string) {
+	writeJSONError(w, http.StatusForbidden, "FORBIDDEN", message)
+}
+
+// writeJSONError writes the standard error body. Messages carry error text
+// such as role and scope names, so they are encoded, never concatenated.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
-	w.Write([]byte(`{"error":{"code":"UNAUTHORIZED","message":"` + message + `"}}`))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
 }
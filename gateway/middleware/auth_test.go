@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+func TestAuthKeepsMatchedRoute(t *testing.T) {
+	public := &config.RoutesConfig{Routes: []config.Route{
+		{PathPrefix: "/service-a", Target: "http://public:8080", Auth: &config.RouteAuth{Mode: config.AuthModeNone}},
+	}}
+	table := config.NewRouteTable(public)
+
+	var seen *config.Route
+	h := Auth(nil, nil, nil, table)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The table changes between auth and the proxy
+		table.Swap(&config.RoutesConfig{Routes: []config.Route{{PathPrefix: "/service-a", Target: "http://private:8080"}}})
+		seen, _ = config.RouteFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/service-a/items", nil))
+
+	if seen == nil || seen.Target != "http://public:8080" {
+		t.Errorf("Expected the route auth was decided on, got %+v", seen)
+	}
+}
+
+func TestAuthErrorsAreValidJSON(t *testing.T) {
+	writers := map[string]func(http.ResponseWriter, string){
+		"unauthorized": func(w http.ResponseWriter, msg string) { writeAuthError(w, "UNAUTHORIZED", msg) },
+		"forbidden":    writeForbidden,
+		"unavailable":  writeAuthUnavailable,
+	}
+	msg := `missing role "admin\ops"` + "\n"
+	for name, write := range writers {
+		rec := httptest.NewRecorder()
+		write(rec, msg)
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Message != msg {
+			t.Errorf("%s: expected valid JSON carrying the message, got %s (%v)", name, rec.Body.String(), err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,22 +19,41 @@ func Metrics() func(http.Handler) http.Handler {
 			// Wrap response writer to capture status code
 			rw := &metricsResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
-			next.ServeHTTP(rw, r)
+			// Later handlers fill in labels decided after routing
+			ctx, labels := observability.WithRequestLabels(r.Context())
+			defer func() {
+				// Connections closed without a response (injected resets) are
+				// still counted; an abort panic continues once recorded
+				status := strconv.Itoa(rw.statusCode)
+				aborted := recover()
+				if rw.hijacked || aborted == http.ErrAbortHandler {
+					status = StatusReset
+				}
 
-			// Extract service from path (e.g., "/service-a/hello" → "/service-a")
-			service := extractService(r.URL.Path)
-			duration := time.Since(start).Seconds()
+				// Extract service from path (e.g., "/service-a/hello" → "/service-a")
+				service := extractService(r.URL.Path)
+				duration := time.Since(start).Seconds()
 
-			// Record metrics
-			observability.RequestsTotal.WithLabelValues(service, r.Method, strconv.Itoa(rw.statusCode)).Inc()
-			observability.RequestDuration.WithLabelValues(service, r.Method).Observe(duration)
+				// Record metrics
+				observability.RequestsTotal.WithLabelValues(service, r.Method, status, labels.Split).Inc()
+				observability.RequestDuration.WithLabelValues(service, r.Method).Observe(duration)
+				if aborted != nil {
+					panic(aborted)
+				}
+			}()
+			next.ServeHTTP(rw, r.WithContext(ctx))
 		})
 	}
 }
 
+// StatusReset is the status label of requests whose connection was closed
+// without a response
+const StatusReset = "reset"
+
 type metricsResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	hijacked   bool
 }
 
 func (rw *metricsResponseWriter) WriteHeader(code int) {
@@ -40,6 +61,20 @@ func (rw *metricsResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack hands the connection to the handler, e.g. to reset it
+func (rw *metricsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // extractService gets service name from path: "/service-a/hello" → "/service-a"
 func extractService(path string) string {
 	if len(path) < 2 {
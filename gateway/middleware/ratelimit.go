@@ -11,17 +11,18 @@ import (
 )
 
 // RateLimit returns middleware that limits requests per client.
-// Uses X-Client-ID from JWT, falls back to X-Forwarded-For or RemoteAddr.
-func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+// Uses X-Client-ID from JWT, falls back to the client IP.
+func RateLimit(limiter *ratelimit.Limiter, proxies ratelimit.Proxies) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			key := getRateLimitKey(r)
+			key := RateLimitKey(r, proxies)
 			result := limiter.Allow(r.Context(), key)
 
 			// Add rate limit headers
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(ratelimit.DefaultLimit))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(0, ratelimit.DefaultLimit-result.Count)))
+			limit := limiter.Limit()
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(0, limit-result.Count)))
 
 			if !result.Allowed {
 				w.Header().Set("Retry-After", strconv.Itoa(result.RetryAfter))
@@ -30,7 +31,7 @@ func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
 				trace.EmitStep(r.Context(), trace.StepRateLimit, trace.StatusFailed, time.Since(start), map[string]interface{}{
 					"client_id":   key,
 					"count":       result.Count,
-					"limit":       ratelimit.DefaultLimit,
+					"limit":       limit,
 					"retry_after": result.RetryAfter,
 				})
 				writeRateLimitError(w)
@@ -40,7 +41,7 @@ func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
 			trace.EmitStep(r.Context(), trace.StepRateLimit, trace.StatusSuccess, time.Since(start), map[string]interface{}{
 				"client_id": key,
 				"count":     result.Count,
-				"remaining": ratelimit.DefaultLimit - result.Count,
+				"remaining": limit - result.Count,
 			})
 
 			next.ServeHTTP(w, r)
@@ -48,15 +49,16 @@ func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
 	}
 }
 
-// getRateLimitKey returns the key for rate limiting: client_id > X-Forwarded-For > RemoteAddr
-func getRateLimitKey(r *http.Request) string {
+// RateLimitKey returns the key for rate limiting: "client:" and the
+// client_id, else "ip:" and the client IP as resolved through proxies. The
+// prefixes keep a client_id from ever sharing an IP's quota. X-Client-ID
+// is set by Auth from the authenticated identity and removed for anonymous
+// callers.
+func RateLimitKey(r *http.Request, proxies ratelimit.Proxies) string {
 	if clientID := r.Header.Get("X-Client-ID"); clientID != "" {
-		return clientID
+		return "client:" + clientID
 	}
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-	return r.RemoteAddr
+	return "ip:" + proxies.ClientIP(r)
 }
 
 func writeRateLimitError(w http.ResponseWriter) {
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distributed-api-gateway/gateway/pkg/ratelimit"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	proxies, _ := ratelimit.ParseProxies([]string{"10.0.0.0/8"})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:5123"
+	if key := RateLimitKey(r, proxies); key != "ip:203.0.113.7" {
+		t.Errorf("Expected connection address, got %s", key)
+	}
+
+	// A client rotating X-Forwarded-For keeps its key
+	for _, spoof := range []string{"1.1.1.1", "2.2.2.2, 10.0.0.3"} {
+		r.Header.Set("X-Forwarded-For", spoof)
+		if key := RateLimitKey(r, proxies); key != "ip:203.0.113.7" {
+			t.Errorf("Expected spoofed X-Forwarded-For %q ignored, got %s", spoof, key)
+		}
+	}
+
+	// A client_id that looks like an IP key gets its own quota
+	r.Header.Set("X-Client-ID", "ip:203.0.113.7")
+	if key := RateLimitKey(r, proxies); key != "client:ip:203.0.113.7" {
+		t.Errorf("Expected client namespace, got %s", key)
+	}
+}
@@ -0,0 +1,24 @@
+package observability
+
+import "context"
+
+type labelsKey struct{}
+
+// RequestLabels carries metric labels that are only known after routing
+// (such as the traffic split group) back to the metrics middleware
+type RequestLabels struct {
+	Split string // Upstream group name, empty for routes without a split
+}
+
+// WithRequestLabels attaches an empty label set to ctx
+func WithRequestLabels(ctx context.Context) (context.Context, *RequestLabels) {
+	labels := &RequestLabels{}
+	return context.WithValue(ctx, labelsKey{}, labels), labels
+}
+
+// LabelsFromContext returns the request's label set, or nil outside the
+// metrics middleware
+func LabelsFromContext(ctx context.Context) *RequestLabels {
+	labels, _ := ctx.Value(labelsKey{}).(*RequestLabels)
+	return labels
+}
@@ -6,13 +6,14 @@ import (
 )
 
 var (
-	// RequestsTotal counts total requests by service, method, and status
+	// RequestsTotal counts total requests by service, method, status and
+	// traffic split group (empty for routes without a split)
 	RequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "gateway_requests_total",
 			Help: "Total number of requests processed by the gateway",
 		},
-		[]string{"service", "method", "status"},
+		[]string{"service", "method", "status", "split"},
 	)
 
 	// RequestDuration tracks request latency
@@ -42,6 +43,64 @@ var (
 		},
 		[]string{"service"},
 	)
+
+	// ShadowRequests counts mirrored requests by service and result
+	// (status class, error, dropped or too_large)
+	ShadowRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_shadow_requests_total",
+			Help: "Total number of requests mirrored to shadow upstreams",
+		},
+		[]string{"service", "result"},
+	)
+
+	// ShadowComparisons counts shadow responses compared against the
+	// primary by service and result (match, *_mismatch or skipped)
+	ShadowComparisons = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_shadow_comparisons_total",
+			Help: "Total number of shadow responses compared against the primary",
+		},
+		[]string{"service", "result"},
+	)
+
+	// FaultsInjected counts injected faults by service and fault
+	// (delay, abort or reset)
+	FaultsInjected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_faults_injected_total",
+			Help: "Total number of faults injected into routed requests",
+		},
+		[]string{"service", "fault"},
+	)
+
+	// CacheRequests counts cached-route GETs by service and result
+	// (hit, stale, revalidated, miss, bypass or error)
+	CacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_cache_requests_total",
+			Help: "Total number of response cache lookups",
+		},
+		[]string{"service", "result"},
+	)
+
+	// CoalescedRequests counts requests answered with the upstream response
+	// of an identical request already in flight
+	CoalescedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_coalesced_requests_total",
+			Help: "Total number of requests that shared an in-flight upstream request",
+		},
+		[]string{"service"},
+	)
+
+	// OpenConnections tracks client connections currently open on the listener
+	OpenConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_open_connections",
+			Help: "Number of open client connections",
+		},
+	)
 )
 
 // Circuit breaker state values
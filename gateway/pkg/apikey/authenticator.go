@@ -0,0 +1,150 @@
+package apikey
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCached bounds the local cache; unknown IDs are cached too, so made-up
+// keys cannot grow it without limit
+const maxCached = 10000
+
+var (
+	ErrInvalidKey  = errors.New("invalid API key")
+	ErrKeyDisabled = errors.New("API key disabled")
+	ErrKeyExpired  = errors.New("API key expired")
+	// ErrUnavailable means the key store could not be reached
+	ErrUnavailable = errors.New("API key store unavailable")
+)
+
+// Authenticator validates API keys sent in a header or query parameter.
+// Records are cached locally for ttl, so a key costs one Redis read per
+// ttl and instance; changes made on other instances apply within ttl.
+type Authenticator struct {
+	store      *Store
+	header     string
+	queryParam string // Empty = header only
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	rec     *record // nil for unknown IDs
+	expires time.Time
+}
+
+// NewAuthenticator creates an authenticator reading keys from header and,
+// if queryParam is set, from that query parameter
+func NewAuthenticator(store *Store, header, queryParam string, ttl time.Duration) *Authenticator {
+	return &Authenticator{
+		store:      store,
+		header:     header,
+		queryParam: queryParam,
+		ttl:        ttl,
+		now:        time.Now,
+		cache:      make(map[string]cached),
+	}
+}
+
+// Extract returns the key sent with r, or "" if none
+func (a *Authenticator) Extract(r *http.Request) string {
+	if key := r.Header.Get(a.header); key != "" {
+		return key
+	}
+	if a.queryParam != "" {
+		return r.URL.Query().Get(a.queryParam)
+	}
+	return ""
+}
+
+// Strip removes the key from r so it is not forwarded upstream
+func (a *Authenticator) Strip(r *http.Request) {
+	r.Header.Del(a.header)
+	if a.queryParam == "" {
+		return
+	}
+	if q := r.URL.Query(); q.Has(a.queryParam) {
+		q.Del(a.queryParam)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+// Authenticate returns the metadata of a valid, enabled and unexpired key
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Key, error) {
+	id, ok := parseToken(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	rec, err := a.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrInvalidKey
+	}
+
+	now := a.now()
+	hash := []byte(hashToken(token))
+	valid := subtle.ConstantTimeCompare(hash, []byte(rec.Hash)) == 1
+	if !valid && rec.PreviousHash != "" && rec.PreviousUntil != nil && now.Before(*rec.PreviousUntil) {
+		valid = subtle.ConstantTimeCompare(hash, []byte(rec.PreviousHash)) == 1
+	}
+	if !valid {
+		return nil, ErrInvalidKey
+	}
+	if !rec.Enabled {
+		return nil, ErrKeyDisabled
+	}
+	if rec.ExpiresAt != nil && !now.Before(*rec.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+	key := rec.Key
+	return &key, nil
+}
+
+// Invalidate drops the cached record of key id, e.g. after an admin change
+// on this instance
+func (a *Authenticator) Invalidate(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, id)
+}
+
+// lookup returns the record of id from the cache or the store; nil means
+// the ID is unknown
+func (a *Authenticator) lookup(ctx context.Context, id string) (*record, error) {
+	now := a.now()
+	a.mu.Lock()
+	entry, ok := a.cache[id]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.rec, nil
+	}
+
+	rec, _, err := a.store.get(ctx, id)
+	if err != nil && err != ErrNotFound {
+		return nil, ErrUnavailable
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCached {
+		for cachedID, e := range a.cache {
+			if !now.Before(e.expires) {
+				delete(a.cache, cachedID)
+			}
+		}
+		if len(a.cache) >= maxCached {
+			clear(a.cache)
+		}
+	}
+	a.cache[id] = cached{rec: rec, expires: now.Add(a.ttl)}
+	return rec, nil
+}
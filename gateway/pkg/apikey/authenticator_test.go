@@ -0,0 +1,153 @@
+package apikey
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *Store, *miniredis.Miniredis, *time.Time) {
+	store, server := newTestStore(t)
+	auth := NewAuthenticator(store, "X-API-Key", "api_key", time.Minute)
+	now := testNow
+	auth.now = func() time.Time { return now }
+	return auth, store, server, &now
+}
+
+func TestAuthenticate(t *testing.T) {
+	auth, store, _, now := newTestAuthenticator(t)
+	ctx := context.Background()
+	expires := testNow.Add(time.Hour)
+	token, key, _ := store.Create(ctx, Key{ClientID: "billing-sync", Owner: "team-billing", Enabled: true, ExpiresAt: &expires})
+	disabledToken, _, _ := store.Create(ctx, Key{ClientID: "old", Owner: "o"})
+
+	got, err := auth.Authenticate(ctx, token)
+	if err != nil || got.ID != key.ID || got.ClientID != "billing-sync" {
+		t.Fatalf("Expected valid key, got %+v (%v)", got, err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"malformed", "not-a-key", ErrInvalidKey},
+		{"unknown ID", "gk_0123456789abcdef_c2VjcmV0", ErrInvalidKey},
+		{"wrong secret", token[:len(token)-4] + "AAAA", ErrInvalidKey},
+		{"disabled", disabledToken, ErrKeyDisabled},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := auth.Authenticate(ctx, tc.token); err != tc.err {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	*now = expires
+	if _, err := auth.Authenticate(ctx, token); err != ErrKeyExpired {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+}
+
+func TestAuthenticateCache(t *testing.T) {
+	auth, store, server, now := newTestAuthenticator(t)
+	ctx := context.Background()
+	token, key, _ := store.Create(ctx, Key{ClientID: "c", Owner: "o", Enabled: true})
+
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	commands := server.CommandCount()
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Authenticate(ctx, token); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+	}
+	if n := server.CommandCount() - commands; n != 0 {
+		t.Errorf("Expected cached key served without Redis, got %d commands", n)
+	}
+
+	// A change on another instance applies once the entry expires
+	store.SetEnabled(ctx, key.ID, false)
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Errorf("Expected cached key to stay valid within the TTL, got %v", err)
+	}
+	*now = now.Add(time.Minute)
+	if _, err := auth.Authenticate(ctx, token); err != ErrKeyDisabled {
+		t.Errorf("Expected ErrKeyDisabled after the TTL, got %v", err)
+	}
+
+	// Local changes apply at once
+	store.SetEnabled(ctx, key.ID, true)
+	auth.Invalidate(key.ID)
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Errorf("Expected re-enabled key after invalidation, got %v", err)
+	}
+
+	// Unknown IDs are cached as well
+	auth.Authenticate(ctx, "gk_0123456789abcdef_c2VjcmV0")
+	commands = server.CommandCount()
+	for i := 0; i < 2; i++ {
+		auth.Authenticate(ctx, "gk_0123456789abcdef_c2VjcmV0")
+	}
+	if n := server.CommandCount() - commands; n != 0 {
+		t.Errorf("Expected cached unknown ID served without Redis, got %d commands", n)
+	}
+}
+
+func TestAuthenticateRotationGrace(t *testing.T) {
+	auth, store, _, now := newTestAuthenticator(t)
+	ctx := context.Background()
+	old, key, _ := store.Create(ctx, Key{ClientID: "c", Owner: "o", Enabled: true})
+	token, _, _ := store.Rotate(ctx, key.ID, time.Hour)
+
+	for _, tok := range []string{old, token} {
+		if _, err := auth.Authenticate(ctx, tok); err != nil {
+			t.Errorf("Expected both keys valid during the grace period, got %v", err)
+		}
+	}
+	*now = now.Add(time.Hour)
+	if _, err := auth.Authenticate(ctx, old); err != ErrInvalidKey {
+		t.Errorf("Expected old key rejected after the grace period, got %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Errorf("Expected new key valid, got %v", err)
+	}
+}
+
+func TestAuthenticateUnavailable(t *testing.T) {
+	auth, store, server, _ := newTestAuthenticator(t)
+	token, _, _ := store.Create(context.Background(), Key{ClientID: "c", Owner: "o", Enabled: true})
+	server.SetError("LOADING Redis is loading the dataset in memory")
+
+	if _, err := auth.Authenticate(context.Background(), token); err != ErrUnavailable {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestExtractAndStrip(t *testing.T) {
+	auth, _, _, _ := newTestAuthenticator(t)
+
+	r := httptest.NewRequest("GET", "/billing/invoices?api_key=gk_query&page=2", nil)
+	if key := auth.Extract(r); key != "gk_query" {
+		t.Errorf("Expected key from query, got %q", key)
+	}
+	r.Header.Set("X-API-Key", "gk_header")
+	if key := auth.Extract(r); key != "gk_header" {
+		t.Errorf("Expected header to win, got %q", key)
+	}
+
+	auth.Strip(r)
+	if r.Header.Get("X-API-Key") != "" || r.URL.RawQuery != "page=2" {
+		t.Errorf("Expected key removed, got header %q and query %q", r.Header.Get("X-API-Key"), r.URL.RawQuery)
+	}
+
+	headerOnly := NewAuthenticator(nil, "X-API-Key", "", time.Minute)
+	if key := headerOnly.Extract(httptest.NewRequest("GET", "/?api_key=gk_query", nil)); key != "" {
+		t.Errorf("Expected query ignored without a query parameter, got %q", key)
+	}
+}
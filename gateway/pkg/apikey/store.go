@@ -0,0 +1,308 @@
+// Package apikey authenticates machine clients by API key. Keys are kept
+// in Redis as SHA-256 hashes next to their metadata, so a Redis dump does
+// not reveal usable keys; the key itself is only shown when it is created
+// or rotated.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+)
+
+// Redis key layout
+const (
+	KeyPrefix = "apikey:"     // apikey:{id} holds a JSON record
+	IndexKey  = "apikeys:ids" // Set of all key IDs, for listing
+)
+
+// Keys look like gk_{id}_{secret}: the ID finds the record, the secret
+// makes the key unguessable
+const (
+	tokenPrefix = "gk_"
+	idBytes     = 8
+	secretBytes = 32
+)
+
+var (
+	// ErrNotFound means no key has the given ID
+	ErrNotFound = errors.New("API key not found")
+	// ErrConflict means the key changed while it was being updated
+	ErrConflict = errors.New("API key was modified concurrently")
+)
+
+// Key is the metadata of an API key
+type Key struct {
+	ID        string     `json:"id"`
+	ClientID  string     `json:"client_id"` // X-Client-ID, also the rate limit key
+	Owner     string     `json:"owner"`     // X-User-ID
+	Scopes    []string   `json:"scopes,omitempty"`
+	Plan      string     `json:"plan,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil = never
+	Enabled   bool       `json:"enabled"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+}
+
+// record is a key as stored. After a rotation the previous secret stays
+// valid until PreviousUntil, so clients can switch without downtime.
+type record struct {
+	Key
+	Hash          string     `json:"hash"` // SHA-256 of the full key, hex
+	PreviousHash  string     `json:"previous_hash,omitempty"`
+	PreviousUntil *time.Time `json:"previous_until,omitempty"`
+}
+
+// Returns the stored value, or "" if none
+const getScript = `return redis.call('GET', KEYS[1]) or ''`
+
+// Stores a new record unless the ID is taken; returns 1 if stored
+const createScript = `
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX') then
+    return 0
+end
+redis.call('SADD', KEYS[2], ARGV[2])
+return 1
+`
+
+// Replaces a record if it still holds ARGV[1].
+// Returns 1 on success, 0 on conflict and -1 if the key is gone.
+const updateScript = `
+local current = redis.call('GET', KEYS[1])
+if not current then
+    return -1
+end
+if current ~= ARGV[1] then
+    return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1
+`
+
+// Deletes a record; returns the number of records deleted
+const deleteScript = `
+redis.call('SREM', KEYS[2], ARGV[1])
+return redis.call('DEL', KEYS[1])
+`
+
+// Returns every stored record
+const listScript = `
+local ids = redis.call('SMEMBERS', KEYS[1])
+local records = {}
+for _, id in ipairs(ids) do
+    local data = redis.call('GET', ARGV[1] .. id)
+    if data then
+        table.insert(records, data)
+    end
+end
+return records
+`
+
+// Store reads and writes API keys in Redis
+type Store struct {
+	redis redis.Evaluator
+	now   func() time.Time
+}
+
+// NewStore creates an API key store
+func NewStore(client redis.Evaluator) *Store {
+	return &Store{redis: client, now: time.Now}
+}
+
+// Create stores a new key with meta's client ID, owner, scopes, plan,
+// expiry and enabled flag, and returns the key. It cannot be read back.
+func (s *Store) Create(ctx context.Context, meta Key) (string, *Key, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", nil, err
+	}
+	token, hash, err := newToken(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	meta.ID = id
+	meta.CreatedAt = s.now().UTC()
+	meta.RotatedAt = nil
+	data, err := json.Marshal(record{Key: meta, Hash: hash})
+	if err != nil {
+		return "", nil, err
+	}
+	result, err := s.redis.Eval(ctx, createScript, []string{KeyPrefix + id, IndexKey}, string(data), id)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	if n, _ := result.(int64); n != 1 {
+		return "", nil, errors.New("API key ID collision")
+	}
+	return token, &meta, nil
+}
+
+// Get returns the metadata of key id
+func (s *Store) Get(ctx context.Context, id string) (*Key, error) {
+	rec, _, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Key, nil
+}
+
+// List returns every key, unordered
+func (s *Store) List(ctx context.Context) ([]Key, error) {
+	result, err := s.redis.Eval(ctx, listScript, []string{IndexKey}, KeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	raw, _ := result.([]interface{})
+	keys := make([]Key, 0, len(raw))
+	for _, item := range raw {
+		var rec record
+		if data, ok := item.(string); ok && json.Unmarshal([]byte(data), &rec) == nil {
+			keys = append(keys, rec.Key)
+		}
+	}
+	return keys, nil
+}
+
+// Rotate replaces the secret of key id and returns the new key. With a
+// grace period the old key keeps working until it ends.
+func (s *Store) Rotate(ctx context.Context, id string, grace time.Duration) (string, *Key, error) {
+	var token string
+	rec, err := s.update(ctx, id, func(rec *record) error {
+		var hash string
+		var err error
+		if token, hash, err = newToken(id); err != nil {
+			return err
+		}
+		now := s.now().UTC()
+		rec.PreviousHash, rec.PreviousUntil = "", nil
+		if grace > 0 {
+			until := now.Add(grace)
+			rec.PreviousHash, rec.PreviousUntil = rec.Hash, &until
+		}
+		rec.Hash = hash
+		rec.RotatedAt = &now
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &rec.Key, nil
+}
+
+// SetEnabled enables or disables key id without deleting it
+func (s *Store) SetEnabled(ctx context.Context, id string, enabled bool) (*Key, error) {
+	rec, err := s.update(ctx, id, func(rec *record) error {
+		rec.Enabled = enabled
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Key, nil
+}
+
+// Delete revokes key id for good
+func (s *Store) Delete(ctx context.Context, id string) error {
+	result, err := s.redis.Eval(ctx, deleteScript, []string{KeyPrefix + id, IndexKey}, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// get returns the record of key id and its stored form
+func (s *Store) get(ctx context.Context, id string) (*record, string, error) {
+	result, err := s.redis.Eval(ctx, getScript, []string{KeyPrefix + id})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read API key: %w", err)
+	}
+	data, _ := result.(string)
+	if data == "" {
+		return nil, "", ErrNotFound
+	}
+	var rec record
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil, "", fmt.Errorf("failed to decode API key %s: %w", id, err)
+	}
+	return &rec, data, nil
+}
+
+// update applies change to key id unless it was modified meanwhile
+func (s *Store) update(ctx context.Context, id string, change func(*record) error) (*record, error) {
+	rec, before, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(rec); err != nil {
+		return nil, err
+	}
+	after, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.redis.Eval(ctx, updateScript, []string{KeyPrefix + id}, before, string(after))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key: %w", err)
+	}
+	switch n, _ := result.(int64); n {
+	case 1:
+		return rec, nil
+	case -1:
+		return nil, ErrNotFound
+	}
+	return nil, ErrConflict
+}
+
+func randomID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newToken returns a fresh key for id and its hash
+func newToken(id string) (token, hash string, err error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken hashes a key for storage. Keys carry 256 random bits, so a
+// plain SHA-256 cannot be brute-forced and keeps lookups cheap.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseToken returns the ID of a well-formed key
+func parseToken(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 2*idBytes || secret == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,126 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+var testNow = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	server, client := redistest.New(t)
+	store := NewStore(client)
+	store.now = func() time.Time { return testNow }
+	return store, server
+}
+
+func TestStoreCreate(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+
+	token, key, err := store.Create(ctx, Key{ClientID: "billing-sync", Owner: "team-billing", Scopes: []string{"invoices:read"}, Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if id, ok := parseToken(token); !ok || id != key.ID {
+		t.Errorf("Expected token for key %s, got %s", key.ID, token)
+	}
+	if !key.CreatedAt.Equal(testNow) {
+		t.Errorf("Expected creation time, got %v", key.CreatedAt)
+	}
+
+	stored, _ := server.Get(KeyPrefix + key.ID)
+	if strings.Contains(stored, token) || !strings.Contains(stored, hashToken(token)) {
+		t.Errorf("Expected only the hash to be stored, got %s", stored)
+	}
+
+	got, err := store.Get(ctx, key.ID)
+	if err != nil || got.ClientID != "billing-sync" || got.Scopes[0] != "invoices:read" {
+		t.Errorf("Expected stored metadata, got %+v (%v)", got, err)
+	}
+	keys, err := store.List(ctx)
+	if err != nil || len(keys) != 1 || keys[0].ID != key.ID {
+		t.Errorf("Expected key in list, got %+v (%v)", keys, err)
+	}
+}
+
+func TestStoreRotateAndDelete(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+	old, key, _ := store.Create(ctx, Key{ClientID: "c", Owner: "o", Enabled: true})
+
+	token, rotated, err := store.Rotate(ctx, key.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if token == old || rotated.RotatedAt == nil {
+		t.Errorf("Expected a new key and rotation time, got %s %+v", token, rotated)
+	}
+	rec, _, _ := store.get(ctx, key.ID)
+	if rec.Hash != hashToken(token) || rec.PreviousHash != hashToken(old) || !rec.PreviousUntil.Equal(testNow.Add(time.Hour)) {
+		t.Errorf("Expected old hash kept for the grace period, got %+v", rec)
+	}
+
+	// Without grace the old key stops working at once
+	if _, _, err := store.Rotate(ctx, key.ID, 0); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if rec, _, _ := store.get(ctx, key.ID); rec.PreviousHash != "" {
+		t.Errorf("Expected no previous key without grace, got %+v", rec)
+	}
+
+	if err := store.Delete(ctx, key.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, key.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, key.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a second delete, got %v", err)
+	}
+	if _, _, err := store.Rotate(ctx, key.ID, 0); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound rotating a deleted key, got %v", err)
+	}
+}
+
+func TestStoreUpdateConflict(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+	_, key, _ := store.Create(ctx, Key{ClientID: "c", Owner: "o", Enabled: true})
+
+	// Another instance changes the key between read and write
+	_, err := store.update(ctx, key.ID, func(rec *record) error {
+		server.Set(KeyPrefix+key.ID, `{"id":"`+key.ID+`","enabled":false}`)
+		rec.Enabled = false
+		return nil
+	})
+	if err != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		token string
+		ok    bool
+	}{
+		{"gk_0123456789abcdef_c2VjcmV0", true},
+		{"gk_0123456789abcdef_a_b", true}, // Secrets may contain _
+		{"gk_0123456789abcdef_", false},
+		{"gk_0123_c2VjcmV0", false},
+		{"gk_0123456789abcdeg_c2VjcmV0", false},
+		{"0123456789abcdef_c2VjcmV0", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		if _, ok := parseToken(tc.token); ok != tc.ok {
+			t.Errorf("parseToken(%q): expected %v", tc.token, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// hopHeaders are never stored: they describe one connection or one request
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade",
+	"Trailer", "Te", "Age", "X-Request-Id", "X-Cache",
+}
+
+// Entry is a cached response
+type Entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+
+	// Stored is when the response was generated, adjusted by its Age
+	Stored time.Time     `json:"stored"`
+	Fresh  time.Duration `json:"fresh"` // Freshness lifetime
+	Stale  time.Duration `json:"stale"` // stale-while-revalidate window after Fresh
+	Vary   []string      `json:"vary,omitempty"`
+	Tags   []string      `json:"tags,omitempty"` // Surrogate keys for purging
+	Path   string        `json:"path,omitempty"` // Request path, for PurgeURLPrefix
+}
+
+// NewEntry builds an entry from an upstream response received at now
+func NewEntry(status int, header http.Header, body []byte, rules *config.RouteCache, now time.Time) *Entry {
+	e := &Entry{Status: status, Header: header.Clone(), Body: body, Vary: varyNames(header), Tags: Tags(header)}
+	e.strip()
+	e.update(header, rules, now)
+	return e
+}
+
+// strip removes headers that are not stored
+func (e *Entry) strip() {
+	for _, h := range hopHeaders {
+		e.Header.Del(h)
+	}
+	for _, h := range TagHeaders {
+		e.Header.Del(h)
+	}
+}
+
+// update recomputes age and lifetimes from response headers
+func (e *Entry) update(header http.Header, rules *config.RouteCache, now time.Time) {
+	age := time.Duration(0)
+	if v, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && v > 0 {
+		age = time.Duration(v) * time.Second
+	}
+	d := parseCacheControl(header)
+	e.Stored = now.Add(-age)
+	e.Fresh = freshness(header, d, rules, now)
+	e.Stale = staleWindow(d, rules)
+}
+
+// Revalidated applies a 304 Not Modified response: its headers replace the
+// stored ones and the entry is fresh again
+func (e *Entry) Revalidated(header http.Header, rules *config.RouteCache, now time.Time) {
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Content-Type", "Content-Encoding":
+			continue // Describe the (absent) 304 body, not the stored one
+		}
+		e.Header[name] = values
+	}
+	e.strip()
+	if tags := Tags(header); len(tags) > 0 {
+		e.Tags = tags
+	}
+	e.update(header, rules, now)
+}
+
+// Tags returns the surrogate keys of a response
+func Tags(header http.Header) []string {
+	var tags []string
+	for _, name := range TagHeaders {
+		for _, line := range header.Values(name) {
+			tags = append(tags, strings.FieldsFunc(line, func(r rune) bool {
+				return r == ' ' || r == ','
+			})...)
+		}
+	}
+	return tags
+}
+
+// Age is how old the entry is at now
+func (e *Entry) Age(now time.Time) time.Duration {
+	return max(now.Sub(e.Stored), 0)
+}
+
+// IsFresh reports whether the entry can be served without revalidation
+func (e *Entry) IsFresh(now time.Time) bool {
+	return e.Age(now) < e.Fresh
+}
+
+// ServeStale reports whether the expired entry can still be served while
+// it is refreshed in the background
+func (e *Entry) ServeStale(now time.Time) bool {
+	return !e.IsFresh(now) && e.Age(now) < e.Fresh+e.Stale
+}
+
+// HasValidators reports whether the entry can be revalidated
+func (e *Entry) HasValidators() bool {
+	return hasValidators(e.Header)
+}
+
+// retention is how long Redis keeps the entry
+func (e *Entry) retention() time.Duration {
+	ttl := e.Fresh + e.Stale
+	if e.HasValidators() {
+		ttl += max(e.Fresh, minRetention)
+	}
+	return ttl
+}
+
+// Conditional adds the entry's validators to an upstream request
+func (e *Entry) Conditional(req http.Header) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		req.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		req.Set("If-Modified-Since", lm)
+	}
+}
+
+// NotModified reports whether a client's conditional request matches the
+// entry, so a 304 can be sent instead of the body
+func (e *Entry) NotModified(req http.Header) bool {
+	if e.Status != http.StatusOK {
+		return false
+	}
+	if inm := req.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(e.Header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(req.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	return err == nil && !lm.After(ims)
+}
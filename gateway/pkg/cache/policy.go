@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// minRetention keeps entries with validators at least this long after they
+// expire, so they can be revalidated instead of fetched again
+const minRetention = time.Minute
+
+// cacheableStatus lists the statuses a shared cache may store by default
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// directives holds a parsed Cache-Control header: name → argument ("" if none)
+type directives map[string]string
+
+func parseCacheControl(h http.Header) directives {
+	d := make(directives)
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				d[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns a delta-seconds argument such as max-age=60
+func (d directives) seconds(name string) (time.Duration, bool) {
+	value, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// Bypass reports whether a request must not be answered from the cache.
+// no-store also keeps its response out of the cache.
+func Bypass(req http.Header) (skipLookup, skipStore bool) {
+	d := parseCacheControl(req)
+	if d.has("no-store") {
+		return true, true
+	}
+	if maxAge, ok := d.seconds("max-age"); d.has("no-cache") || (ok && maxAge == 0) {
+		return true, false
+	}
+	return false, false
+}
+
+// Storable checks whether a response may be cached for the route. The
+// reason explains a refusal. Responses to authenticated requests are only
+// shared between callers when the upstream marks them public or s-maxage,
+// as for requests with Authorization in a shared HTTP cache.
+func Storable(status int, header http.Header, rules *config.RouteCache, authenticated bool) (bool, string) {
+	d := parseCacheControl(header)
+	switch {
+	case !cacheableStatus[status]:
+		return false, "status " + strconv.Itoa(status)
+	case d.has("no-store"):
+		return false, "no-store"
+	case d.has("private") && !rules.Key.PerCaller():
+		return false, "private"
+	case authenticated && !rules.Key.PerCaller() && !d.has("public") && !d.has("s-maxage"):
+		return false, "authenticated"
+	case header.Get("Vary") == "*":
+		return false, "vary *"
+	case len(header.Values("Set-Cookie")) > 0:
+		return false, "set-cookie"
+	}
+	if freshness(header, d, rules, time.Now()) == 0 && !hasValidators(header) {
+		return false, "no freshness or validators"
+	}
+	return true, ""
+}
+
+// freshness is the lifetime of a response: s-maxage, max-age, Expires, then
+// the route TTL. no-cache responses must always be revalidated.
+func freshness(header http.Header, d directives, rules *config.RouteCache, now time.Time) time.Duration {
+	if d.has("no-cache") {
+		return 0
+	}
+	if v, ok := d.seconds("s-maxage"); ok {
+		return v
+	}
+	if v, ok := d.seconds("max-age"); ok {
+		return v
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // Invalid Expires means already expired
+		}
+		date := now
+		if v, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = v
+		}
+		return max(t.Sub(date), 0)
+	}
+	return rules.TTL
+}
+
+// staleWindow is how long a stale response may be served while it is
+// refreshed in the background
+func staleWindow(d directives, rules *config.RouteCache) time.Duration {
+	if d.has("no-cache") || d.has("must-revalidate") || d.has("proxy-revalidate") {
+		return 0
+	}
+	if v, ok := d.seconds("stale-while-revalidate"); ok {
+		return v
+	}
+	return rules.StaleWhileRevalidate
+}
+
+func hasValidators(header http.Header) bool {
+	return header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+// varyNames lists the request headers a response varies on, canonicalized
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
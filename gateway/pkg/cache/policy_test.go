@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+var testRules = &config.RouteCache{TTL: time.Minute, StaleWhileRevalidate: 30 * time.Second}
+
+func header(pairs ...string) http.Header {
+	h := make(http.Header)
+	for i := 0; i < len(pairs); i += 2 {
+		h.Add(pairs[i], pairs[i+1])
+	}
+	return h
+}
+
+func TestStorable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		rules  *config.RouteCache
+		auth   bool
+		ok     bool
+	}{
+		{"default TTL", 200, header(), testRules, false, true},
+		{"max-age", 200, header("Cache-Control", "max-age=10"), &config.RouteCache{}, false, true},
+		{"validators only", 200, header("ETag", `"v1"`), &config.RouteCache{}, false, true},
+		{"no freshness", 200, header(), &config.RouteCache{}, false, false},
+		{"no-store", 200, header("Cache-Control", "no-store"), testRules, false, false},
+		{"private shared key", 200, header("Cache-Control", "private, max-age=60"), testRules, false, false},
+		{"private per-user key", 200, header("Cache-Control", "private, max-age=60"), &config.RouteCache{Key: config.CacheKey{UserID: true}}, false, true},
+		{"vary star", 200, header("Vary", "*"), testRules, false, false},
+		{"set-cookie", 200, header("Set-Cookie", "a=b"), testRules, false, false},
+		{"server error", 500, header("Cache-Control", "max-age=60"), testRules, false, false},
+		{"not found", 404, header(), testRules, false, true},
+		{"authenticated shared key", 200, header("Cache-Control", "max-age=60"), testRules, true, false},
+		{"authenticated default TTL", 200, header(), testRules, true, false},
+		{"authenticated public", 200, header("Cache-Control", "public, max-age=60"), testRules, true, true},
+		{"authenticated s-maxage", 200, header("Cache-Control", "s-maxage=60"), testRules, true, true},
+		{"authenticated per-user key", 200, header(), &config.RouteCache{TTL: time.Minute, Key: config.CacheKey{UserID: true}}, true, true},
+		{"authenticated user header key", 200, header(), &config.RouteCache{TTL: time.Minute, Key: config.CacheKey{Headers: []string{"X-User-ID"}}}, true, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if ok, reason := Storable(tc.status, tc.header, tc.rules, tc.auth); ok != tc.ok {
+				t.Errorf("Expected storable=%v, got %v (%s)", tc.ok, ok, reason)
+			}
+		})
+	}
+}
+
+func TestNewEntryLifetimes(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		header       http.Header
+		fresh, stale time.Duration
+		age          time.Duration
+	}{
+		{"route defaults", header(), time.Minute, 30 * time.Second, 0},
+		{"s-maxage wins", header("Cache-Control", "max-age=10, s-maxage=20"), 20 * time.Second, 30 * time.Second, 0},
+		{"expires", header("Date", now.Format(http.TimeFormat), "Expires", now.Add(90*time.Second).Format(http.TimeFormat)), 90 * time.Second, 30 * time.Second, 0},
+		{"invalid expires", header("Expires", "0"), 0, 30 * time.Second, 0},
+		{"response swr", header("Cache-Control", "max-age=5, stale-while-revalidate=60"), 5 * time.Second, time.Minute, 0},
+		{"must-revalidate", header("Cache-Control", "max-age=5, must-revalidate"), 5 * time.Second, 0, 0},
+		{"no-cache", header("Cache-Control", "no-cache", "ETag", `"v1"`), 0, 0, 0},
+		{"upstream age", header("Cache-Control", "max-age=60", "Age", "15"), time.Minute, 30 * time.Second, 15 * time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEntry(200, tc.header, nil, testRules, now)
+			if e.Fresh != tc.fresh || e.Stale != tc.stale || e.Age(now) != tc.age {
+				t.Errorf("Expected fresh %s, stale %s, age %s; got %s, %s, %s", tc.fresh, tc.stale, tc.age, e.Fresh, e.Stale, e.Age(now))
+			}
+			if e.Header.Get("Age") != "" {
+				t.Error("Expected Age not to be stored")
+			}
+		})
+	}
+}
+
+func TestEntryStates(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := NewEntry(200, header("ETag", `"v1"`), []byte("hi"), testRules, now)
+
+	if !e.IsFresh(now.Add(59*time.Second)) || e.ServeStale(now.Add(59*time.Second)) {
+		t.Error("Expected entry fresh within its TTL")
+	}
+	if e.IsFresh(now.Add(61*time.Second)) || !e.ServeStale(now.Add(61*time.Second)) {
+		t.Error("Expected stale entry to be served while revalidating")
+	}
+	if e.ServeStale(now.Add(91 * time.Second)) {
+		t.Error("Expected entry past the stale window not to be served")
+	}
+	if e.retention() != 150*time.Second {
+		t.Errorf("Expected validators to extend retention by the TTL, got %s", e.retention())
+	}
+
+	// 304 refreshes headers and lifetime but keeps the body
+	later := now.Add(2 * time.Minute)
+	e.Revalidated(header("ETag", `"v1"`, "Cache-Control", "max-age=300", "Content-Length", "0"), testRules, later)
+	if !e.IsFresh(later) || e.Fresh != 5*time.Minute || string(e.Body) != "hi" {
+		t.Errorf("Expected revalidated entry fresh for 5m with its body, got %+v", e)
+	}
+	if e.Header.Get("Content-Length") != "" {
+		t.Error("Expected 304 Content-Length not to replace the stored one")
+	}
+}
+
+func TestEntryConditionals(t *testing.T) {
+	lm := "Mon, 05 Jan 2026 10:00:00 GMT"
+	e := NewEntry(200, header("ETag", `W/"v1"`, "Last-Modified", lm), nil, testRules, time.Now())
+
+	req := make(http.Header)
+	e.Conditional(req)
+	if req.Get("If-None-Match") != `W/"v1"` || req.Get("If-Modified-Since") != lm {
+		t.Errorf("Expected validators on upstream request, got %v", req)
+	}
+
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{header("If-None-Match", `"v0", "v1"`), true},
+		{header("If-None-Match", "*"), true},
+		{header("If-None-Match", `"v2"`), false},
+		{header("If-Modified-Since", lm), true},
+		{header("If-Modified-Since", "Sun, 04 Jan 2026 10:00:00 GMT"), false},
+		{header(), false},
+	}
+	for _, tc := range tests {
+		if got := e.NotModified(tc.header); got != tc.want {
+			t.Errorf("NotModified(%v) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestBypass(t *testing.T) {
+	if skip, store := Bypass(header("Cache-Control", "no-cache")); !skip || store {
+		t.Error("Expected no-cache to skip the lookup but store the response")
+	}
+	if skip, store := Bypass(header("Cache-Control", "max-age=0")); !skip || store {
+		t.Error("Expected max-age=0 to skip the lookup")
+	}
+	if skip, store := Bypass(header("Cache-Control", "no-store")); !skip || !store {
+		t.Error("Expected no-store to bypass the cache")
+	}
+	if skip, _ := Bypass(header()); skip {
+		t.Error("Expected plain requests to use the cache")
+	}
+}
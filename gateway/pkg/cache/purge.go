@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Deletes the entries listed in the tag sets ARGV and the sets themselves.
+// Returns the number of entries deleted.
+const purgeTagsScript = `
+local n = 0
+for _, tag in ipairs(ARGV) do
+    local set = '` + TagPrefix + `' .. tag
+    for _, key in ipairs(redis.call('SMEMBERS', set)) do
+        n = n + redis.call('DEL', key)
+    end
+    redis.call('DEL', set)
+end
+return n
+`
+
+// Deletes one SCAN page of keys matching ARGV[2] from cursor ARGV[1].
+// Returns {next_cursor, deleted}.
+const purgeScanScript = `
+local page = redis.call('SCAN', ARGV[1], 'MATCH', ARGV[2], 'COUNT', 1000)
+local n = 0
+for _, key in ipairs(page[2]) do
+    n = n + redis.call('DEL', key)
+end
+return {page[1], n}
+`
+
+// Deletes one SCAN page of entries from cursor ARGV[1] whose stored request
+// path starts with ARGV[2]. Keys are not parsed: both the path prefix and
+// the path in them may contain ':'. Returns {next_cursor, deleted}.
+const purgeURLScript = `
+local page = redis.call('SCAN', ARGV[1], 'MATCH', '` + KeyPrefix + `/*', 'COUNT', 1000)
+local n = 0
+for _, key in ipairs(page[2]) do
+    local ok, entry = pcall(cjson.decode, redis.call('GET', key) or '')
+    if ok and type(entry) == 'table' and type(entry.path) == 'string'
+        and string.sub(entry.path, 1, #ARGV[2]) == ARGV[2] then
+        n = n + redis.call('DEL', key)
+    end
+end
+return {page[1], n}
+`
+
+// PurgeKey deletes an entry by its cache key, including its Vary variants
+func (s *Store) PurgeKey(ctx context.Context, key string) (int, error) {
+	if !strings.HasPrefix(key, KeyPrefix+"/") {
+		return 0, fmt.Errorf("not a cache key: %q", key)
+	}
+	return s.purgeMatching(ctx, escapeGlob(key)+"*")
+}
+
+// PurgeRoute deletes every entry of the route with the given path prefix
+func (s *Store) PurgeRoute(ctx context.Context, pathPrefix string) (int, error) {
+	return s.purgeMatching(ctx, KeyPrefix+escapeGlob(pathPrefix)+":*")
+}
+
+// PurgeURLPrefix deletes the entries of every request path starting with
+// prefix, on any route. The prefix is compared with the path stored in each
+// entry, never used as a pattern.
+func (s *Store) PurgeURLPrefix(ctx context.Context, prefix string) (int, error) {
+	return s.purgePages(ctx, purgeURLScript, prefix)
+}
+
+// PurgeTags deletes the entries labeled with any of tags
+func (s *Store) PurgeTags(ctx context.Context, tags []string) (int, error) {
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+	result, err := s.redis.Eval(ctx, purgeTagsScript, nil, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	n, _ := result.(int64)
+	return int(n), nil
+}
+
+// purgeMatching deletes keys matching a glob pattern
+func (s *Store) purgeMatching(ctx context.Context, pattern string) (int, error) {
+	return s.purgePages(ctx, purgeScanScript, pattern)
+}
+
+// purgePages runs a purge script over the keyspace, one SCAN page per
+// script call so Redis is never blocked for long
+func (s *Store) purgePages(ctx context.Context, script, arg string) (int, error) {
+	cursor, total := "0", 0
+	for {
+		result, err := s.redis.Eval(ctx, script, nil, cursor, arg)
+		if err != nil {
+			return total, fmt.Errorf("failed to purge cache: %w", err)
+		}
+		page, ok := result.([]interface{})
+		if !ok || len(page) != 2 {
+			return total, fmt.Errorf("unexpected purge result: %v", result)
+		}
+		cursor, _ = page[0].(string)
+		n, _ := page[1].(int64)
+		total += int(n)
+		if cursor == "0" || cursor == "" {
+			return total, nil
+		}
+	}
+}
+
+// escapeGlob quotes the characters SCAN MATCH treats as wildcards
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
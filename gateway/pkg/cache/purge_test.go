@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// seedEntries stores one entry per path and returns their keys
+func seedEntries(t *testing.T, store *Store, route *config.Route, paths map[string]string) map[string]string {
+	t.Helper()
+	keys := make(map[string]string)
+	for path, tags := range paths {
+		r := request(path)
+		key := Key(route, r, "")
+		h := header("Surrogate-Key", tags)
+		if err := store.Put(context.Background(), key, r, NewEntry(200, h, []byte(path), testRules, time.Now())); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		keys[path] = key
+	}
+	return keys
+}
+
+// tagSets lists the tag sets left in Redis
+func tagSets(server *miniredis.Miniredis) []string {
+	var sets []string
+	for _, key := range server.Keys() {
+		if strings.HasPrefix(key, TagPrefix) {
+			sets = append(sets, key)
+		}
+	}
+	return sets
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	paths := map[string]string{
+		"/service-a/items/1":    "item-1 items",
+		"/service-a/items/2":    "item-2 items",
+		"/service-a/users/1":    "user-1",
+		"/service-a/[x]/1":      "",
+		"/service-a/x:/items/1": "",
+	}
+
+	t.Run("key", func(t *testing.T) {
+		store, server := newTestStore(t)
+		keys := seedEntries(t, store, cachedRoute(config.CacheKey{}), paths)
+
+		n, err := store.PurgeKey(ctx, keys["/service-a/[x]/1"])
+		if err != nil || n != 1 || server.Exists(keys["/service-a/[x]/1"]) || !server.Exists(keys["/service-a/items/1"]) {
+			t.Errorf("Expected only the keyed entry purged, got %d (%v)", n, err)
+		}
+		if _, err := store.PurgeKey(ctx, "routes:current"); err == nil {
+			t.Error("Expected non-cache keys to be refused")
+		}
+	})
+
+	t.Run("url prefix", func(t *testing.T) {
+		store, server := newTestStore(t)
+		keys := seedEntries(t, store, cachedRoute(config.CacheKey{}), paths)
+
+		// Matched on the stored path, not on a key pattern
+		if n, err := store.PurgeURLPrefix(ctx, "/items"); err != nil || n != 0 {
+			t.Errorf("Expected a ':' in a path not to match, got %d (%v)", n, err)
+		}
+		if n, err := store.PurgeURLPrefix(ctx, "/service-a/[x]"); err != nil || n != 1 || !server.Exists(keys["/service-a/x:/items/1"]) {
+			t.Errorf("Expected glob characters matched literally, got %d (%v)", n, err)
+		}
+		n, err := store.PurgeURLPrefix(ctx, "/service-a/items")
+		if err != nil || n != 2 || server.Exists(keys["/service-a/items/2"]) || !server.Exists(keys["/service-a/users/1"]) {
+			t.Errorf("Expected the two item entries purged, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("route", func(t *testing.T) {
+		store, server := newTestStore(t)
+		seedEntries(t, store, cachedRoute(config.CacheKey{}), paths)
+		other := &config.Route{PathPrefix: "/service-b", Cache: testRules}
+		otherKeys := seedEntries(t, store, other, map[string]string{"/service-b/x": ""})
+
+		n, err := store.PurgeRoute(ctx, "/service-a")
+		if err != nil || n != 5 || !server.Exists(otherKeys["/service-b/x"]) {
+			t.Errorf("Expected all /service-a entries purged, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		store, server := newTestStore(t)
+		keys := seedEntries(t, store, cachedRoute(config.CacheKey{}), paths)
+
+		n, err := store.PurgeTags(ctx, []string{"items", "user-1"})
+		if err != nil || n != 3 || server.Exists(keys["/service-a/users/1"]) || !server.Exists(keys["/service-a/[x]/1"]) {
+			t.Errorf("Expected the tagged entries purged, got %d (%v)", n, err)
+		}
+		if sets := tagSets(server); len(sets) != 2 {
+			t.Errorf("Expected only the unpurged tag sets left, got %v", sets)
+		}
+	})
+}
+
+func TestTags(t *testing.T) {
+	h := header("Surrogate-Key", "product-1  products", "Cache-Tag", "a,b")
+	got := Tags(h)
+	if len(got) != 4 || got[0] != "product-1" || got[3] != "b" {
+		t.Errorf("Expected tags from both headers, got %v", got)
+	}
+
+	e := NewEntry(200, h, nil, testRules, time.Now())
+	if e.Header.Get("Surrogate-Key") != "" || e.Header.Get("Cache-Tag") != "" || len(e.Tags) != 4 {
+		t.Errorf("Expected tags kept apart from stored headers, got %+v", e)
+	}
+}
@@ -0,0 +1,188 @@
+// Package cache stores upstream responses in Redis so repeated GETs are
+// answered by the gateway. It follows the shared-cache rules of HTTP:
+// Cache-Control, Expires and Vary decide what is stored and for how long,
+// and ETag/Last-Modified let expired entries be revalidated.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+)
+
+// Redis key layout. Entries live at cache:{path_prefix}:{path}:{hash}, so
+// purges can match a route or a URL prefix; tag sets list the entries a
+// backend labeled with a surrogate key.
+const (
+	KeyPrefix = "cache:"
+	TagPrefix = "cache:tag:"
+)
+
+// TagHeaders carry surrogate keys: space-separated in Surrogate-Key,
+// comma-separated in Cache-Tag. They are not passed on to clients.
+var TagHeaders = []string{"Surrogate-Key", "Cache-Tag"}
+
+// Returns the stored value, or "" if none
+const getScript = `return redis.call('GET', KEYS[1]) or ''`
+
+// Stores an entry at KEYS[2] and, for responses with Vary, the list of
+// varying headers at KEYS[1]. ARGV[3] is the TTL in milliseconds; ARGV[4..]
+// are tags, whose sets are kept as long as their longest-lived entry.
+const putScript = `
+if ARGV[1] ~= '' then
+    redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+end
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+for i = 4, #ARGV do
+    local tag = '` + TagPrefix + `' .. ARGV[i]
+    redis.call('SADD', tag, KEYS[2])
+    if redis.call('PTTL', tag) < tonumber(ARGV[3]) then
+        redis.call('PEXPIRE', tag, ARGV[3])
+    end
+end
+return 1
+`
+
+// Takes a short lock so one instance refreshes an entry; returns 1 if taken
+const lockScript = `
+if redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1]) then
+    return 1
+end
+return 0
+`
+
+// Store reads and writes cached responses
+type Store struct {
+	redis redis.Evaluator
+}
+
+// NewStore creates a response store
+func NewStore(client redis.Evaluator) *Store {
+	return &Store{redis: client}
+}
+
+// Key composes the cache key of a request from its path and a hash of the
+// method, the parts the route's cache.key selects and the traffic split
+// group the request was sent to ("" when the route has no split), so
+// groups never answer from each other's entries
+func Key(route *config.Route, r *http.Request, group string) string {
+	rules := route.Cache.Key
+	parts := []string{r.Method, r.URL.Path}
+	if group != "" {
+		parts = append(parts, "group="+group)
+	}
+	if !rules.IgnoreQuery {
+		parts = append(parts, r.URL.Query().Encode()) // Sorted by name
+	}
+	for _, name := range rules.Headers {
+		parts = append(parts, strings.ToLower(name)+"="+strings.Join(r.Header.Values(name), ","))
+	}
+	if rules.ClientID {
+		parts = append(parts, "client="+r.Header.Get("X-Client-ID"))
+	}
+	if rules.UserID {
+		parts = append(parts, "user="+r.Header.Get("X-User-ID"))
+	}
+	return KeyPrefix + route.PathPrefix + ":" + r.URL.Path + ":" + digest(parts)
+}
+
+// variantKey extends key with the values of the headers a response varies on
+func variantKey(key string, vary []string, req http.Header) string {
+	names := append([]string(nil), vary...)
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strings.Join(req.Values(name), ",")
+	}
+	return key + ":" + digest(parts)
+}
+
+func digest(parts []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// varyIndex is stored at the key of responses with Vary; the entries live
+// at their variant keys
+type varyIndex struct {
+	Vary []string `json:"vary"`
+	Path string   `json:"path"`
+}
+
+// Get returns the entry for a request, or nil if none is stored
+func (s *Store) Get(ctx context.Context, key string, req http.Header) (*Entry, error) {
+	var e Entry
+	found, err := s.get(ctx, key, &e)
+	if err != nil || !found {
+		return nil, err
+	}
+	if e.Status != 0 {
+		return &e, nil
+	}
+
+	// Only the vary index is stored here
+	found, err = s.get(ctx, variantKey(key, e.Vary, req), &e)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *Store) get(ctx context.Context, key string, out *Entry) (bool, error) {
+	result, err := s.redis.Eval(ctx, getScript, []string{key})
+	if err != nil {
+		return false, fmt.Errorf("failed to read cache: %w", err)
+	}
+	data, _ := result.(string)
+	if data == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(data), out); err != nil {
+		return false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	return true, nil
+}
+
+// Put stores an entry for the request it answers
+func (s *Store) Put(ctx context.Context, key string, r *http.Request, e *Entry) error {
+	stored := *e
+	stored.Path = r.URL.Path
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	index, target := "", key
+	if len(e.Vary) > 0 {
+		b, _ := json.Marshal(varyIndex{Vary: e.Vary, Path: stored.Path})
+		index, target = string(b), variantKey(key, e.Vary, r.Header)
+	}
+	args := []interface{}{index, string(data), e.retention().Milliseconds()}
+	for _, tag := range e.Tags {
+		args = append(args, tag)
+	}
+	if _, err := s.redis.Eval(ctx, putScript, []string{key, target}, args...); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// Lock reports whether the caller may refresh key; the lock expires after
+// ttl so a failed refresh does not block others for long
+func (s *Store) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	result, err := s.redis.Eval(ctx, lockScript, []string{key + ":lock"}, ttl.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to lock cache entry: %w", err)
+	}
+	n, _ := result.(int64)
+	return n == 1, nil
+}
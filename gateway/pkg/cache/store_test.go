@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server, client := redistest.New(t)
+	return NewStore(client), server
+}
+
+// request builds a GET request with header pairs
+func request(path string, headers ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+func cachedRoute(key config.CacheKey) *config.Route {
+	return &config.Route{PathPrefix: "/service-a", Cache: &config.RouteCache{TTL: time.Minute, Key: key}}
+}
+
+func TestKey(t *testing.T) {
+	route := cachedRoute(config.CacheKey{})
+	key := func(target string, headers ...string) string {
+		return Key(route, request(target, headers...), "")
+	}
+
+	if k := key("/service-a/items?b=2&a=1"); !strings.HasPrefix(k, "cache:/service-a:/service-a/items:") || k != key("/service-a/items?a=1&b=2") {
+		t.Errorf("Expected query order not to matter, got %s", k)
+	}
+	if key("/service-a/items?a=1") == key("/service-a/items?a=2") {
+		t.Error("Expected query to be part of the key")
+	}
+	if key("/service-a/items", "X-User-ID", "alice") != key("/service-a/items", "X-User-ID", "bob") {
+		t.Error("Expected users to share keys by default")
+	}
+
+	r := request("/service-a/items")
+	if Key(route, r, "canary") == Key(route, r, "stable") || Key(route, r, "canary") == key("/service-a/items") {
+		t.Error("Expected split groups to have their own keys")
+	}
+
+	route.Cache.Key = config.CacheKey{IgnoreQuery: true, UserID: true, Headers: []string{"Accept-Language"}}
+	if key("/service-a/items?a=1") != key("/service-a/items?a=2") {
+		t.Error("Expected ignore_query to drop the query")
+	}
+	if key("/service-a/items", "X-User-ID", "alice") == key("/service-a/items", "X-User-ID", "bob") {
+		t.Error("Expected user_id to separate users")
+	}
+	if key("/service-a/items", "Accept-Language", "en") == key("/service-a/items", "Accept-Language", "de") {
+		t.Error("Expected key headers to separate responses")
+	}
+}
+
+func TestStorePutGet(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+
+	e := NewEntry(200, header("Content-Type", "text/plain"), []byte("hello"), testRules, time.Now())
+	if err := store.Put(ctx, "cache:/a:1", request("/a/1"), e); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, err := store.Get(ctx, "cache:/a:1", nil)
+	if err != nil || got == nil || string(got.Body) != "hello" || got.Fresh != time.Minute {
+		t.Errorf("Expected stored entry, got %+v (%v)", got, err)
+	}
+	if ttl := server.TTL("cache:/a:1"); ttl != 90*time.Second {
+		t.Errorf("Expected TTL of fresh + stale, got %s", ttl)
+	}
+
+	if got, err := store.Get(ctx, "cache:/a:2", nil); err != nil || got != nil {
+		t.Errorf("Expected miss, got %+v (%v)", got, err)
+	}
+}
+
+func TestStoreVary(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	english := header("Accept-Language", "en")
+	german := header("Accept-Language", "de")
+	store.Put(ctx, "cache:/a:1", request("/a/1", "Accept-Language", "en"), NewEntry(200, header("Vary", "accept-language"), []byte("hello"), testRules, time.Now()))
+	store.Put(ctx, "cache:/a:1", request("/a/1", "Accept-Language", "de"), NewEntry(200, header("Vary", "Accept-Language"), []byte("hallo"), testRules, time.Now()))
+
+	for req, want := range map[*http.Header]string{&english: "hello", &german: "hallo"} {
+		got, err := store.Get(ctx, "cache:/a:1", *req)
+		if err != nil || got == nil || string(got.Body) != want {
+			t.Errorf("Expected %q for %v, got %+v (%v)", want, *req, got, err)
+		}
+	}
+	if got, _ := store.Get(ctx, "cache:/a:1", header("Accept-Language", "fr")); got != nil {
+		t.Errorf("Expected miss for an unseen variant, got %+v", got)
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if ok, err := store.Lock(ctx, "cache:/a:1", time.Second); !ok || err != nil {
+		t.Errorf("Expected first lock to succeed, got %v (%v)", ok, err)
+	}
+	if ok, _ := store.Lock(ctx, "cache:/a:1", time.Second); ok {
+		t.Error("Expected second lock to fail while held")
+	}
+}
+
+func TestStoreRedisUnavailable(t *testing.T) {
+	store, server := newTestStore(t)
+	server.SetError("LOADING Redis is loading the dataset in memory")
+
+	if _, err := store.Get(context.Background(), "cache:/a:1", nil); err == nil {
+		t.Error("Expected Get to report Redis errors")
+	}
+	if err := store.Put(context.Background(), "cache:/a:1", request("/a/1"), NewEntry(200, header(), nil, testRules, time.Now())); err == nil {
+		t.Error("Expected Put to report Redis errors")
+	}
+}
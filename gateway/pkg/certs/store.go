@@ -0,0 +1,160 @@
+// Package certs manages the TLS certificates served by the gateway listener.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pair is a certificate/key file pair on disk
+type Pair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Store holds loaded certificates and selects one per connection by SNI.
+// Certificates are reloaded when their files change on disk.
+type Store struct {
+	pairs []Pair
+
+	mu       sync.RWMutex
+	certs    []*tls.Certificate
+	modTimes map[string]time.Time
+}
+
+// NewStore loads all pairs. The first pair is the default certificate
+// served when the client sends no SNI or no certificate matches.
+func NewStore(pairs []Pair) (*Store, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+	s := &Store{pairs: pairs}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		// Exact names win over wildcards
+		for _, cert := range s.certs {
+			if matchesName(cert.Leaf, name, false) {
+				return cert, nil
+			}
+		}
+		for _, cert := range s.certs {
+			if matchesName(cert.Leaf, name, true) {
+				return cert, nil
+			}
+		}
+	}
+	return s.certs[0], nil
+}
+
+// Watch polls certificate files and reloads them when any file changes.
+// A failed reload keeps serving the previous certificates.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.load(); err != nil {
+				log.Printf("TLS certificate reload failed: %v", err)
+				continue
+			}
+			log.Printf("TLS certificates reloaded")
+		}
+	}
+}
+
+// load reads every pair from disk and atomically replaces the served set
+func (s *Store) load() error {
+	certs := make([]*tls.Certificate, 0, len(s.pairs))
+	modTimes := make(map[string]time.Time, len(s.pairs)*2)
+
+	for _, p := range s.pairs {
+		for _, file := range []string{p.CertFile, p.KeyFile} {
+			info, err := os.Stat(file)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", file, err)
+			}
+			modTimes[file] = info.ModTime()
+		}
+
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s: %w", p.CertFile, err)
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return fmt.Errorf("failed to parse certificate %s: %w", p.CertFile, err)
+			}
+		}
+		certs = append(certs, &cert)
+	}
+
+	s.mu.Lock()
+	s.certs = certs
+	s.modTimes = modTimes
+	s.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any cert or key file has a new modification time
+func (s *Store) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for file, modTime := range s.modTimes {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // File mid-rotation; try again next tick
+		}
+		if !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesName checks SANs (falling back to CN) against the SNI name
+func matchesName(leaf *x509.Certificate, name string, wildcard bool) bool {
+	names := leaf.DNSNames
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = []string{leaf.Subject.CommonName}
+	}
+	for _, n := range names {
+		n = strings.ToLower(n)
+		if !wildcard && n == name {
+			return true
+		}
+		// "*.example.com" matches exactly one extra label: "api.example.com"
+		if wildcard && strings.HasPrefix(n, "*.") {
+			if i := strings.Index(name, "."); i > 0 && name[i:] == n[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert generates a self-signed certificate for the given DNS names
+func writeCert(t *testing.T, dir, name string, dnsNames ...string) Pair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	pair := Pair{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(pair.CertFile, certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write cert: %v", err)
+	}
+	if err := os.WriteFile(pair.KeyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return pair
+}
+
+func TestStoreSelectsBySNI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore([]Pair{
+		writeCert(t, dir, "default", "gateway.local"),
+		writeCert(t, dir, "api", "api.example.com"),
+		writeCert(t, dir, "wildcard", "*.example.com"),
+	})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	tests := []struct {
+		serverName string
+		expected   string
+	}{
+		{"api.example.com", "api.example.com"},
+		{"API.Example.com", "api.example.com"},
+		{"www.example.com", "*.example.com"},
+		{"a.b.example.com", "gateway.local"}, // Wildcard covers one label only
+		{"unknown.org", "gateway.local"},
+		{"", "gateway.local"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.serverName, func(t *testing.T) {
+			cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: tc.serverName})
+			if err != nil {
+				t.Fatalf("GetCertificate failed: %v", err)
+			}
+			if cert.Leaf.DNSNames[0] != tc.expected {
+				t.Errorf("Expected certificate for '%s', got '%s'", tc.expected, cert.Leaf.DNSNames[0])
+			}
+		})
+	}
+}
+
+func TestStoreReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeCert(t, dir, "site", "old.example.com")
+
+	store, err := NewStore([]Pair{pair})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Watch(ctx, 10*time.Millisecond)
+
+	// Rewrite with a new name and bump mtime so the change is always visible
+	writeCert(t, dir, "site", "new.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(pair.CertFile, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		cert, _ := store.GetCertificate(&tls.ClientHelloInfo{})
+		if cert.Leaf.DNSNames[0] == "new.example.com" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected certificate to be reloaded after file change")
+}
+
+func TestNewStoreRequiresCertificates(t *testing.T) {
+	if _, err := NewStore(nil); err == nil {
+		t.Error("Expected error when no certificates configured")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore([]Pair{writeCert(t, dir, "site", "gateway.local")})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	cfg, err := ServerConfig(store, "1.2", []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 minimum, got %x", cfg.MinVersion)
+	}
+	if len(cfg.CipherSuites) != 1 || cfg.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("Unexpected cipher suites: %v", cfg.CipherSuites)
+	}
+
+	if _, err := ServerConfig(store, "2.0", nil); err == nil {
+		t.Error("Expected error for unsupported TLS version")
+	}
+	if _, err := ServerConfig(store, "1.2", []string{"TLS_MADE_UP"}); err == nil {
+		t.Error("Expected error for unknown cipher suite")
+	}
+}
@@ -168,6 +168,24 @@ func (b *Breaker) Allow(ctx context.Context) Result {
 	return Result{Allowed: allowed, State: state}
 }
 
+// Lua script to read state without transitioning
+var readStateScript = `
+return redis.call('HGET', KEYS[1], 'state') or 'CLOSED'
+`
+
+// State returns the stored state without counting as a request.
+// An OPEN circuit past its cooldown still reports OPEN until the next Allow.
+func (b *Breaker) State(ctx context.Context) (State, error) {
+	result, err := b.redis.Eval(ctx, readStateScript, []string{"circuit:" + b.service + ":state"})
+	if err != nil {
+		return StateClosed, err
+	}
+	if s := toString(result); s != "" {
+		return State(s), nil
+	}
+	return StateClosed, nil
+}
+
 // RecordSuccess records a successful request
 func (b *Breaker) RecordSuccess(ctx context.Context) {
 	b.recordResult(ctx, true)
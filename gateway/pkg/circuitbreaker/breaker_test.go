@@ -138,3 +138,29 @@ func TestBreakerInvalidResponse(t *testing.T) {
 		t.Error("Should fail-open on invalid response")
 	}
 }
+
+func TestBreakerStateReadsStoredState(t *testing.T) {
+	mock := &mockRedis{responses: []interface{}{"OPEN"}}
+	breaker := NewBreaker(mock, "test-service")
+
+	state, err := breaker.State(context.Background())
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if state != StateOpen {
+		t.Errorf("Expected OPEN, got %s", state)
+	}
+}
+
+func TestBreakerStateRedisDown(t *testing.T) {
+	mock := &mockRedis{err: errors.New("connection refused")}
+	breaker := NewBreaker(mock, "test-service")
+
+	state, err := breaker.State(context.Background())
+	if err == nil {
+		t.Error("Expected error when Redis unavailable")
+	}
+	if state != StateClosed {
+		t.Errorf("Expected CLOSED (fail-open), got %s", state)
+	}
+}
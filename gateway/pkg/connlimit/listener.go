@@ -0,0 +1,97 @@
+// Package connlimit caps and counts concurrent connections on a listener.
+package connlimit
+
+import (
+	"net"
+	"sync"
+)
+
+// Gauge tracks the number of open connections (satisfied by prometheus.Gauge)
+type Gauge interface {
+	Inc()
+	Dec()
+}
+
+// Listener wraps a net.Listener. When max connections are open, Accept
+// blocks until one closes, leaving new clients in the kernel backlog
+// instead of starving the process of file descriptors.
+type Listener struct {
+	net.Listener
+	sem   chan struct{} // nil when unlimited
+	gauge Gauge
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewListener limits ln to max concurrent connections (0 = unlimited).
+// gauge may be nil.
+func NewListener(ln net.Listener, max int, gauge Gauge) *Listener {
+	l := &Listener{Listener: ln, gauge: gauge, done: make(chan struct{})}
+	if max > 0 {
+		l.sem = make(chan struct{}, max)
+	}
+	return l
+}
+
+// Accept waits for a free slot, then accepts the next connection
+func (l *Listener) Accept() (net.Conn, error) {
+	if !l.acquire() {
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+
+	if l.gauge != nil {
+		l.gauge.Inc()
+	}
+	return &limitedConn{Conn: conn, release: l.closed}, nil
+}
+
+// Close stops accepting and unblocks any waiting Accept
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+func (l *Listener) acquire() bool {
+	if l.sem == nil {
+		return true
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *Listener) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// closed runs once per connection when it closes
+func (l *Listener) closed() {
+	if l.gauge != nil {
+		l.gauge.Dec()
+	}
+	l.release()
+}
+
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,120 @@
+package connlimit
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingGauge struct {
+	value atomic.Int64
+}
+
+func (g *countingGauge) Inc() { g.value.Add(1) }
+func (g *countingGauge) Dec() { g.value.Add(-1) }
+
+func newTestListener(t *testing.T, max int, gauge Gauge) *Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	l := NewListener(ln, max, gauge)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func dial(t *testing.T, l *Listener) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestListenerBlocksAtLimit(t *testing.T) {
+	gauge := &countingGauge{}
+	l := newTestListener(t, 1, gauge)
+
+	dial(t, l)
+	first, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if gauge.value.Load() != 1 {
+		t.Errorf("Expected 1 open connection, got %d", gauge.value.Load())
+	}
+
+	// Second accept must wait until the first connection closes
+	dial(t, l)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	select {
+	case <-accepted:
+		t.Fatal("Expected Accept to block at connection limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	first.Close() // Double close must not release twice
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Expected Accept to proceed after a connection closed")
+	}
+
+	if gauge.value.Load() != 0 {
+		t.Errorf("Expected 0 open connections, got %d", gauge.value.Load())
+	}
+}
+
+func TestListenerUnlimited(t *testing.T) {
+	gauge := &countingGauge{}
+	l := newTestListener(t, 0, gauge)
+
+	for i := 0; i < 5; i++ {
+		dial(t, l)
+		if _, err := l.Accept(); err != nil {
+			t.Fatalf("Accept %d failed: %v", i, err)
+		}
+	}
+	if gauge.value.Load() != 5 {
+		t.Errorf("Expected 5 open connections, got %d", gauge.value.Load())
+	}
+}
+
+func TestListenerCloseUnblocksAccept(t *testing.T) {
+	l := newTestListener(t, 1, nil)
+
+	dial(t, l)
+	if _, err := l.Accept(); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		errCh <- err
+	}()
+
+	l.Close()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("Expected error from Accept after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to unblock Accept")
+	}
+}
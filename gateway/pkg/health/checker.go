@@ -0,0 +1,110 @@
+// Package health runs dependency checks for the readiness endpoint.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a single check or of the whole instance
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDown     Status = "down"
+	StatusReady    Status = "ready"
+	StatusNotReady Status = "not_ready"
+)
+
+// CheckFunc probes one dependency. Returned details are included in the
+// report whether or not the check fails.
+type CheckFunc func(ctx context.Context) (map[string]interface{}, error)
+
+// Result is the outcome of one check
+type Result struct {
+	Name      string                 `json:"name"`
+	Status    Status                 `json:"status"`
+	Critical  bool                   `json:"critical"` // Failure makes the instance not ready
+	LatencyMs float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the readiness response body
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs registered checks concurrently with a shared timeout
+type Checker struct {
+	checks   []check
+	critical map[string]bool
+	timeout  time.Duration
+}
+
+// NewChecker creates a checker. Failures of checks named in critical make
+// the instance not ready; other failures are reported but tolerated.
+func NewChecker(timeout time.Duration, critical []string) *Checker {
+	c := &Checker{critical: make(map[string]bool), timeout: timeout}
+	for _, name := range critical {
+		c.critical[name] = true
+	}
+	return c
+}
+
+// Register adds a named check
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Run executes all checks and aggregates the result
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: results}
+	for _, r := range results {
+		if r.Critical && r.Status == StatusDown {
+			report.Status = StatusNotReady
+		}
+	}
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+func (c *Checker) runCheck(ctx context.Context, chk check) Result {
+	start := time.Now()
+	details, err := chk.fn(ctx)
+
+	result := Result{
+		Name:      chk.name,
+		Status:    StatusUp,
+		Critical:  c.critical[chk.name],
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
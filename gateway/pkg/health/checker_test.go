@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerAllUp(t *testing.T) {
+	c := NewChecker(time.Second, []string{"routes"})
+	c.Register("routes", func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"count": 3}, nil
+	})
+	c.Register("redis", func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, nil
+	})
+
+	report := c.Run(context.Background())
+	if report.Status != StatusReady {
+		t.Errorf("Expected ready, got %s", report.Status)
+	}
+	if len(report.Checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %d", len(report.Checks))
+	}
+	// Sorted by name
+	if report.Checks[0].Name != "redis" || report.Checks[1].Name != "routes" {
+		t.Errorf("Expected checks sorted by name, got %s, %s", report.Checks[0].Name, report.Checks[1].Name)
+	}
+	if report.Checks[1].Details["count"] != 3 {
+		t.Errorf("Expected details to be preserved, got %v", report.Checks[1].Details)
+	}
+}
+
+func TestCheckerNonCriticalFailureStaysReady(t *testing.T) {
+	c := NewChecker(time.Second, []string{"routes"})
+	c.Register("routes", func(ctx context.Context) (map[string]interface{}, error) { return nil, nil })
+	c.Register("redis", func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	report := c.Run(context.Background())
+	if report.Status != StatusReady {
+		t.Errorf("Expected ready with non-critical failure, got %s", report.Status)
+	}
+	if report.Checks[0].Status != StatusDown || report.Checks[0].Error != "connection refused" {
+		t.Errorf("Expected redis down with error, got %+v", report.Checks[0])
+	}
+}
+
+func TestCheckerCriticalFailureNotReady(t *testing.T) {
+	c := NewChecker(time.Second, []string{"redis"})
+	c.Register("redis", func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	report := c.Run(context.Background())
+	if report.Status != StatusNotReady {
+		t.Errorf("Expected not_ready, got %s", report.Status)
+	}
+	if !report.Checks[0].Critical {
+		t.Error("Expected redis check to be marked critical")
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := NewChecker(20*time.Millisecond, []string{"slow"})
+	c.Register("slow", func(ctx context.Context) (map[string]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	if time.Since(start) > time.Second {
+		t.Error("Expected checks to be bounded by timeout")
+	}
+	if report.Status != StatusNotReady {
+		t.Errorf("Expected not_ready after timeout, got %s", report.Status)
+	}
+}
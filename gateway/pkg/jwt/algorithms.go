@@ -0,0 +1,105 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+)
+
+// Signature algorithms (RFC 7518 §3, RFC 8037)
+const (
+	AlgorithmRS256 = "RS256" // RSA PKCS #1 v1.5 with SHA-256
+	AlgorithmPS256 = "PS256" // RSA-PSS with SHA-256
+	AlgorithmES256 = "ES256" // ECDSA P-256 with SHA-256
+	AlgorithmES384 = "ES384" // ECDSA P-384 with SHA-384
+	AlgorithmEdDSA = "EdDSA" // Ed25519
+)
+
+// DefaultAlgorithms are accepted when none are configured
+var DefaultAlgorithms = []string{AlgorithmRS256}
+
+// verifiers check a signature over message. Each only accepts keys of its
+// own type, so the token's alg header can never make a key be used with
+// another algorithm (e.g. an RSA key as an ECDSA key).
+var verifiers = map[string]func(key crypto.PublicKey, message string, signature []byte) bool{
+	AlgorithmRS256: func(key crypto.PublicKey, message string, signature []byte) bool {
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && verifyRS256Signature(pub, message, signature) == nil
+	},
+	AlgorithmPS256: func(key crypto.PublicKey, message string, signature []byte) bool {
+		pub, ok := key.(*rsa.PublicKey)
+		hash := sha256.Sum256([]byte(message))
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		return ok && rsa.VerifyPSS(pub, crypto.SHA256, hash[:], signature, opts) == nil
+	},
+	AlgorithmES256: func(key crypto.PublicKey, message string, signature []byte) bool {
+		hash := sha256.Sum256([]byte(message))
+		return verifyECDSA(key, elliptic.P256(), hash[:], signature)
+	},
+	AlgorithmES384: func(key crypto.PublicKey, message string, signature []byte) bool {
+		hash := sha512.Sum384([]byte(message))
+		return verifyECDSA(key, elliptic.P384(), hash[:], signature)
+	},
+	AlgorithmEdDSA: func(key crypto.PublicKey, message string, signature []byte) bool {
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, []byte(message), signature)
+	},
+}
+
+// verifyECDSA checks a JWS ECDSA signature: R and S concatenated, each
+// padded to the curve size
+func verifyECDSA(key crypto.PublicKey, curve elliptic.Curve, hash, signature []byte) bool {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != curve {
+		return false
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	return ecdsa.Verify(pub, hash, r, s)
+}
+
+// algorithmSet validates a configured algorithm list
+func algorithmSet(algorithms []string) (map[string]bool, error) {
+	if len(algorithms) == 0 {
+		algorithms = DefaultAlgorithms
+	}
+	set := make(map[string]bool, len(algorithms))
+	for _, alg := range algorithms {
+		if verifiers[alg] == nil {
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+		set[alg] = true
+	}
+	return set, nil
+}
+
+// usable reports whether key can verify any of the algorithms
+func usable(key crypto.PublicKey, algorithms map[string]bool) bool {
+	for alg := range algorithms {
+		switch pub := key.(type) {
+		case *rsa.PublicKey:
+			if alg == AlgorithmRS256 || alg == AlgorithmPS256 {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if (alg == AlgorithmES256 && pub.Curve == elliptic.P256()) || (alg == AlgorithmES384 && pub.Curve == elliptic.P384()) {
+				return true
+			}
+		case ed25519.PublicKey:
+			if alg == AlgorithmEdDSA {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidatorAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		alg string
+		key crypto.Signer
+	}{
+		{AlgorithmRS256, rsaKey},
+		{AlgorithmPS256, rsaKey},
+		{AlgorithmES256, p256},
+		{AlgorithmES384, p384},
+		{AlgorithmEdDSA, edKey},
+	}
+	for _, tc := range tests {
+		t.Run(tc.alg, func(t *testing.T) {
+			validator, err := NewValidator(writePublicKey(t, tc.key.Public()), Policy{}, []string{tc.alg})
+			if err != nil {
+				t.Fatalf("NewValidator failed: %v", err)
+			}
+			claims, err := validator.Validate(signWith(t, tc.alg, tc.key, ""))
+			if err != nil || claims.Sub != "user123" {
+				t.Fatalf("Expected valid %s token, got %v", tc.alg, err)
+			}
+
+			// A token signed by another key of the same type is rejected
+			other := map[string]crypto.Signer{AlgorithmRS256: p256, AlgorithmPS256: p256, AlgorithmES256: p384, AlgorithmES384: p256, AlgorithmEdDSA: rsaKey}[tc.alg]
+			if _, err := validator.Validate(signWith(t, tc.alg, other, "")); err != ErrInvalidSignature {
+				t.Errorf("Expected ErrInvalidSignature for a foreign key, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatorAlgorithmNotAllowed(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	validator, err := NewValidator(writePublicKey(t, &key.PublicKey), Policy{}, nil)
+	if err != nil {
+		t.Fatalf("NewValidator failed: %v", err)
+	}
+	if _, err := validator.Validate(signWith(t, AlgorithmPS256, key, "")); err != ErrAlgorithm {
+		t.Errorf("Expected ErrAlgorithm for PS256 with RS256 only, got %v", err)
+	}
+
+	// "none" and HMAC tokens are never accepted
+	for _, alg := range []string{"none", "HS256"} {
+		header, _ := json.Marshal(map[string]string{"alg": alg})
+		token := base64.RawURLEncoding.EncodeToString(header) + ".e30."
+		if _, err := validator.Validate(token); err != ErrAlgorithm {
+			t.Errorf("Expected ErrAlgorithm for %s, got %v", alg, err)
+		}
+	}
+
+	if _, err := NewValidator(writePublicKey(t, &key.PublicKey), Policy{}, []string{"HS256"}); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := NewValidator(writePublicKey(t, &ecKey.PublicKey), Policy{}, []string{AlgorithmRS256}); err == nil {
+		t.Error("Expected error for an EC key with only RS256 allowed")
+	}
+}
+
+func TestJWKSAlgorithmBinding(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecJWK := map[string]string{
+		"kty": "EC", "kid": "ec", "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	}
+	edJWK := map[string]string{
+		"kty": "OKP", "kid": "ed", "crv": "Ed25519",
+		"x": base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)),
+	}
+	server := newJWKSServer(jwkSet(jwkFor("rsa", rsaKey), ecJWK, edJWK))
+	defer server.Close()
+
+	all := []string{AlgorithmRS256, AlgorithmPS256, AlgorithmES256, AlgorithmES384, AlgorithmEdDSA}
+	validator, _ := NewJWKSValidator(server.URL, Policy{}, all)
+	if err := validator.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if validator.KeyCount() != 3 {
+		t.Fatalf("Expected 3 keys, got %d", validator.KeyCount())
+	}
+
+	tests := []struct {
+		name string
+		alg  string
+		key  crypto.Signer
+		kid  string
+		err  error
+	}{
+		{"rsa", AlgorithmRS256, rsaKey, "rsa", nil},
+		{"ec", AlgorithmES256, ecKey, "ec", nil},
+		{"ed25519", AlgorithmEdDSA, edKey, "ed", nil},
+		{"no kid", AlgorithmEdDSA, edKey, "", nil},
+		{"key bound to RS256", AlgorithmPS256, rsaKey, "rsa", ErrInvalidSignature},
+		{"alg of another key type", AlgorithmES384, ecKey, "ec", ErrInvalidSignature},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := validator.Validate(signWith(t, tc.alg, tc.key, tc.kid)); err != tc.err {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// A point off the curve is rejected
+	ecJWK["y"] = ecJWK["x"]
+	if _, err := ParseJWKS(jwkSet(ecJWK)); err == nil {
+		t.Error("Expected error for invalid EC point")
+	}
+}
+
+// --- Helpers ---
+
+func writePublicKey(t *testing.T, pub crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "public.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	return path
+}
+
+// signWith creates a token signed with alg; the key type must fit alg
+func signWith(t *testing.T, alg string, key crypto.Signer, kid string) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	payload := map[string]interface{}{"sub": "user123", "exp": time.Now().Add(time.Hour).Unix()}
+	hJSON, _ := json.Marshal(header)
+	pJSON, _ := json.Marshal(payload)
+	msg := base64.RawURLEncoding.EncodeToString(hJSON) + "." + base64.RawURLEncoding.EncodeToString(pJSON)
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		hash := sha256.Sum256([]byte(msg))
+		if alg == AlgorithmPS256 {
+			sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, hash[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:])
+		}
+	case *ecdsa.PrivateKey:
+		var hash []byte
+		if k.Curve == elliptic.P384() {
+			sum := sha512.Sum384([]byte(msg))
+			hash = sum[:]
+		} else {
+			sum := sha256.Sum256([]byte(msg))
+			hash = sum[:]
+		}
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, hash)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig, err = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), signErr
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(msg))
+	}
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return msg + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,208 @@
+package jwt
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxHeaderValue caps claim values copied into upstream headers
+const maxHeaderValue = 256
+
+// Audience is the aud claim: a single string or an array of strings
+type Audience []string
+
+// UnmarshalJSON accepts both forms of aud
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// ContainsAny reports whether the audience includes one of names
+func (a Audience) ContainsAny(names []string) bool {
+	for _, name := range names {
+		if slices.Contains(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the claim at path. A claim named path is preferred;
+// otherwise dots select members of nested objects, e.g. realm_access.roles.
+func (c *Claims) Lookup(path string) (interface{}, bool) {
+	if v, ok := c.All[path]; ok {
+		return v, true
+	}
+	var cur interface{} = c.All
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Strings returns the claim at path as a list: a string is one element,
+// arrays keep their string elements
+func (c *Claims) Strings(path string) []string {
+	v, _ := c.Lookup(path)
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// scopes reads the OAuth scope claim (RFC 8693: space-separated), falling
+// back to scp, which some providers send as an array
+func (c *Claims) scopes() []string {
+	for _, name := range []string{"scope", "scp"} {
+		switch v := c.All[name].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			return c.Strings(name)
+		}
+	}
+	return nil
+}
+
+// HeaderValue renders the claim at path for an upstream header: strings,
+// numbers and booleans as text, arrays of them comma-separated. ok is false
+// when the claim is missing, empty or an object, or when the result would
+// hold anything but printable ASCII, a comma inside an array element, or
+// more than maxHeaderValue bytes. Claims often carry user-chosen text, so
+// nothing is escaped or truncated: unsafe values are left out.
+func (c *Claims) HeaderValue(path string) (string, bool) {
+	v, ok := c.Lookup(path)
+	if !ok {
+		return "", false
+	}
+	var value string
+	if list, isList := v.([]interface{}); isList {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := scalarText(item)
+			if !ok || strings.Contains(s, ",") {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+		value = strings.Join(parts, ",")
+	} else if value, ok = scalarText(v); !ok {
+		return "", false
+	}
+
+	if value == "" || len(value) > maxHeaderValue {
+		return "", false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x20 || value[i] > 0x7e {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+func scalarText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+// Policy holds the claim checks of a validator
+type Policy struct {
+	Issuer    string        // Expected iss (empty = not checked)
+	Audience  []string      // aud must include one of these (empty = not checked)
+	ClockSkew time.Duration // Leeway for exp, nbf, iat and MaxAge
+	MaxAge    time.Duration // Reject tokens issued longer ago (0 = no limit; requires iat)
+
+	RolesClaim string // Claim path holding roles (empty = DefaultRolesClaim)
+}
+
+// DefaultRolesClaim holds the caller's roles unless configured otherwise
+const DefaultRolesClaim = "roles"
+
+func (p Policy) rolesClaim() string {
+	if p.RolesClaim == "" {
+		return DefaultRolesClaim
+	}
+	return p.RolesClaim
+}
+
+// Requirements override the policy's issuer and audience for one route
+type Requirements struct {
+	Issuer   string
+	Audience []string
+}
+
+// checkClaims applies the time, issuer and audience checks at now
+func (p Policy) checkClaims(c *Claims, req Requirements, now time.Time) error {
+	skew := p.ClockSkew
+	if c.Exp > 0 && now.After(time.Unix(c.Exp, 0).Add(skew)) {
+		return ErrExpiredToken
+	}
+	if c.Nbf > 0 && now.Add(skew).Before(time.Unix(c.Nbf, 0)) {
+		return ErrNotYetValid
+	}
+	if c.Iat > 0 && now.Add(skew).Before(time.Unix(c.Iat, 0)) {
+		return ErrIssuedInFuture
+	}
+	if p.MaxAge > 0 {
+		if c.Iat == 0 {
+			return ErrMissingIssuedAt
+		}
+		if now.Sub(time.Unix(c.Iat, 0)) > p.MaxAge+skew {
+			return ErrTokenTooOld
+		}
+	}
+
+	issuer, audience := p.Issuer, p.Audience
+	if req.Issuer != "" {
+		issuer = req.Issuer
+	}
+	if len(req.Audience) > 0 {
+		audience = req.Audience
+	}
+	if issuer != "" && c.Iss != issuer {
+		return ErrInvalidIssuer
+	}
+	if len(audience) > 0 && !c.Aud.ContainsAny(audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudienceUnmarshal(t *testing.T) {
+	var c Claims
+	if err := json.Unmarshal([]byte(`{"aud":"orders"}`), &c); err != nil || len(c.Aud) != 1 || c.Aud[0] != "orders" {
+		t.Errorf("Expected single audience, got %v (%v)", c.Aud, err)
+	}
+	if err := json.Unmarshal([]byte(`{"aud":["orders","billing"]}`), &c); err != nil || !c.Aud.ContainsAny([]string{"billing"}) {
+		t.Errorf("Expected audience list, got %v (%v)", c.Aud, err)
+	}
+	if err := json.Unmarshal([]byte(`{"aud":42}`), &c); err == nil {
+		t.Error("Expected error for numeric audience")
+	}
+}
+
+func TestPolicyCheckClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	policy := Policy{Issuer: "https://idp.example.com", Audience: []string{"gateway"}, ClockSkew: 30 * time.Second, MaxAge: time.Hour}
+	valid := Claims{Iss: "https://idp.example.com", Aud: Audience{"gateway"}, Exp: at(time.Minute), Iat: at(-time.Minute)}
+
+	tests := []struct {
+		name   string
+		modify func(c *Claims)
+		req    Requirements
+		err    error
+	}{
+		{"valid", func(c *Claims) {}, Requirements{}, nil},
+		{"expired within skew", func(c *Claims) { c.Exp = at(-20 * time.Second) }, Requirements{}, nil},
+		{"expired", func(c *Claims) { c.Exp = at(-time.Minute) }, Requirements{}, ErrExpiredToken},
+		{"nbf within skew", func(c *Claims) { c.Nbf = at(20 * time.Second) }, Requirements{}, nil},
+		{"not yet valid", func(c *Claims) { c.Nbf = at(time.Minute) }, Requirements{}, ErrNotYetValid},
+		{"issued in the future", func(c *Claims) { c.Iat = at(time.Minute) }, Requirements{}, ErrIssuedInFuture},
+		{"too old", func(c *Claims) { c.Iat = at(-2 * time.Hour) }, Requirements{}, ErrTokenTooOld},
+		{"no iat with max age", func(c *Claims) { c.Iat = 0 }, Requirements{}, ErrMissingIssuedAt},
+		{"wrong issuer", func(c *Claims) { c.Iss = "https://other.example.com" }, Requirements{}, ErrInvalidIssuer},
+		{"wrong audience", func(c *Claims) { c.Aud = Audience{"billing"} }, Requirements{}, ErrInvalidAudience},
+		{"no audience", func(c *Claims) { c.Aud = nil }, Requirements{}, ErrInvalidAudience},
+		{"one of several audiences", func(c *Claims) { c.Aud = Audience{"billing", "gateway"} }, Requirements{}, nil},
+		{"route issuer", func(c *Claims) { c.Iss = "https://partner.example.com" }, Requirements{Issuer: "https://partner.example.com"}, nil},
+		{"route audience", func(c *Claims) {}, Requirements{Audience: []string{"orders"}}, ErrInvalidAudience},
+		{"route audience matches", func(c *Claims) { c.Aud = Audience{"orders"} }, Requirements{Audience: []string{"orders"}}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := valid
+			tc.modify(&c)
+			if err := policy.checkClaims(&c, tc.req, now); err != tc.err {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// Without a policy only exp, nbf and iat are checked, without leeway
+	c := Claims{Exp: at(-time.Second)}
+	if err := (Policy{}).checkClaims(&c, Requirements{}, now); err != ErrExpiredToken {
+		t.Errorf("Expected ErrExpiredToken without leeway, got %v", err)
+	}
+	if err := (Policy{}).checkClaims(&Claims{Iss: "anyone"}, Requirements{}, now); err != nil {
+		t.Errorf("Expected no issuer or audience check, got %v", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, err := range []*Error{
+		ErrInvalidToken, ErrExpiredToken, ErrInvalidSignature, ErrUnknownKey, ErrAlgorithm, ErrNotYetValid,
+		ErrIssuedInFuture, ErrMissingIssuedAt, ErrTokenTooOld, ErrInvalidIssuer, ErrInvalidAudience,
+		ErrRevoked, ErrLifetimeTooLong,
+	} {
+		if seen[err.Code] {
+			t.Errorf("Duplicate error code %s", err.Code)
+		}
+		seen[err.Code] = true
+	}
+}
+
+func TestValidatorKeepsAllClaims(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	validator, _ := NewValidator(writePublicKey(t, &key.PublicKey), Policy{}, nil)
+
+	claims, err := validator.Validate(signedToken(t, key, ""))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if claims.All["sub"] != "user123" {
+		t.Errorf("Expected sub in All, got %v", claims.All)
+	}
+	if _, ok := claims.All["exp"].(json.Number); !ok {
+		t.Errorf("Expected exp as json.Number, got %T", claims.All["exp"])
+	}
+}
+
+func TestClaimsHeaderValue(t *testing.T) {
+	claims := claimSet(t, `{
+		"tenant": "acme",
+		"plan_id": 42,
+		"beta": true,
+		"roles": ["admin", "billing"],
+		"realm_access": {"roles": ["viewer"]},
+		"https://example.com/org": "org-1",
+		"name": "Eve\r\nX-Admin: true",
+		"unicode": "Zoë",
+		"tags": ["a,b"],
+		"mixed": ["ok", {"x": 1}],
+		"profile": {"plan": "pro"},
+		"empty": "",
+		"long": "`+strings.Repeat("x", maxHeaderValue+1)+`"
+	}`)
+
+	tests := []struct {
+		path  string
+		value string
+		ok    bool
+	}{
+		{"tenant", "acme", true},
+		{"plan_id", "42", true},
+		{"beta", "true", true},
+		{"roles", "admin,billing", true},
+		{"realm_access.roles", "viewer", true},
+		{"https://example.com/org", "org-1", true},
+		{"profile.plan", "pro", true},
+		{"missing", "", false},
+		{"tenant.id", "", false},
+		{"profile", "", false},
+		{"name", "", false},
+		{"unicode", "", false},
+		{"tags", "", false},
+		{"mixed", "", false},
+		{"empty", "", false},
+		{"long", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			value, ok := claims.HeaderValue(tc.path)
+			if value != tc.value || ok != tc.ok {
+				t.Errorf("Expected %q/%v, got %q/%v", tc.value, tc.ok, value, ok)
+			}
+		})
+	}
+}
+
+// claimSet decodes a payload the way the validator does
+func claimSet(t *testing.T, payload string) *Claims {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(payload))
+	dec.UseNumber()
+	var c Claims
+	if err := dec.Decode(&c.All); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	return &c
+}
+
+func TestClaimsScopesAndRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		scopes  []string
+		roles   []string
+	}{
+		{"scope string", `{"scope":"orders:read  orders:write","roles":["admin"]}`, []string{"orders:read", "orders:write"}, []string{"admin"}},
+		{"scp array", `{"scp":["orders:read"],"roles":"support"}`, []string{"orders:read"}, []string{"support"}},
+		{"none", `{"roles":[1,"viewer"]}`, nil, []string{"viewer"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := claimSet(t, tc.payload)
+			if scopes := c.scopes(); !slices.Equal(scopes, tc.scopes) {
+				t.Errorf("Expected scopes %v, got %v", tc.scopes, scopes)
+			}
+			if roles := c.Strings(Policy{}.rolesClaim()); !slices.Equal(roles, tc.roles) {
+				t.Errorf("Expected roles %v, got %v", tc.roles, roles)
+			}
+		})
+	}
+
+	c := claimSet(t, `{"realm_access":{"roles":["admin"]}}`)
+	if roles := c.Strings((Policy{RolesClaim: "realm_access.roles"}).rolesClaim()); !slices.Equal(roles, []string{"admin"}) {
+		t.Errorf("Expected nested roles, got %v", roles)
+	}
+}
@@ -0,0 +1,323 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWKS refresh limits
+const (
+	jwksTimeout         = 5 * time.Second  // Per fetch
+	jwksMaxSize         = 1 << 20          // 1 MB
+	jwksMinBackoff      = time.Second      // First retry after a failed refresh
+	jwksUnknownInterval = 10 * time.Second // Minimum time between refreshes for unknown kids
+)
+
+// Key is a verification key. Alg restricts it to one algorithm (empty = any).
+type Key struct {
+	ID     string
+	Alg    string
+	Public crypto.PublicKey
+}
+
+// KeySet holds the active verification keys. During a rotation it holds
+// both the old and the new key.
+type KeySet struct {
+	keys []Key
+}
+
+// Lookup returns the keys that may have signed a token with kid: those
+// with that ID, or else the keys without an ID. Tokens without kid may be
+// signed by any key.
+func (s *KeySet) Lookup(kid string) []Key {
+	if s == nil {
+		return nil
+	}
+	if kid == "" {
+		return s.keys
+	}
+	var unnamed []Key
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return []Key{k}
+		}
+		if k.ID == "" {
+			unnamed = append(unnamed, k)
+		}
+	}
+	return unnamed
+}
+
+// jwk is one entry of a JSON Web Key Set (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`   // RSA modulus
+	E   string `json:"e"`   // RSA exponent
+	Crv string `json:"crv"` // EC or OKP curve
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// errUnsupported marks keys of types or curves the gateway cannot verify
+var errUnsupported = errors.New("unsupported key")
+
+// ParseJWKS reads the signing keys of a JWKS document: RSA, EC (P-256,
+// P-384) and OKP (Ed25519). Encryption keys and unsupported key types are
+// skipped.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	set := &KeySet{}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := parseKey(k)
+		if err == errUnsupported {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (kid %q): %w", i, k.Kid, err)
+		}
+		set.keys = append(set.keys, Key{ID: k.Kid, Alg: k.Alg, Public: pub})
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return set, nil
+}
+
+func parseKey(k jwk) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		return parseRSAKey(k)
+	case "EC":
+		return parseECKey(k)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errUnsupported
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errUnsupported
+}
+
+func parseECKey(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var point ecdh.Curve
+	switch k.Crv {
+	case "P-256":
+		curve, point = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, point = elliptic.P384(), ecdh.P384()
+	default:
+		return nil, errUnsupported
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	x, errX := decodeSegment(k.X)
+	y, errY := decodeSegment(k.Y)
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, errors.New("invalid EC coordinates")
+	}
+	// Rejects points that are not on the curve
+	if _, err := point.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, errors.New("invalid EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := decodeSegment(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid RSA modulus")
+	}
+	e, err := decodeSegment(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+}
+
+// jwksSource reads a JWKS from a file or URL. URLs are fetched
+// conditionally, so unchanged documents are not downloaded again.
+type jwksSource struct {
+	location string
+	isURL    bool
+	client   *http.Client
+	etag     string
+}
+
+// NewJWKSValidator creates a validator whose keys come from a JWKS file or
+// http(s) URL, accepting algorithms (nil = DefaultAlgorithms). No keys are
+// loaded until Refresh is called.
+func NewJWKSValidator(source string, policy Policy, algorithms []string) (*Validator, error) {
+	allowed, err := algorithmSet(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	src := &jwksSource{location: source, client: &http.Client{Timeout: jwksTimeout}}
+	if strings.Contains(source, "://") {
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("JWKS URL must be http(s) (got %q)", source)
+		}
+		src.isURL = true
+	}
+	return &Validator{policy: policy, algorithms: allowed, jwks: src}, nil
+}
+
+// fetch returns the document, or nil if it has not changed since tag
+func (s *jwksSource) fetch(ctx context.Context, tag string) (data []byte, etag string, err error) {
+	if !s.isURL {
+		data, err = os.ReadFile(s.location)
+		return data, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if tag != "" {
+		req.Header.Set("If-None-Match", tag)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, tag, nil
+	case http.StatusOK:
+	default:
+		return nil, "", fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > jwksMaxSize {
+		return nil, "", errors.New("JWKS document too large")
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Refresh reloads the JWKS. On failure the current keys stay active.
+func (v *Validator) Refresh(ctx context.Context) error {
+	if v.jwks == nil {
+		return nil
+	}
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+	return v.refresh(ctx)
+}
+
+func (v *Validator) refresh(ctx context.Context) error {
+	v.lastRefresh = time.Now()
+	data, etag, err := v.jwks.fetch(ctx, v.jwks.etag)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS from %s: %w", v.jwks.location, err)
+	}
+	if data == nil {
+		return nil // Not modified
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	v.keys.Store(keys)
+	v.jwks.etag = etag // Only once the document is known to be valid
+	return nil
+}
+
+// refreshUnknown starts a background reload of the JWKS for a token signed
+// with an unknown kid, e.g. a key published after the last refresh. The
+// token itself is rejected without waiting: callers choose the kid, so
+// they must not be able to make requests wait on the JWKS endpoint.
+// Refreshes are spaced out so made-up kids cannot flood the endpoint
+// either, and are skipped while another refresh is running.
+func (v *Validator) refreshUnknown() {
+	if v.jwks == nil || !v.refreshMu.TryLock() {
+		return
+	}
+	if time.Since(v.lastRefresh) < jwksUnknownInterval {
+		v.refreshMu.Unlock()
+		return
+	}
+	v.lastRefresh = time.Now()
+	go func() {
+		defer v.refreshMu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), jwksTimeout)
+		defer cancel()
+		if err := v.refresh(ctx); err != nil {
+			log.Printf("JWKS refresh for unknown key failed: %v", err)
+		}
+	}()
+}
+
+// Watch refreshes the JWKS every interval until ctx is done. Failed
+// refreshes keep the current keys and are retried with exponential backoff
+// from a second up to interval.
+func (v *Validator) Watch(ctx context.Context, interval time.Duration) {
+	if v.jwks == nil {
+		return
+	}
+	backoff := time.Duration(0)
+	if v.KeyCount() == 0 {
+		backoff = jwksMinBackoff // Initial load failed
+	}
+	for {
+		wait := interval
+		if backoff > 0 {
+			wait = backoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := v.Refresh(ctx); err != nil {
+			backoff = min(max(2*backoff, jwksMinBackoff), interval)
+			log.Printf("%v (retrying in %s)", err, backoff)
+			continue
+		}
+		backoff = 0
+	}
+}
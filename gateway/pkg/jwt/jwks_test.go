@@ -0,0 +1,325 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKSValidator(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(jwkSet(jwkFor("old", oldKey), jwkFor("new", newKey)))
+	defer server.Close()
+
+	validator, err := NewJWKSValidator(server.URL, Policy{}, nil)
+	if err != nil {
+		t.Fatalf("NewJWKSValidator failed: %v", err)
+	}
+	if validator.KeyCount() != 0 {
+		t.Error("Expected no keys before the first refresh")
+	}
+	if err := validator.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if validator.KeyCount() != 2 {
+		t.Errorf("Expected 2 keys, got %d", validator.KeyCount())
+	}
+
+	tests := []struct {
+		name string
+		key  *rsa.PrivateKey
+		kid  string
+		err  error
+	}{
+		{"old key during rotation", oldKey, "old", nil},
+		{"new key", newKey, "new", nil},
+		{"no kid", newKey, "", nil},
+		{"kid of another key", oldKey, "new", ErrInvalidSignature},
+		{"unknown kid", newKey, "retired", ErrUnknownKey},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validator.Validate(signedToken(t, tc.key, tc.kid))
+			if err != tc.err {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(jwkSet(jwkFor("old", oldKey)))
+	defer server.Close()
+
+	validator, _ := NewJWKSValidator(server.URL, Policy{}, nil)
+	if err := validator.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	t.Run("unchanged document", func(t *testing.T) {
+		if err := validator.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+		if full, notModified := server.counts(); full != 1 || notModified != 1 {
+			t.Errorf("Expected conditional refresh, got %d full and %d not modified", full, notModified)
+		}
+	})
+
+	t.Run("failed refresh keeps keys", func(t *testing.T) {
+		server.fail(true)
+		defer server.fail(false)
+		if err := validator.Refresh(context.Background()); err == nil {
+			t.Fatal("Expected refresh error")
+		}
+		if _, err := validator.Validate(signedToken(t, oldKey, "old")); err != nil {
+			t.Errorf("Expected cached key to stay active, got %v", err)
+		}
+	})
+
+	t.Run("unknown kid refreshes", func(t *testing.T) {
+		server.set(jwkSet(jwkFor("old", oldKey), jwkFor("new", newKey)))
+		token := signedToken(t, newKey, "new")
+
+		// Spaced out: a refresh just happened
+		if _, err := validator.Validate(token); err != ErrUnknownKey {
+			t.Fatalf("Expected ErrUnknownKey right after a refresh, got %v", err)
+		}
+		validator.refreshMu.Lock()
+		validator.lastRefresh = time.Time{}
+		validator.refreshMu.Unlock()
+		if _, err := validator.Validate(token); err != ErrUnknownKey {
+			t.Errorf("Expected the token rejected while the refresh runs, got %v", err)
+		}
+		waitFor(t, func() bool {
+			_, err := validator.Validate(token)
+			return err == nil
+		})
+	})
+}
+
+func TestJWKSUnknownKidDoesNotWait(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	doc := jwkSet(jwkFor("old", key))
+	hang := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-hang // Slow endpoint after the initial load
+		}
+		w.Write(doc)
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	validator, _ := NewJWKSValidator(server.URL, Policy{}, nil)
+	if err := validator.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	validator.refreshMu.Lock()
+	validator.lastRefresh = time.Time{}
+	validator.refreshMu.Unlock()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := validator.Validate(signedToken(t, key, "made-up")); err != ErrUnknownKey {
+			t.Fatalf("Expected ErrUnknownKey, got %v", err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected unknown kids rejected without waiting on the JWKS endpoint, took %s", d)
+	}
+	waitFor(t, func() bool { return fetches.Load() == 2 })
+	if _, err := validator.Validate(signedToken(t, key, "old")); err != nil {
+		t.Errorf("Expected known keys to validate during the refresh, got %v", err)
+	}
+}
+
+func TestJWKSWatch(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(jwkSet(jwkFor("old", oldKey)))
+	defer server.Close()
+	server.fail(true) // Unreachable at startup
+
+	validator, _ := NewJWKSValidator(server.URL, Policy{}, nil)
+	if err := validator.Refresh(context.Background()); err == nil {
+		t.Fatal("Expected initial refresh to fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go validator.Watch(ctx, 20*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	server.fail(false)
+	waitFor(t, func() bool { return validator.KeyCount() == 1 })
+
+	// Rotation: the new key is picked up by the next refresh
+	server.set(jwkSet(jwkFor("new", newKey)))
+	waitFor(t, func() bool {
+		_, err := validator.Validate(signedToken(t, newKey, "new"))
+		return err == nil
+	})
+	if _, err := validator.Validate(signedToken(t, oldKey, "old")); err != ErrUnknownKey {
+		t.Errorf("Expected retired key to be rejected, got %v", err)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	enc := jwkFor("enc", key)
+	enc["use"] = "enc"
+
+	set, err := ParseJWKS(jwkSet(jwkFor("sig", key), enc, map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}))
+	if err != nil {
+		t.Fatalf("ParseJWKS failed: %v", err)
+	}
+	if len(set.keys) != 1 || set.keys[0].ID != "sig" {
+		t.Errorf("Expected only the RSA signing key, got %+v", set.keys)
+	}
+
+	if _, err := ParseJWKS(jwkSet(enc)); err == nil {
+		t.Error("Expected error without signing keys")
+	}
+	if _, err := ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"bad","n":"","e":"AQAB"}]}`)); err == nil {
+		t.Error("Expected error for invalid modulus")
+	}
+	if _, err := ParseJWKS([]byte(`not json`)); err == nil {
+		t.Error("Expected error for invalid document")
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, jwkSet(jwkFor("file", key)), 0o600)
+
+	validator, err := NewJWKSValidator(path, Policy{}, nil)
+	if err != nil {
+		t.Fatalf("NewJWKSValidator failed: %v", err)
+	}
+	if err := validator.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := validator.Validate(signedToken(t, key, "file")); err != nil {
+		t.Errorf("Expected token to validate, got %v", err)
+	}
+
+	if _, err := NewJWKSValidator("ftp://keys.example.com/jwks.json", Policy{}, nil); err == nil {
+		t.Error("Expected error for unsupported URL scheme")
+	}
+}
+
+// --- Helpers ---
+
+// jwksServer serves a JWKS with an ETag and counts full and 304 responses
+type jwksServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	doc         []byte
+	version     int
+	failing     bool
+	full        int
+	notModified int
+}
+
+func newJWKSServer(doc []byte) *jwksServer {
+	s := &jwksServer{doc: doc}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		etag := `"v` + string(rune('0'+s.version)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full++
+		w.Header().Set("ETag", etag)
+		w.Write(s.doc)
+	}))
+	return s
+}
+
+func (s *jwksServer) set(doc []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.doc = doc
+	s.version++
+}
+
+func (s *jwksServer) fail(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *jwksServer) counts() (full, notModified int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.full, s.notModified
+}
+
+func jwkFor(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func jwkSet(keys ...map[string]string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return data
+}
+
+// signedToken creates an RS256 token with kid in its header
+func signedToken(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	payload := map[string]interface{}{"sub": "user123", "exp": time.Now().Add(time.Hour).Unix()}
+
+	hJSON, _ := json.Marshal(header)
+	pJSON, _ := json.Marshal(payload)
+	msg := base64.RawURLEncoding.EncodeToString(hJSON) + "." + base64.RawURLEncoding.EncodeToString(pJSON)
+	hash := sha256.Sum256([]byte(msg))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	return msg + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
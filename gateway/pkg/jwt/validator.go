@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -12,38 +13,90 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const algorithmRS256 = "RS256"
+// Error is a token rejection. Code is the error code sent to clients.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
 
 var (
-	ErrInvalidToken     = errors.New("invalid token")
-	ErrExpiredToken     = errors.New("token expired")
-	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidToken     = &Error{"INVALID_TOKEN", "invalid token"}
+	ErrExpiredToken     = &Error{"TOKEN_EXPIRED", "token expired"}
+	ErrInvalidSignature = &Error{"INVALID_SIGNATURE", "invalid signature"}
+	ErrUnknownKey       = &Error{"UNKNOWN_KEY", "unknown signing key"}
+	ErrAlgorithm        = &Error{"ALGORITHM_NOT_ALLOWED", "algorithm not allowed"}
+	ErrNotYetValid      = &Error{"TOKEN_NOT_YET_VALID", "token not yet valid"}
+	ErrIssuedInFuture   = &Error{"TOKEN_ISSUED_IN_FUTURE", "token issued in the future"}
+	ErrMissingIssuedAt  = &Error{"TOKEN_MISSING_IAT", "token has no iat claim"}
+	ErrTokenTooOld      = &Error{"TOKEN_TOO_OLD", "token too old"}
+	ErrInvalidIssuer    = &Error{"INVALID_ISSUER", "invalid issuer"}
+	ErrInvalidAudience  = &Error{"INVALID_AUDIENCE", "invalid audience"}
+	ErrRevoked          = &Error{"TOKEN_REVOKED", "token revoked"}
+	ErrLifetimeTooLong  = &Error{"TOKEN_LIFETIME_TOO_LONG", "token lifetime exceeds the maximum"}
 )
 
 // Header represents JWT header
 type Header struct {
 	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"` // Selects the verification key
 }
 
 // Claims represents JWT payload claims
 type Claims struct {
-	Sub      string `json:"sub"`       // User ID
-	ClientID string `json:"client_id"` // For rate limiting
-	Exp      int64  `json:"exp"`       // Expiration timestamp
-	Iss      string `json:"iss"`       // Issuer
+	Sub      string   `json:"sub"`       // User ID
+	ClientID string   `json:"client_id"` // For rate limiting
+	Exp      int64    `json:"exp"`       // Expiration timestamp
+	Iss      string   `json:"iss"`       // Issuer
+	Aud      Audience `json:"aud"`       // Intended recipients
+	Nbf      int64    `json:"nbf"`       // Not valid before
+	Iat      int64    `json:"iat"`       // Issued at
+	Jti      string   `json:"jti"`       // Token ID, for revocation
+
+	// All holds every claim of the token, including custom ones such as
+	// roles or tenant. Numbers are json.Number.
+	All map[string]interface{} `json:"-"`
+
+	// Scopes come from scope (space-separated) or scp; Roles from the
+	// policy's RolesClaim
+	Scopes []string `json:"-"`
+	Roles  []string `json:"-"`
 }
 
-// Validator validates JWT tokens using RS256
+// Revocations reports whether an otherwise valid token was revoked
+type Revocations interface {
+	Revoked(claims *Claims) bool
+}
+
+// Validator validates JWT tokens signed with the allowed algorithms
 type Validator struct {
-	publicKey *rsa.PublicKey
-	issuer    string // Optional: expected issuer
+	keys        atomic.Pointer[KeySet]
+	policy      Policy
+	algorithms  map[string]bool
+	revocations Revocations   // nil = no revocation checks
+	maxLifetime time.Duration // Longest exp - iat accepted with revocations (0 = any)
+
+	// JWKS source; nil when the key was loaded from a PEM file
+	jwks        *jwksSource
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
 }
 
-// NewValidator creates a validator from PEM-encoded public key file
-func NewValidator(publicKeyPath, issuer string) (*Validator, error) {
+// NewValidator creates a validator from PEM-encoded public key file (RSA,
+// ECDSA or Ed25519). algorithms lists the accepted algorithms (nil =
+// DefaultAlgorithms); the key must be usable with one of them.
+func NewValidator(publicKeyPath string, policy Policy, algorithms []string) (*Validator, error) {
+	allowed, err := algorithmSet(algorithms)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := os.ReadFile(publicKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read public key: %w", err)
@@ -59,31 +112,65 @@ func NewValidator(publicKeyPath, issuer string) (*Validator, error) {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
 	}
 
-	rsaPub, ok := pub.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("not an RSA public key")
+	if !usable(pub, allowed) {
+		return nil, fmt.Errorf("%T cannot verify any of the allowed algorithms %v", pub, algorithms)
 	}
 
-	return &Validator{publicKey: rsaPub, issuer: issuer}, nil
+	v := &Validator{policy: policy, algorithms: allowed}
+	v.keys.Store(&KeySet{keys: []Key{{Public: pub}}})
+	return v, nil
 }
 
-// Validate verifies token and returns claims. LLD §3 steps:
-// 1. Extract parts, 2. Check alg=RS256, 3. Verify signature, 4. Check exp, 5. Check iss
+// SetRevocations makes the validator reject tokens revoked in r. Call it
+// before the validator is used. When maxLifetime is set, revocations are
+// only kept that long, so tokens without iat and exp, or living longer,
+// are rejected as well; 0 accepts any lifetime.
+func (v *Validator) SetRevocations(r Revocations, maxLifetime time.Duration) {
+	v.revocations = r
+	v.maxLifetime = maxLifetime
+}
+
+// KeyCount returns the number of verification keys loaded
+func (v *Validator) KeyCount() int {
+	if keys := v.keys.Load(); keys != nil {
+		return len(keys.keys)
+	}
+	return 0
+}
+
+// Validate verifies token against the validator's policy and returns
+// claims. LLD §3 steps:
+// 1. Extract parts, 2. Check alg is allowed, 3. Verify signature,
+// 4. Check exp, nbf, iat and age, 5. Check iss and aud, 6. Check the
+// revocation list
+//
+// The kid header selects the key; tokens without kid may be signed by any
+// loaded key. An unknown kid triggers an early JWKS refresh. A key only
+// verifies algorithms matching its type and, for JWKS keys, its alg.
 func (v *Validator) Validate(token string) (*Claims, error) {
+	return v.ValidateFor(token, Requirements{})
+}
+
+// ValidateFor is Validate with a route's issuer and audience in place of
+// the policy's
+func (v *Validator) ValidateFor(token string, req Requirements) (*Claims, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, ErrInvalidToken
 	}
 
-	// Verify header has RS256 algorithm
+	// Verify header has an allowed algorithm
 	headerBytes, err := decodeSegment(parts[0])
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 	var header Header
-	if err := json.Unmarshal(headerBytes, &header); err != nil || header.Alg != algorithmRS256 {
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
 		return nil, ErrInvalidToken
 	}
+	if !v.algorithms[header.Alg] {
+		return nil, ErrAlgorithm
+	}
 
 	// Decode payload
 	payload, err := decodeSegment(parts[1])
@@ -95,29 +182,47 @@ func (v *Validator) Validate(token string) (*Claims, error) {
 	if err := json.Unmarshal(payload, &claims); err != nil {
 		return nil, ErrInvalidToken
 	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&claims.All); err != nil {
+		return nil, ErrInvalidToken
+	}
 
-	// Verify signature: decrypt(signature, public_key) should match SHA256(header.payload)
-	// If someone tampered with payload, hashes won't match → rejected
+	// Verify signature over header.payload with the key; if someone
+	// tampered with the payload, verification fails → rejected
 	signedContent := parts[0] + "." + parts[1]
 	signature, err := decodeSegment(parts[2])
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	if err := verifyRS256Signature(v.publicKey, signedContent, signature); err != nil {
+	candidates := v.keys.Load().Lookup(header.Kid)
+	if len(candidates) == 0 {
+		if header.Kid != "" {
+			v.refreshUnknown() // Picked up by later requests
+		}
+		return nil, ErrUnknownKey
+	}
+	if !verifyAny(candidates, header.Alg, signedContent, signature) {
 		return nil, ErrInvalidSignature
 	}
 
-	// Check expiration
-	if claims.Exp > 0 && time.Now().Unix() > claims.Exp {
-		return nil, ErrExpiredToken
+	if err := v.policy.checkClaims(&claims, req, time.Now()); err != nil {
+		return nil, err
 	}
-
-	// Check issuer if configured
-	if v.issuer != "" && claims.Iss != v.issuer {
-		return nil, ErrInvalidToken
+	if v.revocations != nil {
+		if v.maxLifetime > 0 && claims.Iat == 0 {
+			return nil, ErrMissingIssuedAt
+		}
+		if v.maxLifetime > 0 && (claims.Exp == 0 || time.Duration(claims.Exp-claims.Iat)*time.Second > v.maxLifetime) {
+			return nil, ErrLifetimeTooLong
+		}
+		if v.revocations.Revoked(&claims) {
+			return nil, ErrRevoked
+		}
 	}
-
+	claims.Scopes = claims.scopes()
+	claims.Roles = claims.Strings(v.policy.rolesClaim())
 	return &claims, nil
 }
 
@@ -131,6 +236,20 @@ func decodeSegment(seg string) ([]byte, error) {
 	return base64.URLEncoding.DecodeString(seg)
 }
 
+// verifyAny reports whether one of keys produced signature with alg
+func verifyAny(keys []Key, alg, message string, signature []byte) bool {
+	verify := verifiers[alg]
+	for _, k := range keys {
+		if k.Alg != "" && k.Alg != alg {
+			continue // Key bound to another algorithm
+		}
+		if verify(k.Public, message, signature) {
+			return true
+		}
+	}
+	return false
+}
+
 func verifyRS256Signature(key *rsa.PublicKey, message string, signature []byte) error {
 	hash := sha256.Sum256([]byte(message))
 	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
@@ -19,7 +19,7 @@ func TestValidator(t *testing.T) {
 	publicKeyPath := createTempPublicKey(t, privateKey)
 	defer os.Remove(publicKeyPath)
 
-	validator, err := NewValidator(publicKeyPath, "")
+	validator, err := NewValidator(publicKeyPath, Policy{}, nil)
 	if err != nil {
 		t.Fatalf("NewValidator failed: %v", err)
 	}
@@ -75,7 +75,7 @@ func TestValidatorWithIssuer(t *testing.T) {
 	publicKeyPath := createTempPublicKey(t, privateKey)
 	defer os.Remove(publicKeyPath)
 
-	validator, _ := NewValidator(publicKeyPath, "expected-issuer")
+	validator, _ := NewValidator(publicKeyPath, Policy{Issuer: "expected-issuer"}, nil)
 
 	t.Run("correct issuer", func(t *testing.T) {
 		token := createToken(t, privateKey, "user123", "client1", "expected-issuer", time.Hour)
@@ -88,12 +88,82 @@ func TestValidatorWithIssuer(t *testing.T) {
 	t.Run("wrong issuer", func(t *testing.T) {
 		token := createToken(t, privateKey, "user123", "client1", "wrong-issuer", time.Hour)
 		_, err := validator.Validate(token)
-		if err != ErrInvalidToken {
-			t.Errorf("Expected ErrInvalidToken for wrong issuer, got %v", err)
+		if err != ErrInvalidIssuer {
+			t.Errorf("Expected ErrInvalidIssuer for wrong issuer, got %v", err)
 		}
 	})
 }
 
+// revokeSubject revokes every token of one subject
+type revokeSubject string
+
+func (r revokeSubject) Revoked(c *Claims) bool { return c.Sub == string(r) }
+
+func TestValidatorRevocations(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	publicKeyPath := createTempPublicKey(t, privateKey)
+	defer os.Remove(publicKeyPath)
+
+	validator, _ := NewValidator(publicKeyPath, Policy{}, nil)
+	validator.SetRevocations(revokeSubject("leaked"), 24*time.Hour)
+
+	if _, err := validator.Validate(createToken(t, privateKey, "leaked", "client1", "", time.Hour)); err != ErrRevoked {
+		t.Errorf("Expected ErrRevoked, got %v", err)
+	}
+	if _, err := validator.Validate(createToken(t, privateKey, "user123", "client1", "", time.Hour)); err != nil {
+		t.Errorf("Expected other subjects valid, got %v", err)
+	}
+	// Expiry is reported before revocation
+	if _, err := validator.Validate(createToken(t, privateKey, "leaked", "client1", "", -time.Hour)); err != ErrExpiredToken {
+		t.Errorf("Expected ErrExpiredToken, got %v", err)
+	}
+
+	// Revocations expire with the longest token lifetime, so tokens that
+	// could outlive them are rejected
+	now := time.Now()
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    error
+	}{
+		{"no iat", map[string]interface{}{"sub": "u1", "exp": now.Add(time.Hour).Unix()}, ErrMissingIssuedAt},
+		{"no exp", map[string]interface{}{"sub": "u1", "iat": now.Unix()}, ErrLifetimeTooLong},
+		{"too long", map[string]interface{}{"sub": "u1", "iat": now.Unix(), "exp": now.Add(25 * time.Hour).Unix()}, ErrLifetimeTooLong},
+		{"max lifetime", map[string]interface{}{"sub": "u1", "iat": now.Unix(), "exp": now.Add(24 * time.Hour).Unix()}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := validator.Validate(signPayload(t, privateKey, tc.payload)); err != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestValidatorRevocationsWithoutLifetime(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	publicKeyPath := createTempPublicKey(t, privateKey)
+	defer os.Remove(publicKeyPath)
+
+	validator, _ := NewValidator(publicKeyPath, Policy{}, nil)
+	validator.SetRevocations(revokeSubject("leaked"), 0)
+
+	// Without a max lifetime, tokens of any lifetime are accepted
+	now := time.Now()
+	for name, payload := range map[string]map[string]interface{}{
+		"no iat":     {"sub": "u1", "exp": now.Add(time.Hour).Unix()},
+		"no exp":     {"sub": "u1", "iat": now.Unix()},
+		"long-lived": {"sub": "u1", "iat": now.Unix(), "exp": now.Add(365 * 24 * time.Hour).Unix()},
+	} {
+		if _, err := validator.Validate(signPayload(t, privateKey, payload)); err != nil {
+			t.Errorf("%s: expected valid, got %v", name, err)
+		}
+	}
+	if _, err := validator.Validate(signPayload(t, privateKey, map[string]interface{}{"sub": "leaked", "iat": now.Unix()})); err != ErrRevoked {
+		t.Errorf("Expected revocations still checked, got %v", err)
+	}
+}
+
 // --- Helpers ---
 
 func createTempPublicKey(t *testing.T, key *rsa.PrivateKey) string {
@@ -109,16 +179,23 @@ func createTempPublicKey(t *testing.T, key *rsa.PrivateKey) string {
 func createToken(t *testing.T, key *rsa.PrivateKey, sub, clientID, issuer string, exp time.Duration) string {
 	t.Helper()
 
-	header := map[string]string{"alg": "RS256", "typ": "JWT"}
 	payload := map[string]interface{}{
 		"sub":       sub,
 		"client_id": clientID,
+		"iat":       time.Now().Unix(),
 		"exp":       time.Now().Add(exp).Unix(),
 	}
 	if issuer != "" {
 		payload["iss"] = issuer
 	}
+	return signPayload(t, key, payload)
+}
 
+// signPayload creates an RS256 token with the given claims
+func signPayload(t *testing.T, key *rsa.PrivateKey, payload map[string]interface{}) string {
+	t.Helper()
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
 	hJSON, _ := json.Marshal(header)
 	pJSON, _ := json.Marshal(payload)
 
@@ -0,0 +1,132 @@
+// Package mtls authenticates callers by their TLS client certificate.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Field names a certificate attribute used to derive an identity
+type Field string
+
+const (
+	FieldCN       Field = "cn"        // Subject common name
+	FieldOU       Field = "ou"        // First subject organizational unit
+	FieldO        Field = "o"         // First subject organization
+	FieldSANDNS   Field = "san_dns"   // First DNS SAN
+	FieldSANEmail Field = "san_email" // First email SAN
+	FieldSANURI   Field = "san_uri"   // First URI SAN, e.g. spiffe://partner/client
+)
+
+var (
+	ErrNoCertificate         = errors.New("client certificate required")
+	ErrUnverifiedCertificate = errors.New("client certificate not verified")
+	ErrMissingIdentity       = errors.New("client certificate has no identity")
+)
+
+// Identity is the caller derived from a verified client certificate
+type Identity struct {
+	UserID   string
+	ClientID string
+	Subject  string
+}
+
+// Authenticator maps verified client certificates to identities.
+// Chain verification against the CA happens during the TLS handshake;
+// Authenticate only accepts connections whose chain was verified.
+type Authenticator struct {
+	userField   Field
+	clientField Field
+}
+
+// NewAuthenticator creates an authenticator using the given certificate
+// fields for the user ID and client ID
+func NewAuthenticator(userField, clientField string) (*Authenticator, error) {
+	uf, err := parseField(userField)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := parseField(clientField)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{userField: uf, clientField: cf}, nil
+}
+
+// Authenticate returns the identity of the connection's client certificate
+func (a *Authenticator) Authenticate(state *tls.ConnectionState) (*Identity, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, ErrNoCertificate
+	}
+	if len(state.VerifiedChains) == 0 {
+		return nil, ErrUnverifiedCertificate
+	}
+
+	leaf := state.VerifiedChains[0][0]
+	id := &Identity{
+		UserID:   fieldValue(leaf, a.userField),
+		ClientID: fieldValue(leaf, a.clientField),
+		Subject:  leaf.Subject.String(),
+	}
+	if id.UserID == "" {
+		return nil, ErrMissingIdentity
+	}
+	return id, nil
+}
+
+// HasCertificate reports whether the client presented a certificate
+func HasCertificate(state *tls.ConnectionState) bool {
+	return state != nil && len(state.PeerCertificates) > 0
+}
+
+// LoadCAPool reads PEM-encoded CA certificates used to verify clients
+func LoadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found in client CA file")
+	}
+	return pool, nil
+}
+
+func parseField(name string) (Field, error) {
+	switch f := Field(strings.ToLower(name)); f {
+	case FieldCN, FieldOU, FieldO, FieldSANDNS, FieldSANEmail, FieldSANURI:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown certificate field %q", name)
+}
+
+func fieldValue(cert *x509.Certificate, field Field) string {
+	switch field {
+	case FieldCN:
+		return cert.Subject.CommonName
+	case FieldOU:
+		return first(cert.Subject.OrganizationalUnit)
+	case FieldO:
+		return first(cert.Subject.Organization)
+	case FieldSANDNS:
+		return first(cert.DNSNames)
+	case FieldSANEmail:
+		return first(cert.EmailAddresses)
+	case FieldSANURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	}
+	return ""
+}
+
+func first(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCA creates a self-signed CA certificate and key
+func newCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Partner CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// newClientCert issues a client certificate signed by the CA
+func newClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	uri, _ := url.Parse("spiffe://partners/acme")
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			CommonName:         "acme-integration",
+			OrganizationalUnit: []string{"acme-client"},
+		},
+		EmailAddresses: []string{"ops@acme.example"},
+		URIs:           []*url.URL{uri},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create client cert: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+// verifiedState builds the connection state the TLS handshake would produce
+func verifiedState(t *testing.T, ca, client *x509.Certificate) *tls.ConnectionState {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	chains, err := client.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatalf("Failed to verify client cert: %v", err)
+	}
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}, VerifiedChains: chains}
+}
+
+func TestAuthenticateMapsFields(t *testing.T) {
+	ca, caKey := newCA(t)
+	state := verifiedState(t, ca, newClientCert(t, ca, caKey))
+
+	tests := []struct {
+		userField, clientField string
+		expectedUser           string
+		expectedClient         string
+	}{
+		{"cn", "ou", "acme-integration", "acme-client"},
+		{"san_email", "cn", "ops@acme.example", "acme-integration"},
+		{"san_uri", "san_uri", "spiffe://partners/acme", "spiffe://partners/acme"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.userField+"/"+tc.clientField, func(t *testing.T) {
+			auth, err := NewAuthenticator(tc.userField, tc.clientField)
+			if err != nil {
+				t.Fatalf("NewAuthenticator failed: %v", err)
+			}
+			id, err := auth.Authenticate(state)
+			if err != nil {
+				t.Fatalf("Authenticate failed: %v", err)
+			}
+			if id.UserID != tc.expectedUser {
+				t.Errorf("Expected user '%s', got '%s'", tc.expectedUser, id.UserID)
+			}
+			if id.ClientID != tc.expectedClient {
+				t.Errorf("Expected client '%s', got '%s'", tc.expectedClient, id.ClientID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejectsMissingOrUnverified(t *testing.T) {
+	ca, caKey := newCA(t)
+	client := newClientCert(t, ca, caKey)
+	auth, _ := NewAuthenticator("cn", "ou")
+
+	if _, err := auth.Authenticate(nil); err != ErrNoCertificate {
+		t.Errorf("Expected ErrNoCertificate for nil state, got %v", err)
+	}
+	if _, err := auth.Authenticate(&tls.ConnectionState{}); err != ErrNoCertificate {
+		t.Errorf("Expected ErrNoCertificate without peer certs, got %v", err)
+	}
+
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}
+	if _, err := auth.Authenticate(unverified); err != ErrUnverifiedCertificate {
+		t.Errorf("Expected ErrUnverifiedCertificate, got %v", err)
+	}
+}
+
+func TestAuthenticateMissingIdentity(t *testing.T) {
+	ca, caKey := newCA(t)
+	state := verifiedState(t, ca, newClientCert(t, ca, caKey))
+
+	auth, _ := NewAuthenticator("san_dns", "cn") // Client cert has no DNS SAN
+	if _, err := auth.Authenticate(state); err != ErrMissingIdentity {
+		t.Errorf("Expected ErrMissingIdentity, got %v", err)
+	}
+}
+
+func TestNewAuthenticatorUnknownField(t *testing.T) {
+	if _, err := NewAuthenticator("serial", "cn"); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
+
+func TestLoadCAPool(t *testing.T) {
+	ca, _ := newCA(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+
+	if _, err := LoadCAPool(path); err != nil {
+		t.Errorf("LoadCAPool failed: %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not a cert"), 0600)
+	if _, err := LoadCAPool(empty); err == nil {
+		t.Error("Expected error for file without certificates")
+	}
+}
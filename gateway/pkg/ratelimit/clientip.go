@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Proxies are the load balancers in front of the gateway. Only the
+// X-Forwarded-For entries they append are trusted.
+type Proxies []netip.Prefix
+
+// ParseProxies parses IP addresses and CIDR ranges such as 10.0.0.0/8
+func ParseProxies(list []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(list))
+	for _, item := range list {
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q", item)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the address of the client that sent r. That is the
+// connection's address unless it is a trusted proxy; then X-Forwarded-For
+// is read from the right, skipping trusted proxies, and the first other
+// hop is the client. Entries further left were written by the client and
+// are ignored, so a forged header cannot change the result.
+func (p Proxies) ClientIP(r *http.Request) string {
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	addr, err := netip.ParseAddr(client)
+	if err != nil || !p.trusted(addr) {
+		return client
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // Not written by a proxy we trust; keep the last trusted hop
+		}
+		client = hop.Unmap().String()
+		if !p.trusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+func (p Proxies) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatalf("ParseProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"direct ignores XFF", "203.0.113.7:5123", []string{"1.1.1.1"}, "203.0.113.7"},
+		{"behind proxy", "10.0.0.2:5123", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry left of the proxy's", "10.0.0.2:5123", []string{"1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{"proxy chain", "10.0.0.2:5123", []string{"203.0.113.7, 192.168.1.5"}, "203.0.113.7"},
+		{"multiple headers", "10.0.0.2:5123", []string{"1.1.1.1", "203.0.113.7"}, "203.0.113.7"},
+		{"only proxies", "10.0.0.2:5123", []string{"10.0.0.9"}, "10.0.0.9"},
+		{"malformed hop", "10.0.0.2:5123", []string{"203.0.113.7, garbage"}, "10.0.0.2"},
+		{"no header", "10.0.0.2:5123", nil, "10.0.0.2"},
+		{"IPv4-mapped proxy", "[::ffff:10.0.0.2]:5123", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := proxies.ClientIP(r); got != tc.want {
+				t.Errorf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIPSpoofedHeaderKeepsKey(t *testing.T) {
+	var none Proxies
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:5123"
+	want := none.ClientIP(r)
+	for _, spoof := range []string{"1.1.1.1", "2.2.2.2, 3.3.3.3", "garbage"} {
+		r.Header.Set("X-Forwarded-For", spoof)
+		if got := none.ClientIP(r); got != want {
+			t.Errorf("Expected X-Forwarded-For %q ignored, got %s", spoof, got)
+		}
+	}
+}
+
+func TestParseProxiesInvalid(t *testing.T) {
+	if _, err := ParseProxies([]string{"10.0.0.0/8", "not-an-ip"}); err == nil {
+		t.Error("Expected error for invalid proxy")
+	}
+}
@@ -63,6 +63,11 @@ func NewLimiter(r redis.Evaluator, limit int) *Limiter {
 	return &Limiter{redis: r, limit: limit}
 }
 
+// Limit returns the configured requests per window
+func (l *Limiter) Limit() int {
+	return l.limit
+}
+
 // Result of a rate limit check
 type Result struct {
 	Allowed    bool
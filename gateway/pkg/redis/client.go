@@ -20,10 +20,12 @@ type Client struct {
 var _ Evaluator = (*Client)(nil)
 
 // New creates a Redis client
-func New(addr string) *Client {
+func New(addr, password string, db int) *Client {
 	return &Client{
 		rdb: redis.NewClient(&redis.Options{
 			Addr:         addr,
+			Password:     password,
+			DB:           db,
 			DialTimeout:  1 * time.Second,
 			ReadTimeout:  1 * time.Second,
 			WriteTimeout: 1 * time.Second,
@@ -0,0 +1,22 @@
+// Package redistest runs the gateway's Lua scripts against an in-process
+// Redis (miniredis) in tests, so a broken script fails its package's tests.
+package redistest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+)
+
+// New starts a Redis server that is stopped when the test ends, and returns
+// it with a client connected to it. Use the server to inspect keys, inject
+// failures with SetError, and move time with SetTime or FastForward.
+func New(t testing.TB) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.New(server.Addr(), "", 0)
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
@@ -0,0 +1,342 @@
+// Package revocation rejects JWTs before they expire: single tokens by jti,
+// or every token of a subject issued up to a point in time. Entries live in
+// Redis until the tokens they cover have expired. Each instance checks a
+// local copy, kept current through Pub/Sub, so requests never wait on Redis.
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+)
+
+// Redis key layout
+const (
+	JTIPrefix     = "revoked:jti:"        // revoked:jti:{jti} holds an Entry, expiring with it
+	SubjectPrefix = "revoked:sub:"        // revoked:sub:{sub} holds an Entry, expiring with it
+	JTIIndex      = "revoked:jtis"        // Sorted set of revoked jtis, scored by expiry
+	SubjectIndex  = "revoked:subjects"    // Sorted set of revoked subjects, scored by expiry
+	Channel       = "revocations:changed" // Carries each added or removed Entry
+)
+
+// Entry kinds
+const (
+	KindJTI     = "jti"
+	KindSubject = "subject"
+)
+
+var (
+	// ErrNotFound means no revocation exists for the given jti or subject
+	ErrNotFound = errors.New("revocation not found")
+	// ErrConflict means the entry kept changing while it was being stored
+	ErrConflict = errors.New("revocation was modified concurrently")
+)
+
+// maxAttempts bounds the retries of a revocation racing other writers
+const maxAttempts = 3
+
+// Entry is one revocation
+type Entry struct {
+	Kind string `json:"kind"` // KindJTI or KindSubject
+	ID   string `json:"id"`   // The jti or subject
+	// Before revokes a subject's tokens issued at or before it
+	Before    *time.Time `json:"before,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"` // Dropped once the covered tokens have expired
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Removed   bool       `json:"removed,omitempty"` // Set in notifications of removals
+}
+
+// Stores an entry with its expiry, indexes it and announces it, if the
+// stored entry is still ARGV[5], empty for none. Returns 1, or 0 on conflict.
+const revokeScript = `
+if (redis.call('GET', KEYS[1]) or '') ~= ARGV[5] then
+    return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[3])
+redis.call('PUBLISH', ARGV[4], ARGV[1])
+return 1
+`
+
+// Deletes an entry and announces it; returns the number deleted
+const removeScript = `
+redis.call('ZREM', KEYS[2], ARGV[1])
+local n = redis.call('DEL', KEYS[1])
+if n == 1 then
+    redis.call('PUBLISH', ARGV[2], ARGV[3])
+end
+return n
+`
+
+// Returns the stored value, or "" if none
+const getScript = `return redis.call('GET', KEYS[1]) or ''`
+
+// Drops expired index members and returns the remaining entries
+const loadScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+local ids = redis.call('ZRANGE', KEYS[1], 0, -1)
+local entries = {}
+for _, id in ipairs(ids) do
+    local data = redis.call('GET', ARGV[2] .. id)
+    if data then
+        table.insert(entries, data)
+    end
+end
+return entries
+`
+
+// List is the revocation list of one instance. It implements
+// jwt.Revocations.
+type List struct {
+	redis redis.Evaluator
+	now   func() time.Time
+
+	mu       sync.RWMutex
+	jtis     map[string]Entry
+	subjects map[string]Entry
+	synced   time.Time // Last successful Sync; zero until the first
+	journal  []Entry   // Changes applied while a Sync is loading; nil otherwise
+
+	syncMu sync.Mutex // Serializes Syncs
+}
+
+var _ jwt.Revocations = (*List)(nil)
+
+// NewList creates an empty revocation list; Sync or Watch fills it
+func NewList(client redis.Evaluator) *List {
+	return &List{
+		redis:    client,
+		now:      time.Now,
+		jtis:     make(map[string]Entry),
+		subjects: make(map[string]Entry),
+	}
+}
+
+// Revoked reports whether claims belong to a revoked token. Tokens without
+// iat count as issued before any subject revocation.
+func (l *List) Revoked(claims *jwt.Claims) bool {
+	now := l.now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if e, ok := l.jtis[claims.Jti]; ok && claims.Jti != "" && now.Before(e.ExpiresAt) {
+		return true
+	}
+	if e, ok := l.subjects[claims.Sub]; ok && claims.Sub != "" && now.Before(e.ExpiresAt) {
+		return claims.Iat <= e.Before.Unix()
+	}
+	return false
+}
+
+// RevokeToken revokes the token with jti until expiresAt, normally the
+// token's exp
+func (l *List) RevokeToken(ctx context.Context, jti string, expiresAt time.Time, by, reason string) (*Entry, error) {
+	return l.revoke(ctx, Entry{Kind: KindJTI, ID: jti, ExpiresAt: expiresAt, CreatedBy: by, Reason: reason})
+}
+
+// RevokeSubject revokes every token of sub issued at or before before. The
+// entry is kept until expiresAt, when all those tokens have expired. An
+// existing revocation of sub is only ever widened: the later before and
+// the later expiry win.
+func (l *List) RevokeSubject(ctx context.Context, sub string, before, expiresAt time.Time, by, reason string) (*Entry, error) {
+	before = before.UTC()
+	return l.revoke(ctx, Entry{Kind: KindSubject, ID: sub, Before: &before, ExpiresAt: expiresAt, CreatedBy: by, Reason: reason})
+}
+
+// Remove lifts the revocation of a jti or subject
+func (l *List) Remove(ctx context.Context, kind, id string) error {
+	prefix, index, err := layout(kind)
+	if err != nil {
+		return err
+	}
+	e := Entry{Kind: kind, ID: id, Removed: true}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	result, err := l.redis.Eval(ctx, removeScript, []string{prefix + id, index}, id, Channel, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to remove revocation: %w", err)
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrNotFound
+	}
+	l.Apply(e)
+	return nil
+}
+
+// Entries returns the unexpired revocations known to this instance,
+// soonest expiry first
+func (l *List) Entries() []Entry {
+	now := l.now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entries := make([]Entry, 0, len(l.jtis)+len(l.subjects))
+	for _, m := range []map[string]Entry{l.jtis, l.subjects} {
+		for _, e := range m {
+			if now.Before(e.ExpiresAt) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].ExpiresAt.Equal(entries[j].ExpiresAt) {
+			return entries[i].ExpiresAt.Before(entries[j].ExpiresAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// Apply adds or, for removals, drops e in the local copy
+func (l *List) Apply(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.journal != nil {
+		l.journal = append(l.journal, e)
+	}
+	l.apply(e)
+}
+
+func (l *List) apply(e Entry) {
+	m := l.jtis
+	if e.Kind == KindSubject {
+		m = l.subjects
+	}
+	if e.Removed {
+		delete(m, e.ID)
+		return
+	}
+	m[e.ID] = e
+}
+
+// Sync replaces the local copy with the entries stored in Redis. Changes
+// applied while the entries are loading are replayed on top, so a
+// notification racing the load is not lost.
+func (l *List) Sync(ctx context.Context) error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+
+	l.mu.Lock()
+	l.journal = []Entry{}
+	l.mu.Unlock()
+
+	jtis, err := l.load(ctx, JTIPrefix, JTIIndex)
+	var subjects map[string]Entry
+	if err == nil {
+		subjects, err = l.load(ctx, SubjectPrefix, SubjectIndex)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	journal := l.journal
+	l.journal = nil
+	if err != nil {
+		return err
+	}
+	l.jtis, l.subjects = jtis, subjects
+	for _, e := range journal {
+		l.apply(e)
+	}
+	l.synced = l.now()
+	return nil
+}
+
+// Synced returns the time of the last successful Sync, zero before the
+// first. Until then revoked tokens may be accepted.
+func (l *List) Synced() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.synced
+}
+
+// Len returns the number of revocations held locally
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.jtis) + len(l.subjects)
+}
+
+// revoke stores e, widened by any entry already stored for its ID
+func (l *List) revoke(ctx context.Context, e Entry) (*Entry, error) {
+	prefix, index, err := layout(e.Kind)
+	if err != nil {
+		return nil, err
+	}
+	e.ExpiresAt = e.ExpiresAt.UTC()
+	e.CreatedAt = l.now().UTC()
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		result, err := l.redis.Eval(ctx, getScript, []string{prefix + e.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read revocation: %w", err)
+		}
+		current, _ := result.(string)
+		merged := e
+		var stored Entry
+		if current != "" && json.Unmarshal([]byte(current), &stored) == nil {
+			merged = widen(stored, e)
+		}
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+		result, err = l.redis.Eval(ctx, revokeScript, []string{prefix + e.ID, index},
+			string(data), merged.ExpiresAt.UnixMilli(), e.ID, Channel, current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store revocation: %w", err)
+		}
+		if n, _ := result.(int64); n == 1 {
+			l.Apply(merged)
+			return &merged, nil
+		}
+	}
+	return nil, ErrConflict
+}
+
+// widen returns e extended to cover everything stored covers as well
+func widen(stored, e Entry) Entry {
+	if stored.ExpiresAt.After(e.ExpiresAt) {
+		e.ExpiresAt = stored.ExpiresAt
+	}
+	if stored.Before != nil && (e.Before == nil || stored.Before.After(*e.Before)) {
+		e.Before = stored.Before
+	}
+	return e
+}
+
+func (l *List) load(ctx context.Context, prefix, index string) (map[string]Entry, error) {
+	result, err := l.redis.Eval(ctx, loadScript, []string{index}, l.now().UnixMilli(), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revocations: %w", err)
+	}
+	raw, _ := result.([]interface{})
+	entries := make(map[string]Entry, len(raw))
+	for _, item := range raw {
+		var e Entry
+		if data, ok := item.(string); ok && json.Unmarshal([]byte(data), &e) == nil {
+			entries[e.ID] = e
+		}
+	}
+	return entries, nil
+}
+
+// layout returns the key prefix and index of kind
+func layout(kind string) (prefix, index string, err error) {
+	switch kind {
+	case KindJTI:
+		return JTIPrefix, JTIIndex, nil
+	case KindSubject:
+		return SubjectPrefix, SubjectIndex, nil
+	}
+	return "", "", fmt.Errorf("unknown revocation kind %q", kind)
+}
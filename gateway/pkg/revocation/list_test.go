@@ -0,0 +1,292 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/pkg/jwt"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+// hookedRedis runs before ahead of each script, to interleave other
+// writers and notifications with the list's own calls
+type hookedRedis struct {
+	redis.Evaluator
+	before func(script string)
+}
+
+func (h *hookedRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	h.before(script)
+	return h.Evaluator.Eval(ctx, script, keys, args...)
+}
+
+var testNow = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newTestRedis starts a Redis whose clock stands at testNow
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, client := redistest.New(t)
+	server.SetTime(testNow)
+	return server, client
+}
+
+func newTestList(client redis.Evaluator) (*List, *time.Time) {
+	list := NewList(client)
+	now := testNow
+	list.now = func() time.Time { return now }
+	return list, &now
+}
+
+func TestRevokeToken(t *testing.T) {
+	_, client := newTestRedis(t)
+	list, now := newTestList(client)
+	ctx := context.Background()
+	exp := testNow.Add(time.Hour)
+
+	if _, err := list.RevokeToken(ctx, "tok-1", exp, "alice", "leaked"); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if !list.Revoked(&jwt.Claims{Jti: "tok-1", Sub: "u1"}) {
+		t.Error("Expected revoked jti")
+	}
+	if list.Revoked(&jwt.Claims{Jti: "tok-2", Sub: "u1"}) || list.Revoked(&jwt.Claims{Sub: "u1"}) {
+		t.Error("Expected other tokens valid")
+	}
+
+	*now = exp
+	if list.Revoked(&jwt.Claims{Jti: "tok-1"}) {
+		t.Error("Expected entry ignored once the token has expired")
+	}
+}
+
+func TestRevokeSubject(t *testing.T) {
+	_, client := newTestRedis(t)
+	list, _ := newTestList(client)
+	ctx := context.Background()
+
+	if _, err := list.RevokeSubject(ctx, "u1", testNow, testNow.Add(24*time.Hour), "alice", ""); err != nil {
+		t.Fatalf("RevokeSubject failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.Claims
+		revoked bool
+	}{
+		{"issued before", jwt.Claims{Sub: "u1", Iat: testNow.Add(-time.Minute).Unix()}, true},
+		{"issued at the revocation", jwt.Claims{Sub: "u1", Iat: testNow.Unix()}, true},
+		{"issued after", jwt.Claims{Sub: "u1", Iat: testNow.Add(time.Second).Unix()}, false},
+		{"no iat", jwt.Claims{Sub: "u1"}, true},
+		{"other subject", jwt.Claims{Sub: "u2", Iat: testNow.Add(-time.Minute).Unix()}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := list.Revoked(&tc.claims); got != tc.revoked {
+				t.Errorf("Expected revoked=%v, got %v", tc.revoked, got)
+			}
+		})
+	}
+}
+
+func TestRevokeSubjectWidens(t *testing.T) {
+	server, client := newTestRedis(t)
+	a, _ := newTestList(client)
+	b, _ := newTestList(client)
+	ctx := context.Background()
+
+	a.RevokeSubject(ctx, "u1", testNow, testNow.Add(24*time.Hour), "alice", "")
+	// A narrower revocation from another instance keeps the wider entry
+	entry, err := b.RevokeSubject(ctx, "u1", testNow.Add(-time.Hour), testNow.Add(23*time.Hour), "bob", "")
+	if err != nil {
+		t.Fatalf("RevokeSubject failed: %v", err)
+	}
+	if !entry.Before.Equal(testNow) || !entry.ExpiresAt.Equal(testNow.Add(24*time.Hour)) {
+		t.Errorf("Expected the later before and expiry kept, got %v and %v", entry.Before, entry.ExpiresAt)
+	}
+	if ttl := server.TTL(SubjectPrefix + "u1"); ttl != 24*time.Hour {
+		t.Errorf("Expected stored entry kept for 24h, got %s", ttl)
+	}
+
+	// A later before extends the revocation
+	entry, _ = b.RevokeSubject(ctx, "u1", testNow.Add(time.Hour), testNow.Add(2*time.Hour), "bob", "")
+	if !entry.Before.Equal(testNow.Add(time.Hour)) || !entry.ExpiresAt.Equal(testNow.Add(24*time.Hour)) {
+		t.Errorf("Expected before moved forward and expiry kept, got %v and %v", entry.Before, entry.ExpiresAt)
+	}
+	if !b.Revoked(&jwt.Claims{Sub: "u1", Iat: testNow.Add(30 * time.Minute).Unix()}) {
+		t.Error("Expected tokens up to the later before revoked")
+	}
+}
+
+func TestRevokeConflict(t *testing.T) {
+	server, client := newTestRedis(t)
+	writes := 0
+	hooked := &hookedRedis{Evaluator: client, before: func(script string) {
+		// Another instance stores a wider entry between each read and write
+		if script == revokeScript && writes < maxAttempts {
+			writes++
+			other := `{"kind":"subject","id":"u1","before":"` + testNow.Add(time.Duration(writes)*time.Minute).Format(time.RFC3339) +
+				`","expires_at":"` + testNow.Add(48*time.Hour).Format(time.RFC3339) + `"}`
+			server.Set(SubjectPrefix+"u1", other)
+		}
+	}}
+	list, _ := newTestList(hooked)
+	ctx := context.Background()
+
+	if _, err := list.RevokeSubject(ctx, "u1", testNow, testNow.Add(24*time.Hour), "alice", ""); err != ErrConflict {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	// Once the other writer stops, its entry is merged instead of overwritten
+	entry, err := list.RevokeSubject(ctx, "u1", testNow, testNow.Add(24*time.Hour), "alice", "")
+	if err != nil {
+		t.Fatalf("RevokeSubject failed: %v", err)
+	}
+	if !entry.Before.Equal(testNow.Add(3*time.Minute)) || !entry.ExpiresAt.Equal(testNow.Add(48*time.Hour)) {
+		t.Errorf("Expected the other writer's wider entry kept, got %v and %v", entry.Before, entry.ExpiresAt)
+	}
+}
+
+func TestSyncAcrossInstances(t *testing.T) {
+	server, client := newTestRedis(t)
+	a, _ := newTestList(client)
+	b, bNow := newTestList(client)
+	ctx := context.Background()
+
+	// Subscribe as a second instance would, to replay notifications
+	sub := client.Raw().Subscribe(ctx, Channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	a.RevokeToken(ctx, "tok-1", testNow.Add(time.Hour), "alice", "")
+	a.RevokeSubject(ctx, "u1", testNow, testNow.Add(2*time.Hour), "alice", "")
+	if b.Len() != 0 || !b.Synced().IsZero() {
+		t.Fatalf("Expected instance b empty and unsynced, got %d", b.Len())
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !b.Revoked(&jwt.Claims{Jti: "tok-1"}) || !b.Revoked(&jwt.Claims{Sub: "u1"}) {
+		t.Error("Expected instance b to see both revocations after sync")
+	}
+	if !b.Synced().Equal(testNow) {
+		t.Errorf("Expected sync time recorded, got %v", b.Synced())
+	}
+
+	// Notifications carry the entry, so they apply without a Redis read
+	if err := a.Remove(ctx, KindJTI, "tok-1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		msg, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			t.Fatalf("Expected notification: %v", err)
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+			t.Fatalf("Malformed notification %s: %v", msg.Payload, err)
+		}
+		b.Apply(e)
+	}
+	if b.Revoked(&jwt.Claims{Jti: "tok-1"}) || !b.Revoked(&jwt.Claims{Sub: "u1"}) {
+		t.Error("Expected only the removed revocation lifted")
+	}
+
+	// Expired entries are dropped from Redis and from the local copy
+	*bNow = testNow.Add(90 * time.Minute)
+	server.FastForward(90 * time.Minute)
+	b.Sync(ctx)
+	if entries := b.Entries(); len(entries) != 1 || entries[0].ID != "u1" {
+		t.Errorf("Expected only the subject revocation left, got %+v", entries)
+	}
+	*bNow = testNow.Add(3 * time.Hour)
+	server.FastForward(90 * time.Minute)
+	b.Sync(ctx)
+	if b.Len() != 0 || len(server.Keys()) != 0 {
+		t.Errorf("Expected all entries expired, got %d local and stored %v", b.Len(), server.Keys())
+	}
+}
+
+func TestSyncKeepsConcurrentChanges(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	loads := 0
+	hooked := &hookedRedis{Evaluator: client}
+	list, _ := newTestList(hooked)
+	other, _ := newTestList(client)
+	hooked.before = func(script string) {
+		// Another instance changes the jtis after they were loaded; its
+		// notifications arrive before the sync completes
+		if script == loadScript {
+			if loads++; loads == 2 {
+				e, _ := other.RevokeToken(ctx, "tok-3", testNow.Add(time.Hour), "bob", "")
+				list.Apply(*e)
+				other.Remove(ctx, KindJTI, "tok-1")
+				list.Apply(Entry{Kind: KindJTI, ID: "tok-1", Removed: true})
+			}
+		}
+	}
+	list.RevokeToken(ctx, "tok-1", testNow.Add(time.Hour), "alice", "")
+	list.RevokeToken(ctx, "tok-2", testNow.Add(time.Hour), "alice", "")
+
+	if err := list.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if list.Revoked(&jwt.Claims{Jti: "tok-1"}) || !list.Revoked(&jwt.Claims{Jti: "tok-2"}) || !list.Revoked(&jwt.Claims{Jti: "tok-3"}) {
+		t.Errorf("Expected changes during the sync kept, got %+v", list.Entries())
+	}
+}
+
+func TestRemoveAndErrors(t *testing.T) {
+	server, client := newTestRedis(t)
+	list, _ := newTestList(client)
+	ctx := context.Background()
+
+	if err := list.Remove(ctx, KindSubject, "nobody"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := list.Remove(ctx, "user", "u1"); err == nil {
+		t.Error("Expected error for unknown kind")
+	}
+
+	// A failed sync keeps the current copy
+	list.RevokeToken(ctx, "tok-1", testNow.Add(time.Hour), "alice", "")
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if err := list.Sync(ctx); err == nil {
+		t.Error("Expected sync error")
+	}
+	if !list.Synced().IsZero() {
+		t.Error("Expected no sync time after a failed sync")
+	}
+	if !list.Revoked(&jwt.Claims{Jti: "tok-1"}) {
+		t.Error("Expected revocations kept after a failed sync")
+	}
+	if _, err := list.RevokeToken(ctx, "tok-2", testNow.Add(time.Hour), "alice", ""); err == nil {
+		t.Error("Expected error storing a revocation without Redis")
+	}
+	if list.Revoked(&jwt.Claims{Jti: "tok-2"}) {
+		t.Error("Expected unstored revocation not applied locally")
+	}
+}
+
+func TestEntriesOrder(t *testing.T) {
+	_, client := newTestRedis(t)
+	list, _ := newTestList(client)
+	ctx := context.Background()
+	for i, d := range []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour} {
+		list.RevokeToken(ctx, "tok-"+strconv.Itoa(i), testNow.Add(d), "alice", "")
+	}
+	var ids []string
+	for _, e := range list.Entries() {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 3 || ids[0] != "tok-1" || ids[1] != "tok-2" || ids[2] != "tok-0" {
+		t.Errorf("Expected soonest expiry first, got %v", ids)
+	}
+}
@@ -0,0 +1,48 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Watch keeps the local copy current until ctx is done. Notifications are
+// applied as they arrive; a full sync every resync covers notifications
+// missed while Redis was unreachable.
+func (l *List) Watch(ctx context.Context, client *goredis.Client, resync time.Duration) {
+	pubsub := client.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+	notifications := pubsub.Channel()
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+
+	sync := func() {
+		if err := l.Sync(ctx); err != nil {
+			log.Printf("Revocation list sync failed: %v", err)
+		}
+	}
+
+	sync()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-notifications:
+			if !ok {
+				return
+			}
+			var e Entry
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Printf("Ignoring malformed revocation notification: %v", err)
+				continue
+			}
+			l.Apply(e)
+		case <-ticker.C:
+			sync()
+		}
+	}
+}
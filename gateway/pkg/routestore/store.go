@@ -0,0 +1,360 @@
+// Package routestore keeps the gateway route table in Redis so every
+// instance serves the same routes. Writes use optimistic concurrency on a
+// version number, append to an audit trail and notify instances over
+// Pub/Sub. Recent versions are kept for rollback.
+package routestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+)
+
+// Redis keys and channel
+const (
+	RoutesKey  = "routes:current" // JSON Snapshot
+	VersionKey = "routes:version" // Version of RoutesKey, for compare-and-set
+	AuditKey   = "routes:audit"   // JSON AuditEntry list, newest first
+	HistoryKey = "routes:history" // JSON Snapshot list, newest first
+	Channel    = "routes:changed" // Carries the new version
+)
+
+// Audit actions
+const (
+	ActionSeed     = "seed"
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+)
+
+var (
+	// ErrVersionConflict means the routes changed since the caller read them
+	ErrVersionConflict = errors.New("routes were modified concurrently")
+	// ErrRouteExists means a create used a path prefix that is already routed
+	ErrRouteExists = errors.New("route already exists")
+	// ErrRouteNotFound means an update or delete named an unknown path prefix
+	ErrRouteNotFound = errors.New("route not found")
+	// ErrVersionNotFound means a rollback named a version no longer kept
+	ErrVersionNotFound = errors.New("routes version not found in history")
+	// ErrRouteRequired means a create or update came without a route
+	ErrRouteRequired = errors.New("route is required")
+)
+
+// ValidationError lists the problems that rejected a change
+type ValidationError struct {
+	Diagnostics []config.Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid routes: %d error(s)", len(e.Diagnostics))
+}
+
+// Snapshot is one version of the route table
+type Snapshot struct {
+	Version   int64          `json:"version"`
+	Checksum  string         `json:"checksum"` // See config.Checksum
+	Routes    []config.Route `json:"routes"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UpdatedBy string         `json:"updated_by"`
+}
+
+// AuditEntry records who changed which route. Actor is the authenticated
+// identity; ClaimedActor is the name the caller gave and is not verified.
+type AuditEntry struct {
+	Version      int64         `json:"version"`
+	Time         time.Time     `json:"time"`
+	Actor        string        `json:"actor"`
+	ClaimedActor string        `json:"claimed_actor,omitempty"`
+	RemoteAddr   string        `json:"remote_addr,omitempty"`
+	Action       string        `json:"action"`
+	PathPrefix   string        `json:"path_prefix,omitempty"`
+	Before       *config.Route `json:"before,omitempty"`
+	After        *config.Route `json:"after,omitempty"`
+	RollbackTo   int64         `json:"rollback_to,omitempty"` // Version whose routes were restored
+}
+
+// Change is a single route edit requested through the admin API
+type Change struct {
+	Action     string        // create, update, delete or rollback
+	PathPrefix string        // Route to update or delete
+	Route      *config.Route // New route for create and update
+	Version    int64         // Version to restore on rollback
+	Actor      string        // Authenticated identity
+	// ClaimedActor is the operator name sent by the caller, unverified
+	ClaimedActor string
+	RemoteAddr   string
+}
+
+// Lua script for compare-and-set of the route table.
+// Returns {1, new_version} on success or {0, current_version} on conflict.
+const commitScript = `
+local current = tonumber(redis.call('GET', KEYS[2]) or '0')
+if current ~= tonumber(ARGV[1]) then
+    return {0, current}
+end
+
+redis.call('SET', KEYS[1], ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2])
+redis.call('LPUSH', KEYS[3], ARGV[4])
+redis.call('LTRIM', KEYS[3], 0, tonumber(ARGV[5]) - 1)
+redis.call('LPUSH', KEYS[4], ARGV[3])
+redis.call('LTRIM', KEYS[4], 0, tonumber(ARGV[7]) - 1)
+redis.call('PUBLISH', ARGV[6], ARGV[2])
+return {1, tonumber(ARGV[2])}
+`
+
+// Returns the stored snapshot, or "" if none was written yet
+const getScript = `return redis.call('GET', KEYS[1]) or ''`
+
+// Returns the newest ARGV[1] entries of a list
+const rangeScript = `return redis.call('LRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)`
+
+// Store reads and writes the shared route table
+type Store struct {
+	redis        redis.Evaluator
+	auditLimit   int
+	historyLimit int
+	now          func() time.Time
+}
+
+// NewStore creates a store keeping at most auditLimit audit entries and
+// historyLimit route table versions
+func NewStore(client redis.Evaluator, auditLimit, historyLimit int) *Store {
+	return &Store{redis: client, auditLimit: auditLimit, historyLimit: historyLimit, now: time.Now}
+}
+
+// Get returns the stored routes. Version 0 means nothing was stored yet.
+func (s *Store) Get(ctx context.Context) (*Snapshot, error) {
+	result, err := s.redis.Eval(ctx, getScript, []string{RoutesKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	data, _ := result.(string)
+	if data == "" {
+		return &Snapshot{}, nil
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(data), &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode stored routes: %w", err)
+	}
+	return &snap, nil
+}
+
+// Sync returns the stored routes, first storing seed if the store is empty.
+// Instances starting together race to seed; the losers read the winner's.
+// Seed routes are checked like any other change.
+func (s *Store) Sync(ctx context.Context, seed []config.Route) (*Snapshot, error) {
+	snap, err := s.Get(ctx)
+	if err != nil || snap.Version > 0 {
+		return snap, err
+	}
+
+	if diags := config.CheckRoutes(seed); config.HasErrors(diags) {
+		return nil, &ValidationError{Diagnostics: diags}
+	}
+
+	snap, err = s.commit(ctx, 0, seed, AuditEntry{Actor: "gateway", Action: ActionSeed})
+	if errors.Is(err, ErrVersionConflict) {
+		return s.Get(ctx)
+	}
+	return snap, err
+}
+
+// Apply validates and stores a change made against version expected.
+// Warnings (e.g. shadowed prefixes) are returned alongside the snapshot.
+func (s *Store) Apply(ctx context.Context, expected int64, change Change) (*Snapshot, []config.Diagnostic, error) {
+	current, err := s.Get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current.Version != expected {
+		return current, nil, ErrVersionConflict
+	}
+
+	var routes []config.Route
+	var entry AuditEntry
+	if change.Action == ActionRollback {
+		routes, entry, err = s.rollback(ctx, change)
+	} else {
+		routes, entry, err = applyChange(current.Routes, change)
+	}
+	if err != nil {
+		return current, nil, err
+	}
+
+	diags := config.CheckRoutes(routes)
+	if config.HasErrors(diags) {
+		return current, nil, &ValidationError{Diagnostics: diags}
+	}
+
+	snap, err := s.commit(ctx, expected, routes, entry)
+	return snap, diags, err
+}
+
+// Audit returns up to limit entries, newest first
+func (s *Store) Audit(ctx context.Context, limit int) ([]AuditEntry, error) {
+	items, err := s.list(ctx, AuditKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	entries := make([]AuditEntry, 0, len(items))
+	for _, data := range items {
+		var entry AuditEntry
+		if json.Unmarshal([]byte(data), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// History returns the kept route table versions, newest first
+func (s *Store) History(ctx context.Context) ([]Snapshot, error) {
+	items, err := s.list(ctx, HistoryKey, s.historyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes history: %w", err)
+	}
+
+	snaps := make([]Snapshot, 0, len(items))
+	for _, data := range items {
+		var snap Snapshot
+		if json.Unmarshal([]byte(data), &snap) == nil {
+			snaps = append(snaps, snap)
+		}
+	}
+	return snaps, nil
+}
+
+// rollback returns the routes of the version named in change
+func (s *Store) rollback(ctx context.Context, change Change) ([]config.Route, AuditEntry, error) {
+	entry := AuditEntry{Actor: change.Actor, ClaimedActor: change.ClaimedActor, RemoteAddr: change.RemoteAddr, Action: ActionRollback, RollbackTo: change.Version}
+	history, err := s.History(ctx)
+	if err != nil {
+		return nil, entry, err
+	}
+	for _, snap := range history {
+		if snap.Version == change.Version {
+			return snap.Routes, entry, nil
+		}
+	}
+	return nil, entry, ErrVersionNotFound
+}
+
+// list returns the newest limit string items of a Redis list
+func (s *Store) list(ctx context.Context, key string, limit int) ([]string, error) {
+	result, err := s.redis.Eval(ctx, rangeScript, []string{key}, limit)
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := result.([]interface{})
+	items := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if data, ok := item.(string); ok {
+			items = append(items, data)
+		}
+	}
+	return items, nil
+}
+
+// commit stores routes as version expected+1 if the stored version is
+// still expected, and records entry in the audit log
+func (s *Store) commit(ctx context.Context, expected int64, routes []config.Route, entry AuditEntry) (*Snapshot, error) {
+	if routes == nil {
+		routes = []config.Route{}
+	}
+	now := s.now().UTC()
+	snap := &Snapshot{
+		Version:   expected + 1,
+		Checksum:  config.Checksum(routes),
+		Routes:    routes,
+		UpdatedAt: now,
+		UpdatedBy: entry.Actor,
+	}
+	entry.Version = snap.Version
+	entry.Time = now
+
+	snapData, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	entryData, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.redis.Eval(ctx, commitScript,
+		[]string{RoutesKey, VersionKey, AuditKey, HistoryKey},
+		expected, snap.Version, string(snapData), string(entryData), s.auditLimit, Channel, s.historyLimit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store routes: %w", err)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected commit result: %v", result)
+	}
+	if ok, _ := values[0].(int64); ok != 1 {
+		return nil, ErrVersionConflict
+	}
+	return snap, nil
+}
+
+// applyChange returns the route list with change applied and its audit entry
+func applyChange(routes []config.Route, change Change) ([]config.Route, AuditEntry, error) {
+	entry := AuditEntry{Actor: change.Actor, ClaimedActor: change.ClaimedActor, RemoteAddr: change.RemoteAddr, Action: change.Action}
+	index := -1
+	prefix := change.PathPrefix
+	if change.Action == ActionCreate && change.Route != nil {
+		prefix = change.Route.PathPrefix
+	}
+	for i := range routes {
+		if routes[i].PathPrefix == prefix {
+			index = i
+			break
+		}
+	}
+	entry.PathPrefix = prefix
+
+	next := make([]config.Route, 0, len(routes)+1)
+	next = append(next, routes...)
+
+	switch change.Action {
+	case ActionCreate:
+		if change.Route == nil {
+			return nil, entry, ErrRouteRequired
+		}
+		if index >= 0 {
+			return nil, entry, ErrRouteExists
+		}
+		next = append(next, *change.Route)
+		entry.After = change.Route
+	case ActionUpdate:
+		if change.Route == nil {
+			return nil, entry, ErrRouteRequired
+		}
+		if index < 0 {
+			return nil, entry, ErrRouteNotFound
+		}
+		before := routes[index]
+		next[index] = *change.Route
+		entry.Before, entry.After = &before, change.Route
+	case ActionDelete:
+		if index < 0 {
+			return nil, entry, ErrRouteNotFound
+		}
+		before := routes[index]
+		next = append(next[:index], next[index+1:]...)
+		entry.Before = &before
+	default:
+		return nil, entry, fmt.Errorf("unknown action %q", change.Action)
+	}
+	return next, entry, nil
+}
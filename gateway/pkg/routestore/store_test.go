@@ -0,0 +1,224 @@
+package routestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	"github.com/distributed-api-gateway/gateway/pkg/redis"
+	"github.com/distributed-api-gateway/gateway/pkg/redis/redistest"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server, client := redistest.New(t)
+	store := NewStore(client, 10, 3)
+	store.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return store, server, client
+}
+
+var seedRoutes = []config.Route{
+	{PathPrefix: "/service-a", Target: "http://service-a:6000", Timeout: 5 * time.Second},
+}
+
+func TestStoreSyncSeedsEmptyStore(t *testing.T) {
+	store, _, client := newTestStore(t)
+	ctx := context.Background()
+	sub := client.Raw().Subscribe(ctx, Channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	snap, err := store.Sync(ctx, seedRoutes)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if snap.Version != 1 || len(snap.Routes) != 1 {
+		t.Errorf("Expected seeded version 1 with 1 route, got %+v", snap)
+	}
+	if msg, err := sub.ReceiveMessage(ctx); err != nil || msg.Payload != "1" {
+		t.Errorf("Expected seed to publish version 1, got %v (%v)", msg, err)
+	}
+
+	// Second instance reads the stored routes instead of seeding its own
+	snap, err = store.Sync(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if snap.Version != 1 || len(snap.Routes) != 1 {
+		t.Errorf("Expected stored routes, got %+v", snap)
+	}
+}
+
+func TestStoreApplyCreateUpdateDelete(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	ctx := context.Background()
+	store.Sync(ctx, seedRoutes)
+
+	route := config.Route{PathPrefix: "/service-b", Target: "http://service-b:6001", Timeout: time.Second}
+	snap, _, err := store.Apply(ctx, 1, Change{Action: ActionCreate, Route: &route, Actor: "alice"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if snap.Version != 2 || len(snap.Routes) != 2 || snap.UpdatedBy != "alice" {
+		t.Errorf("Unexpected snapshot after create: %+v", snap)
+	}
+
+	route.Target = "http://service-b-v2:6001"
+	snap, _, err = store.Apply(ctx, 2, Change{Action: ActionUpdate, PathPrefix: "/service-b", Route: &route, Actor: "bob"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if snap.Routes[1].Target != "http://service-b-v2:6001" {
+		t.Errorf("Expected updated target, got %s", snap.Routes[1].Target)
+	}
+
+	snap, _, err = store.Apply(ctx, 3, Change{Action: ActionDelete, PathPrefix: "/service-a", Actor: "carol"})
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(snap.Routes) != 1 || snap.Routes[0].PathPrefix != "/service-b" {
+		t.Errorf("Expected only /service-b after delete, got %+v", snap.Routes)
+	}
+
+	entries, err := store.Audit(ctx, 10)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 audit entries, got %d", len(entries))
+	}
+	if entries[0].Action != ActionDelete || entries[0].Actor != "carol" || entries[0].Before == nil {
+		t.Errorf("Expected newest entry to record carol's delete, got %+v", entries[0])
+	}
+	if entries[1].Before == nil || entries[1].After == nil || entries[1].Before.Target == entries[1].After.Target {
+		t.Errorf("Expected update entry with before and after, got %+v", entries[1])
+	}
+}
+
+func TestStoreApplyVersionConflict(t *testing.T) {
+	store, server, _ := newTestStore(t)
+	ctx := context.Background()
+	store.Sync(ctx, seedRoutes)
+
+	_, _, err := store.Apply(ctx, 0, Change{Action: ActionDelete, PathPrefix: "/service-a"})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected version conflict, got %v", err)
+	}
+	if version, _ := server.Get(VersionKey); version != "1" {
+		t.Errorf("Expected stored version unchanged, got %s", version)
+	}
+}
+
+func TestStoreApplyRejects(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	ctx := context.Background()
+	store.Sync(ctx, seedRoutes)
+
+	dup := seedRoutes[0]
+	if _, _, err := store.Apply(ctx, 1, Change{Action: ActionCreate, Route: &dup}); !errors.Is(err, ErrRouteExists) {
+		t.Errorf("Expected ErrRouteExists, got %v", err)
+	}
+	if _, _, err := store.Apply(ctx, 1, Change{Action: ActionDelete, PathPrefix: "/missing"}); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("Expected ErrRouteNotFound, got %v", err)
+	}
+
+	bad := config.Route{PathPrefix: "/bad", Target: "not a url"}
+	_, _, err := store.Apply(ctx, 1, Change{Action: ActionCreate, Route: &bad})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Diagnostics) == 0 {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+}
+
+func TestStoreAuditLimit(t *testing.T) {
+	store, server, _ := newTestStore(t)
+	store.auditLimit = 2
+	ctx := context.Background()
+	store.Sync(ctx, seedRoutes)
+
+	for v := int64(1); v <= 3; v++ {
+		route := seedRoutes[0]
+		route.Timeout = time.Duration(v) * time.Second
+		if _, _, err := store.Apply(ctx, v, Change{Action: ActionUpdate, PathPrefix: "/service-a", Route: &route}); err != nil {
+			t.Fatalf("Update %d failed: %v", v, err)
+		}
+	}
+	if audit, _ := server.List(AuditKey); len(audit) != 2 {
+		t.Errorf("Expected audit trimmed to 2 entries, got %d", len(audit))
+	}
+	if history, _ := server.List(HistoryKey); len(history) != 3 {
+		t.Errorf("Expected history trimmed to 3 versions, got %d", len(history))
+	}
+}
+
+func TestStoreRedisUnavailable(t *testing.T) {
+	store, server, _ := newTestStore(t)
+	server.SetError("LOADING Redis is loading the dataset in memory")
+
+	if _, err := store.Sync(context.Background(), seedRoutes); err == nil {
+		t.Error("Expected error when Redis is unavailable")
+	}
+}
+
+func TestStoreSyncRejectsInvalidSeed(t *testing.T) {
+	store, server, _ := newTestStore(t)
+
+	seed := []config.Route{{PathPrefix: "/bad", Target: "ftp://files:21"}}
+	_, err := store.Sync(context.Background(), seed)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+	if server.Exists(RoutesKey) {
+		t.Error("Expected invalid seed not stored")
+	}
+}
+
+func TestStoreRollback(t *testing.T) {
+	store, _, _ := newTestStore(t)
+	ctx := context.Background()
+	seeded, _ := store.Sync(ctx, seedRoutes)
+
+	broken := seedRoutes[0]
+	broken.Target = "http://service-a-broken:6000"
+	if _, _, err := store.Apply(ctx, 1, Change{Action: ActionUpdate, PathPrefix: "/service-a", Route: &broken}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	snap, _, err := store.Apply(ctx, 2, Change{Action: ActionRollback, Version: 1, Actor: "alice"})
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if snap.Version != 3 {
+		t.Errorf("Expected rollback to create version 3, got %d", snap.Version)
+	}
+	if snap.Checksum != seeded.Checksum {
+		t.Errorf("Expected checksum of version 1 (%s), got %s", seeded.Checksum, snap.Checksum)
+	}
+	if snap.Routes[0].Target != "http://service-a:6000" {
+		t.Errorf("Expected restored target, got %s", snap.Routes[0].Target)
+	}
+
+	history, err := store.History(ctx)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 3 || history[0].Version != 3 || history[2].Version != 1 {
+		t.Errorf("Expected versions 3,2,1 newest first, got %+v", history)
+	}
+
+	entries, _ := store.Audit(ctx, 1)
+	if len(entries) != 1 || entries[0].Action != ActionRollback || entries[0].RollbackTo != 1 {
+		t.Errorf("Expected rollback audit entry, got %+v", entries)
+	}
+
+	if _, _, err := store.Apply(ctx, 3, Change{Action: ActionRollback, Version: 42}); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package routestore
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Watch calls apply with each changed stored snapshot until ctx is done.
+// Change notifications trigger an immediate sync; polling every resync
+// covers notifications missed while Redis was unreachable. If the store is
+// empty it is seeded with seed.
+func (s *Store) Watch(ctx context.Context, client *goredis.Client, resync time.Duration, seed []config.Route, apply func(*Snapshot)) {
+	pubsub := client.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+	notifications := pubsub.Channel()
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+
+	var applied Snapshot
+	sync := func() {
+		snap, err := s.Sync(ctx, seed)
+		if err != nil {
+			log.Printf("Route store sync failed: %v", err)
+			return
+		}
+		if snap.Version == applied.Version && snap.Checksum == applied.Checksum {
+			return
+		}
+		// Versions only go back when the store was flushed and reseeded
+		if snap.Version < applied.Version {
+			log.Printf("Route store version went back from %d to %d; applying the stored routes", applied.Version, snap.Version)
+		}
+		applied = *snap
+		apply(snap)
+	}
+
+	sync()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notifications:
+			sync()
+		case <-ticker.C:
+			sync()
+		}
+	}
+}
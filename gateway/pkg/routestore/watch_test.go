@@ -0,0 +1,52 @@
+package routestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+func TestWatchFollowsReseededStore(t *testing.T) {
+	store, server, client := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan *Snapshot, 10)
+	// Only notifications trigger syncs, so the watcher never reseeds itself
+	go store.Watch(ctx, client.Raw(), time.Hour, seedRoutes, func(snap *Snapshot) { applied <- snap })
+	next := func() *Snapshot {
+		t.Helper()
+		select {
+		case snap := <-applied:
+			return snap
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected routes applied")
+			return nil
+		}
+	}
+
+	if snap := next(); snap.Version != 1 {
+		t.Fatalf("Expected seeded version 1, got %d", snap.Version)
+	}
+	route := seedRoutes[0]
+	route.Target = "http://service-a-v2:6000"
+	if _, _, err := store.Apply(ctx, 1, Change{Action: ActionUpdate, PathPrefix: "/service-a", Route: &route}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if snap := next(); snap.Version != 2 {
+		t.Fatalf("Expected version 2, got %d", snap.Version)
+	}
+
+	// Redis is flushed and reseeded with other routes at version 1
+	server.FlushAll()
+	other := []config.Route{{PathPrefix: "/service-b", Target: "http://service-b:6001", Timeout: time.Second}}
+	if _, err := store.Sync(ctx, other); err != nil {
+		t.Fatalf("Reseed failed: %v", err)
+	}
+	snap := next()
+	if snap.Version != 1 || snap.Routes[0].PathPrefix != "/service-b" {
+		t.Errorf("Expected reseeded routes applied, got %+v", snap)
+	}
+}
@@ -12,11 +12,15 @@ type Step string
 const (
 	StepReceived     Step = "RECEIVED"      // Request received by gateway
 	StepAuth         Step = "AUTH"          // JWT authentication
+	StepAuthz        Step = "AUTHZ"         // Scope and role authorization
 	StepRateLimit    Step = "RATE_LIMIT"    // Rate limiting check
 	StepCircuit      Step = "CIRCUIT"       // Circuit breaker check
 	StepForward      Step = "FORWARD"       // Forwarding to backend
 	StepResponse     Step = "RESPONSE"      // Response received from backend
 	StepComplete     Step = "COMPLETE"      // Request completed
+	StepShadow       Step = "SHADOW"        // Shadow response compared
+	StepFault        Step = "FAULT"         // Fault injected for resilience testing
+	StepCache        Step = "CACHE"         // Response cache lookup
 )
 
 // Status represents the outcome of a pipeline step.
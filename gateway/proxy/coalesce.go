@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// coalesceHeaders are always part of the coalescing key because they
+// change the upstream response (304 Not Modified, partial content) or,
+// for the identity headers Auth sets, who may see it. Anonymous requests
+// carry no identity headers and still share responses.
+var coalesceHeaders = []string{"If-None-Match", "If-Modified-Since", "Range", "X-User-ID", "X-Client-ID"}
+
+// Coalescer forwards requests, collapsing identical concurrent GETs of
+// routes with a coalesce block into one upstream request. The first request
+// streams the response to its client while recording it; identical
+// requests arriving meanwhile wait and are answered from the recording.
+type Coalescer struct {
+	forwarder *Forwarder
+	maxBody   int64
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an upstream request shared by identical requests
+type flight struct {
+	done    chan struct{}
+	waiters int // Guarded by Coalescer.mu
+
+	// Set before done is closed
+	err    error
+	shared bool // Response recorded in full
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewCoalescer shares responses up to maxBody bytes. Requests waiting on a
+// larger response are forwarded on their own.
+func NewCoalescer(forwarder *Forwarder, maxBody int64) *Coalescer {
+	return &Coalescer{forwarder: forwarder, maxBody: maxBody, flights: make(map[string]*flight)}
+}
+
+// CoalesceKey identifies identical requests: method, upstream URL, the
+// caller's identity and the values of the route's coalesce headers
+func CoalesceKey(route *config.Route, r *http.Request) string {
+	parts := []string{r.Method, buildTargetURL(route, r.URL.Path, r.URL.RawQuery)}
+	names := append(append([]string(nil), coalesceHeaders...), route.Coalesce.Headers...)
+	for _, name := range names {
+		parts = append(parts, strings.ToLower(name)+"="+strings.Join(r.Header.Values(name), ","))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Forward proxies r like Forwarder.Forward. shared reports whether the
+// response (or error) came from an identical request already in flight.
+func (c *Coalescer) Forward(w http.ResponseWriter, r *http.Request, route *config.Route) (shared bool, err error) {
+	if route.Coalesce == nil || r.Method != http.MethodGet {
+		return false, c.forwarder.Forward(w, r, route)
+	}
+
+	key := CoalesceKey(route, r)
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		f.waiters++
+		c.mu.Unlock()
+		return c.wait(w, r, route, f)
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+
+	// Detached so the waiters are still answered if this client goes away;
+	// the route timeout still applies
+	fw := &flightWriter{ResponseWriter: w, header: make(http.Header), limit: c.maxBody}
+	err = c.forwarder.Forward(fw, r.WithContext(context.WithoutCancel(r.Context())), route)
+	f.err = err
+	if err == nil && fw.status != 0 && !fw.truncated {
+		f.shared, f.status, f.header, f.body = true, fw.status, fw.header, fw.body.Bytes()
+	}
+	return false, err
+}
+
+// wait answers r from the flight it joined
+func (c *Coalescer) wait(w http.ResponseWriter, r *http.Request, route *config.Route, f *flight) (bool, error) {
+	select {
+	case <-f.done:
+	case <-r.Context().Done():
+		return false, classifyError(r.Context())
+	}
+	if f.err != nil {
+		return true, f.err
+	}
+	if !f.shared {
+		return false, c.forwarder.Forward(w, r, route)
+	}
+
+	copyHeaders(f.header, w.Header())
+	w.Header().Set("X-Request-ID", getOrCreateRequestID(r))
+	w.WriteHeader(f.status)
+	w.Write(f.body)
+	return true, nil
+}
+
+// flightWriter passes the upstream response to the first client while
+// recording it for the waiters
+type flightWriter struct {
+	http.ResponseWriter
+	header http.Header // Set by the forwarder only
+	limit  int64
+
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (f *flightWriter) Header() http.Header { return f.header }
+
+func (f *flightWriter) WriteHeader(code int) {
+	if f.status != 0 {
+		return
+	}
+	f.status = code
+	copyHeaders(f.header, f.ResponseWriter.Header())
+	f.ResponseWriter.WriteHeader(code)
+}
+
+func (f *flightWriter) Write(b []byte) (int, error) {
+	if f.status == 0 {
+		f.WriteHeader(http.StatusOK)
+	}
+	if !f.truncated {
+		if int64(f.body.Len()+len(b)) > f.limit {
+			f.truncated = true
+		} else {
+			f.body.Write(b)
+		}
+	}
+	n, err := f.ResponseWriter.Write(b)
+	if err != nil && !f.truncated {
+		return len(b), nil // Client gone; keep reading for the waiters
+	}
+	return n, err
+}
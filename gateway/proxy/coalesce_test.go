@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// waitForWaiters blocks until n requests joined the only flight in c
+func waitForWaiters(t *testing.T, c *Coalescer, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		joined := 0
+		for _, f := range c.flights {
+			joined = f.waiters
+		}
+		c.mu.Unlock()
+		if joined == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("Expected %d waiting requests", n)
+}
+
+// coalesceBackend answers once release is closed, counting upstream calls
+func coalesceBackend(body string, release chan struct{}, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("X-Backend", "1")
+		w.Write([]byte(body))
+	}))
+}
+
+func runCoalesced(c *Coalescer, route *config.Route, n int) ([]*httptest.ResponseRecorder, []bool, []error) {
+	recs := make([]*httptest.ResponseRecorder, n)
+	shared := make([]bool, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/service-a/items?page=1", nil)
+			req.Header.Set("X-Request-ID", "req-"+string(rune('a'+i)))
+			shared[i], errs[i] = c.Forward(recs[i], req, route)
+		}(i)
+	}
+	wg.Wait()
+	return recs, shared, errs
+}
+
+func TestCoalescerSharesResponse(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	backend := coalesceBackend(`{"items":[]}`, release, &calls)
+	defer backend.Close()
+
+	c := NewCoalescer(NewForwarder(), 1<<20)
+	route := &config.Route{PathPrefix: "/service-a", Target: backend.URL, Timeout: 5 * time.Second, Coalesce: &config.Coalesce{}}
+
+	go func() {
+		waitForWaiters(t, c, 4)
+		close(release)
+	}()
+	recs, shared, errs := runCoalesced(c, route, 5)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+	sharedCount := 0
+	for i, rec := range recs {
+		if errs[i] != nil {
+			t.Fatalf("Request %d failed: %v", i, errs[i])
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"items":[]}` || rec.Header().Get("X-Backend") != "1" {
+			t.Errorf("Request %d: expected backend response, got %d %q %v", i, rec.Code, rec.Body.String(), rec.Header())
+		}
+		if got, want := rec.Header().Get("X-Request-ID"), "req-"+string(rune('a'+i)); got != want {
+			t.Errorf("Request %d: expected X-Request-ID %q, got %q", i, want, got)
+		}
+		if shared[i] {
+			sharedCount++
+		}
+	}
+	if sharedCount != 4 {
+		t.Errorf("Expected 4 shared responses, got %d", sharedCount)
+	}
+	if len(c.flights) != 0 {
+		t.Error("Expected flight to be removed")
+	}
+}
+
+func TestCoalescerLargeResponse(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	backend := coalesceBackend(strings.Repeat("x", 100), release, &calls)
+	defer backend.Close()
+
+	c := NewCoalescer(NewForwarder(), 10)
+	route := &config.Route{PathPrefix: "/service-a", Target: backend.URL, Timeout: 5 * time.Second, Coalesce: &config.Coalesce{}}
+
+	go func() {
+		waitForWaiters(t, c, 2)
+		close(release)
+	}()
+	recs, shared, _ := runCoalesced(c, route, 3)
+
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected waiters to forward on their own, got %d upstream calls", n)
+	}
+	for i, rec := range recs {
+		if rec.Body.Len() != 100 || shared[i] {
+			t.Errorf("Request %d: expected full unshared body, got %d bytes (shared %v)", i, rec.Body.Len(), shared[i])
+		}
+	}
+}
+
+func TestCoalescerSharesError(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	backend := coalesceBackend("", release, &calls)
+	defer backend.Close()
+	defer close(release)
+
+	c := NewCoalescer(NewForwarder(), 1<<20)
+	route := &config.Route{PathPrefix: "/service-a", Target: backend.URL, Timeout: 200 * time.Millisecond, Coalesce: &config.Coalesce{}}
+
+	_, _, errs := runCoalesced(c, route, 3)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", n)
+	}
+	for i, err := range errs {
+		if pe, ok := err.(*ProxyError); !ok || pe.Code != http.StatusGatewayTimeout {
+			t.Errorf("Request %d: expected shared timeout, got %v", i, err)
+		}
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	route := &config.Route{PathPrefix: "/service-a", Target: "http://service-a:6000", StripPrefix: true,
+		Coalesce: &config.Coalesce{Headers: []string{"Accept-Language"}}}
+	key := func(method, url string, header map[string]string) string {
+		req := httptest.NewRequest(method, url, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		return CoalesceKey(route, req)
+	}
+
+	base := key(http.MethodGet, "/service-a/items?page=1", nil)
+	if key(http.MethodGet, "/service-a/items?page=1", map[string]string{"X-Other": "1"}) != base {
+		t.Error("Expected unlisted headers to be ignored")
+	}
+	for name, other := range map[string]string{
+		"query":  key(http.MethodGet, "/service-a/items?page=2", nil),
+		"method": key(http.MethodHead, "/service-a/items?page=1", nil),
+		"header": key(http.MethodGet, "/service-a/items?page=1", map[string]string{"Accept-Language": "de"}),
+		"etag":   key(http.MethodGet, "/service-a/items?page=1", map[string]string{"If-None-Match": `"v1"`}),
+		"range":  key(http.MethodGet, "/service-a/items?page=1", map[string]string{"Range": "bytes=0-9"}),
+		"user":   key(http.MethodGet, "/service-a/items?page=1", map[string]string{"X-User-ID": "alice"}),
+		"client": key(http.MethodGet, "/service-a/items?page=1", map[string]string{"X-Client-ID": "acme"}),
+	} {
+		if other == base {
+			t.Errorf("Expected %s to change the key", name)
+		}
+	}
+}
+
+func TestCoalescerKeepsUsersApart(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte("data of " + r.Header.Get("X-User-ID")))
+	}))
+	defer backend.Close()
+	route := &config.Route{PathPrefix: "/service-a", Target: backend.URL, Timeout: 2 * time.Second, Coalesce: &config.Coalesce{}}
+	c := NewCoalescer(NewForwarder(), 1<<20)
+
+	users := []string{"alice", "bob", "alice"}
+	recs := make([]*httptest.ResponseRecorder, len(users))
+	var wg sync.WaitGroup
+	start := func(i int) {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/service-a/me", nil)
+			req.Header.Set("X-User-ID", users[i]) // Set by Auth
+			c.Forward(recs[i], req, route)
+		}()
+	}
+	start(0)
+	start(1)
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	start(2)
+	deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		joined := 0
+		for _, f := range c.flights {
+			joined += f.waiters
+		}
+		c.mu.Unlock()
+		if joined == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected one upstream call per user, got %d", n)
+	}
+	for i, user := range users {
+		if body := recs[i].Body.String(); body != "data of "+user {
+			t.Errorf("Request %d by %s: got %q", i, user, body)
+		}
+	}
+}
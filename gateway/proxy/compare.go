@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// Comparison results, checked in this order
+const (
+	CompareMatch          = "match"
+	CompareStatusMismatch = "status_mismatch"
+	CompareHeaderMismatch = "header_mismatch"
+	CompareBodyMismatch   = "body_mismatch"
+	CompareSkipped        = "skipped" // Either side failed or a body exceeded the size limit
+)
+
+// maxDifferences caps the differences reported per comparison
+const maxDifferences = 10
+
+// CapturedResponse is a response recorded for comparison
+type CapturedResponse struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Truncated bool  // Body exceeded the size limit
+	Err       error // Request failed; nothing else is set
+}
+
+// Comparison is the outcome of comparing primary and shadow responses
+type Comparison struct {
+	Result      string
+	Differences []string // e.g. "status: 200 != 500", "body items[0].name"
+}
+
+// Compare diffs the shadow response against the primary one. JSON bodies
+// are compared structurally with rules.IgnorePaths removed from both;
+// other bodies must be byte-identical.
+func Compare(primary, shadow *CapturedResponse, rules *config.ShadowCompare) Comparison {
+	switch {
+	case primary.Err != nil:
+		return Comparison{Result: CompareSkipped, Differences: []string{"primary: " + primary.Err.Error()}}
+	case shadow.Err != nil:
+		return Comparison{Result: CompareSkipped, Differences: []string{"shadow: " + shadow.Err.Error()}}
+	case primary.Status != shadow.Status:
+		return Comparison{Result: CompareStatusMismatch, Differences: []string{
+			fmt.Sprintf("status: %d != %d", primary.Status, shadow.Status),
+		}}
+	}
+
+	var diffs []string
+	for _, name := range rules.Headers {
+		p, s := primary.Header.Values(name), shadow.Header.Values(name)
+		if !reflect.DeepEqual(p, s) {
+			diffs = append(diffs, fmt.Sprintf("header %s: %q != %q", http.CanonicalHeaderKey(name), p, s))
+		}
+	}
+	if len(diffs) > 0 {
+		return Comparison{Result: CompareHeaderMismatch, Differences: diffs}
+	}
+
+	if primary.Truncated || shadow.Truncated {
+		return Comparison{Result: CompareSkipped, Differences: []string{"body: too large to compare"}}
+	}
+	if isJSON(primary.Header) && isJSON(shadow.Header) {
+		diffs = diffJSON(primary.Body, shadow.Body, rules.IgnorePaths)
+	} else if !bytes.Equal(primary.Body, shadow.Body) {
+		diffs = []string{fmt.Sprintf("body: %d bytes != %d bytes", len(primary.Body), len(shadow.Body))}
+	}
+	if len(diffs) > 0 {
+		return Comparison{Result: CompareBodyMismatch, Differences: diffs}
+	}
+	return Comparison{Result: CompareMatch}
+}
+
+func isJSON(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// diffJSON returns the paths where two JSON documents differ
+func diffJSON(primary, shadow []byte, ignore []string) []string {
+	var p, s interface{}
+	if err := json.Unmarshal(primary, &p); err != nil {
+		return []string{"body: primary is not valid JSON"}
+	}
+	if err := json.Unmarshal(shadow, &s); err != nil {
+		return []string{"body: shadow is not valid JSON"}
+	}
+	for _, path := range ignore {
+		segments := parsePath(path)
+		p = removePath(p, segments)
+		s = removePath(s, segments)
+	}
+
+	var diffs []string
+	diffValues("", p, s, &diffs)
+	if len(diffs) > maxDifferences {
+		diffs = append(diffs[:maxDifferences], fmt.Sprintf("… %d more", len(diffs)-maxDifferences))
+	}
+	return diffs
+}
+
+func diffValues(path string, p, s interface{}, diffs *[]string) {
+	if len(*diffs) > maxDifferences {
+		return
+	}
+	label := path
+	if label == "" {
+		label = "(root)"
+	}
+
+	switch pv := p.(type) {
+	case map[string]interface{}:
+		sv, ok := s.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, "body "+label+": type differs")
+			return
+		}
+		keys := make([]string, 0, len(pv)+len(sv))
+		for k := range pv {
+			keys = append(keys, k)
+		}
+		for k := range sv {
+			if _, seen := pv[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			pc, inP := pv[k]
+			sc, inS := sv[k]
+			switch {
+			case !inS:
+				*diffs = append(*diffs, "body "+child+": missing in shadow")
+			case !inP:
+				*diffs = append(*diffs, "body "+child+": only in shadow")
+			default:
+				diffValues(child, pc, sc, diffs)
+			}
+		}
+	case []interface{}:
+		sv, ok := s.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, "body "+label+": type differs")
+			return
+		}
+		if len(pv) != len(sv) {
+			*diffs = append(*diffs, fmt.Sprintf("body %s: length %d != %d", label, len(pv), len(sv)))
+			return
+		}
+		for i := range pv {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), pv[i], sv[i], diffs)
+		}
+	default:
+		if !reflect.DeepEqual(p, s) {
+			*diffs = append(*diffs, "body "+label+": value differs")
+		}
+	}
+}
+
+// pathSegment is one step of an ignore path: a key, an index, or [*]
+type pathSegment struct {
+	key   string
+	index int // -1 for every element
+	isKey bool
+}
+
+var segmentPattern = regexp.MustCompile(`([^.\[\]]+)|\[(\*|\d+)\]`)
+
+// parsePath splits "items[*].id" into segments
+func parsePath(path string) []pathSegment {
+	var segments []pathSegment
+	for _, m := range segmentPattern.FindAllStringSubmatch(path, -1) {
+		switch {
+		case m[1] != "":
+			segments = append(segments, pathSegment{key: m[1], isKey: true})
+		case m[2] == "*":
+			segments = append(segments, pathSegment{index: -1})
+		default:
+			i, _ := strconv.Atoi(m[2])
+			segments = append(segments, pathSegment{index: i})
+		}
+	}
+	return segments
+}
+
+// removePath deletes the value at segments from doc (modifying it in place)
+func removePath(doc interface{}, segments []pathSegment) interface{} {
+	if len(segments) == 0 {
+		return nil
+	}
+	seg, rest := segments[0], segments[1:]
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if !seg.isKey {
+			return doc
+		}
+		if len(rest) == 0 {
+			delete(v, seg.key)
+		} else if child, ok := v[seg.key]; ok {
+			v[seg.key] = removePath(child, rest)
+		}
+	case []interface{}:
+		if seg.isKey {
+			return doc
+		}
+		for i := range v {
+			if seg.index != -1 && seg.index != i {
+				continue
+			}
+			if len(rest) == 0 {
+				v[i] = nil // Keep indexes of the remaining elements stable
+			} else {
+				v[i] = removePath(v[i], rest)
+			}
+		}
+	}
+	return doc
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+func jsonResponse(status int, body string) *CapturedResponse {
+	return &CapturedResponse{
+		Status: status,
+		Header: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:   []byte(body),
+	}
+}
+
+func TestCompare(t *testing.T) {
+	rules := &config.ShadowCompare{
+		Headers:     []string{"Cache-Control"},
+		IgnorePaths: []string{"meta.timestamp", "items[*].id"},
+	}
+
+	tests := []struct {
+		name    string
+		primary *CapturedResponse
+		shadow  *CapturedResponse
+		result  string
+		diffs   []string
+	}{
+		{
+			name:    "ignored paths",
+			primary: jsonResponse(200, `{"items":[{"id":1,"name":"a"}],"meta":{"timestamp":1,"count":1}}`),
+			shadow:  jsonResponse(200, `{"meta":{"count":1,"timestamp":2},"items":[{"id":9,"name":"a"}]}`),
+			result:  CompareMatch,
+		},
+		{
+			name:    "status",
+			primary: jsonResponse(200, `{}`),
+			shadow:  jsonResponse(500, `{}`),
+			result:  CompareStatusMismatch,
+			diffs:   []string{"status: 200 != 500"},
+		},
+		{
+			name:    "body paths",
+			primary: jsonResponse(200, `{"items":[{"name":"a"}],"total":1}`),
+			shadow:  jsonResponse(200, `{"items":[{"name":"b"}],"next":null}`),
+			result:  CompareBodyMismatch,
+			diffs:   []string{"body items[0].name: value differs", "body next: only in shadow", "body total: missing in shadow"},
+		},
+		{
+			name:    "array length",
+			primary: jsonResponse(200, `[1,2]`),
+			shadow:  jsonResponse(200, `[1]`),
+			result:  CompareBodyMismatch,
+			diffs:   []string{"body (root): length 2 != 1"},
+		},
+		{
+			name:    "non-JSON bodies",
+			primary: &CapturedResponse{Status: 200, Header: http.Header{}, Body: []byte("hello")},
+			shadow:  &CapturedResponse{Status: 200, Header: http.Header{}, Body: []byte("hello!")},
+			result:  CompareBodyMismatch,
+			diffs:   []string{"body: 5 bytes != 6 bytes"},
+		},
+		{
+			name:    "shadow error",
+			primary: jsonResponse(200, `{}`),
+			shadow:  &CapturedResponse{Err: errors.New("timeout")},
+			result:  CompareSkipped,
+			diffs:   []string{"shadow: timeout"},
+		},
+		{
+			name:    "truncated",
+			primary: &CapturedResponse{Status: 200, Header: http.Header{}, Truncated: true},
+			shadow:  &CapturedResponse{Status: 200, Header: http.Header{}},
+			result:  CompareSkipped,
+			diffs:   []string{"body: too large to compare"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Compare(tc.primary, tc.shadow, rules)
+			if c.Result != tc.result {
+				t.Errorf("Expected %s, got %s (%v)", tc.result, c.Result, c.Differences)
+			}
+			if len(c.Differences) != len(tc.diffs) {
+				t.Fatalf("Expected differences %v, got %v", tc.diffs, c.Differences)
+			}
+			for i := range tc.diffs {
+				if c.Differences[i] != tc.diffs[i] {
+					t.Errorf("Expected %q, got %q", tc.diffs[i], c.Differences[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompareHeaders(t *testing.T) {
+	primary := jsonResponse(200, `{}`)
+	shadow := jsonResponse(200, `{}`)
+	primary.Header.Set("Cache-Control", "max-age=60")
+	shadow.Header.Set("Cache-Control", "no-store")
+
+	c := Compare(primary, shadow, &config.ShadowCompare{Headers: []string{"cache-control"}})
+	if c.Result != CompareHeaderMismatch {
+		t.Errorf("Expected header mismatch, got %+v", c)
+	}
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// Injected fault kinds
+const (
+	FaultDelay = "delay"
+	FaultAbort = "abort"
+	FaultReset = "reset"
+)
+
+// ErrFaultReset asks the caller to drop the client connection
+var ErrFaultReset = errors.New("connection reset (fault injected)")
+
+// FaultPlan is the faults chosen for one request
+type FaultPlan struct {
+	Delay time.Duration
+	Abort int // Status to answer with; 0 for none
+	Reset bool
+}
+
+// Kinds lists the planned faults, e.g. [delay abort]
+func (p FaultPlan) Kinds() []string {
+	var kinds []string
+	if p.Delay > 0 {
+		kinds = append(kinds, FaultDelay)
+	}
+	if p.Abort != 0 {
+		kinds = append(kinds, FaultAbort)
+	}
+	if p.Reset {
+		kinds = append(kinds, FaultReset)
+	}
+	return kinds
+}
+
+// ChooseFaults samples the route's faults for r. roll returns a value in
+// [0, 1) and is called once per configured fault.
+func ChooseFaults(route *config.Route, r *http.Request, roll func() float64) FaultPlan {
+	fault := route.Fault
+	if fault == nil || !faultApplies(fault, r) {
+		return FaultPlan{}
+	}
+
+	var plan FaultPlan
+	if d := fault.Delay; d != nil && roll()*100 < d.Percent {
+		plan.Delay = d.Duration
+	}
+	if a := fault.Abort; a != nil && roll()*100 < a.Percent {
+		plan.Abort = a.Status
+	}
+	if rs := fault.Reset; rs != nil && roll()*100 < rs.Percent {
+		plan.Reset = true
+	}
+	return plan
+}
+
+// faultApplies: no header or client IDs configured means every request
+func faultApplies(fault *config.Fault, r *http.Request) bool {
+	if fault.Header == "" && len(fault.ClientIDs) == 0 {
+		return true
+	}
+	if fault.Header != "" && r.Header.Get(fault.Header) != "" {
+		return true
+	}
+	if id := r.Header.Get("X-Client-ID"); id != "" {
+		for _, c := range fault.ClientIDs {
+			if c == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InjectFaults waits out the planned delay, then resets or aborts. The
+// delay counts against the route timeout: a delay reaching it fails like a
+// slow upstream with 504, and otherwise the returned route carries the time
+// left. A reset returns ErrFaultReset, an abort a ProxyError with the
+// planned status.
+func InjectFaults(ctx context.Context, plan FaultPlan, route *config.Route) (*config.Route, error) {
+	if plan.Delay > 0 {
+		timeout := route.Timeout
+		if timeout <= 0 {
+			timeout = config.DefaultRouteTimeout
+		}
+
+		timer := time.NewTimer(min(plan.Delay, timeout))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, classifyError(ctx)
+		}
+		if plan.Delay >= timeout {
+			return nil, &ProxyError{Code: http.StatusGatewayTimeout, Message: "backend timeout", Fault: FaultDelay}
+		}
+
+		delayed := *route
+		delayed.Timeout = timeout - plan.Delay
+		route = &delayed
+	}
+
+	if plan.Reset {
+		return nil, ErrFaultReset
+	}
+	if plan.Abort != 0 {
+		return nil, &ProxyError{Code: plan.Abort, Message: "fault injected", Fault: FaultAbort}
+	}
+	return route, nil
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+func faultRoute(fault *config.Fault) *config.Route {
+	return &config.Route{PathPrefix: "/service-a", Target: "http://service-a:6000", Timeout: time.Second, Fault: fault}
+}
+
+func TestChooseFaultsActivation(t *testing.T) {
+	route := faultRoute(&config.Fault{
+		Header:    "X-Fault-Inject",
+		ClientIDs: []string{"chaos"},
+		Abort:     &config.FaultAbort{Status: 503, Percent: 100},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/service-a/hello", nil)
+	if plan := ChooseFaults(route, req, fixedRoll(0)); len(plan.Kinds()) != 0 {
+		t.Errorf("Expected no faults without header or client ID, got %+v", plan)
+	}
+
+	req.Header.Set("X-Fault-Inject", "1")
+	if plan := ChooseFaults(route, req, fixedRoll(0)); plan.Abort != 503 {
+		t.Errorf("Expected header to activate abort, got %+v", plan)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/service-a/hello", nil)
+	req.Header.Set("X-Client-ID", "chaos")
+	if plan := ChooseFaults(route, req, fixedRoll(0)); plan.Abort != 503 {
+		t.Errorf("Expected client ID to activate abort, got %+v", plan)
+	}
+
+	// Without header or client IDs every request is eligible
+	route.Fault.Header, route.Fault.ClientIDs = "", nil
+	req = httptest.NewRequest(http.MethodGet, "/service-a/hello", nil)
+	if plan := ChooseFaults(route, req, fixedRoll(0)); plan.Abort != 503 {
+		t.Errorf("Expected abort for every request, got %+v", plan)
+	}
+}
+
+func TestChooseFaultsSampling(t *testing.T) {
+	route := faultRoute(&config.Fault{
+		Delay: &config.FaultDelay{Duration: time.Second, Percent: 50},
+		Reset: &config.FaultReset{Percent: 10},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/service-a/hello", nil)
+
+	plan := ChooseFaults(route, req, fixedRoll(0.3))
+	if plan.Delay != time.Second || plan.Reset {
+		t.Errorf("Expected delay only at 30, got %+v", plan)
+	}
+	if got := plan.Kinds(); len(got) != 1 || got[0] != FaultDelay {
+		t.Errorf("Expected [delay], got %v", got)
+	}
+	if plan := ChooseFaults(route, req, fixedRoll(0.05)); plan.Delay == 0 || !plan.Reset {
+		t.Errorf("Expected delay and reset at 5, got %+v", plan)
+	}
+	if plan := ChooseFaults(route, req, fixedRoll(0.5)); len(plan.Kinds()) != 0 {
+		t.Errorf("Expected no faults at 50, got %+v", plan)
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	route := faultRoute(nil)
+
+	// Delay within the timeout leaves the rest for the upstream
+	got, err := InjectFaults(context.Background(), FaultPlan{Delay: 50 * time.Millisecond}, route)
+	if err != nil || got.Timeout != 950*time.Millisecond {
+		t.Errorf("Expected 950ms left for the upstream, got %v (%v)", got, err)
+	}
+	if route.Timeout != time.Second {
+		t.Error("Expected the route itself to be unchanged")
+	}
+
+	// Delay beyond the timeout fails once the timeout passes
+	route.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err = InjectFaults(context.Background(), FaultPlan{Delay: time.Hour}, route)
+	var proxyErr *ProxyError
+	if !errors.As(err, &proxyErr) || proxyErr.Code != http.StatusGatewayTimeout || proxyErr.Fault != FaultDelay {
+		t.Errorf("Expected injected 504, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected delay to be capped at the route timeout")
+	}
+
+	_, err = InjectFaults(context.Background(), FaultPlan{Abort: 503}, route)
+	if !errors.As(err, &proxyErr) || proxyErr.Code != 503 || proxyErr.Fault != FaultAbort {
+		t.Errorf("Expected injected 503, got %v", err)
+	}
+
+	_, err = InjectFaults(context.Background(), FaultPlan{Abort: 503, Reset: true}, route)
+	if err != ErrFaultReset {
+		t.Errorf("Expected reset to take precedence over abort, got %v", err)
+	}
+
+	if got, err := InjectFaults(context.Background(), FaultPlan{}, route); err != nil || got != route {
+		t.Errorf("Expected no-op without faults, got %v (%v)", got, err)
+	}
+}
@@ -16,12 +16,19 @@ type Forwarder struct {
 	client *http.Client
 }
 
+// NewForwarder creates a forwarder with the default connect timeout
 func NewForwarder() *Forwarder {
+	return NewForwarderWithConnectTimeout(config.DefaultConnectTimeout * time.Second)
+}
+
+// NewForwarderWithConnectTimeout creates a forwarder whose upstream dials
+// fail after connectTimeout
+func NewForwarderWithConnectTimeout(connectTimeout time.Duration) *Forwarder {
 	return &Forwarder{
 		client: &http.Client{
 			Transport: &http.Transport{
 				DialContext: (&net.Dialer{
-					Timeout: config.DefaultConnectTimeout * time.Second,
+					Timeout: connectTimeout,
 				}).DialContext,
 			},
 		},
@@ -33,7 +40,11 @@ func (f *Forwarder) Forward(w http.ResponseWriter, r *http.Request, route *confi
 	targetURL := buildTargetURL(route, r.URL.Path, r.URL.RawQuery)
 	requestID := getOrCreateRequestID(r)
 
-	ctx, cancel := context.WithTimeout(r.Context(), route.Timeout)
+	timeout := route.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultRouteTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
 	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL, r.Body)
@@ -63,10 +74,16 @@ func (f *Forwarder) Forward(w http.ResponseWriter, r *http.Request, route *confi
 type ProxyError struct {
 	Code    int
 	Message string
+	Fault   string // Set when the error was injected (FaultDelay, FaultAbort)
 }
 
 func (e *ProxyError) Error() string { return e.Message }
 
+// TargetURL returns the upstream URL a request would be forwarded to
+func TargetURL(route *config.Route, path, query string) string {
+	return buildTargetURL(route, path, query)
+}
+
 // buildTargetURL: "/service-a/users" + strip → "http://service-a:6000/users"
 func buildTargetURL(route *config.Route, path, query string) string {
 	targetPath := path
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// ShadowHeader marks mirrored requests so shadow upstreams can tell them
+// apart (e.g. to skip side effects)
+const ShadowHeader = "X-Shadow-Request"
+
+// Mirror results reported to MirrorObserver.Mirrored
+const (
+	MirrorDropped  = "dropped"   // In-flight limit reached
+	MirrorTooLarge = "too_large" // Request body above the size limit
+	MirrorError    = "error"     // Shadow upstream failed or timed out
+)
+
+// MirrorObserver receives the outcome of mirrored requests
+type MirrorObserver interface {
+	// Mirrored reports a status class ("2xx"…"5xx") or a Mirror* result
+	Mirrored(service, result string)
+	// Compared reports the comparison for routes with shadow.compare.
+	// ctx carries the trace of the original request.
+	Compared(ctx context.Context, service string, c Comparison)
+}
+
+// Mirror sends copies of sampled requests to a route's shadow target in the
+// background. Responses never affect the client response or the circuit
+// breaker.
+type Mirror struct {
+	client   *http.Client
+	sem      chan struct{}
+	maxBody  int64
+	observer MirrorObserver
+	roll     func() float64
+}
+
+// NewMirror allows maxInFlight concurrent mirrored requests. Request bodies
+// up to maxBody bytes are mirrored, and response bodies up to maxBody bytes
+// are compared. observer may be nil.
+func NewMirror(maxInFlight int, maxBody int64, observer MirrorObserver) *Mirror {
+	if observer == nil {
+		observer = nopObserver{}
+	}
+	return &Mirror{
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		sem:      make(chan struct{}, maxInFlight),
+		maxBody:  maxBody,
+		observer: observer,
+		roll:     rand.Float64,
+	}
+}
+
+// Exchange links a mirrored request to its primary so the responses can be
+// compared. A nil Exchange (no mirror or no comparison) is valid.
+type Exchange struct {
+	capture *captureWriter
+	primary chan *CapturedResponse
+}
+
+// Capture wraps w to record the primary response
+func (e *Exchange) Capture(w http.ResponseWriter) http.ResponseWriter {
+	if e == nil {
+		return w
+	}
+	e.capture.ResponseWriter = w
+	return e.capture
+}
+
+// Done hands the primary response to the comparison. err is the error
+// returned by Forward, if any.
+func (e *Exchange) Done(err error) {
+	if e == nil {
+		return
+	}
+	resp := &CapturedResponse{Err: err}
+	if err == nil {
+		c := e.capture
+		resp.Status, resp.Header, resp.Body, resp.Truncated = c.status, c.header, c.body.Bytes(), c.truncated
+	}
+	e.primary <- resp
+}
+
+// Send mirrors r to the route's shadow target if it is sampled. It must be
+// called before r is forwarded: the body is buffered and r.Body replaced so
+// the primary request still reads it in full. The returned Exchange is
+// non-nil only when the route compares responses.
+func (m *Mirror) Send(r *http.Request, route *config.Route) *Exchange {
+	shadow := route.Shadow
+	if shadow == nil || m.roll()*100 >= shadow.Percent {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > m.maxBody {
+			m.observer.Mirrored(route.PathPrefix, MirrorTooLarge)
+			return nil
+		}
+		buf, err := io.ReadAll(io.LimitReader(r.Body, m.maxBody+1))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		if err != nil || int64(len(buf)) > m.maxBody {
+			m.observer.Mirrored(route.PathPrefix, MirrorTooLarge)
+			return nil
+		}
+		body = buf
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		m.observer.Mirrored(route.PathPrefix, MirrorDropped)
+		return nil
+	}
+
+	target := *route
+	target.Target = shadow.Target
+	url := buildTargetURL(&target, r.URL.Path, r.URL.RawQuery)
+	header := r.Header.Clone()
+	if !shadow.ForwardCredentials {
+		header.Del("Authorization")
+		header.Del("Cookie")
+	}
+	header.Set("X-Forwarded-For", getClientIP(r))
+	header.Set(ShadowHeader, "true")
+
+	var exchange *Exchange
+	if shadow.Compare != nil {
+		exchange = &Exchange{
+			capture: &captureWriter{limit: m.maxBody},
+			primary: make(chan *CapturedResponse, 1),
+		}
+	}
+	// Outlives the client request but keeps its trace
+	ctx := context.WithoutCancel(r.Context())
+
+	go func() {
+		defer func() { <-m.sem }()
+		resp := m.do(r.Method, url, header, body, shadow.Timeout, exchange != nil)
+		result := MirrorError
+		if resp.Err == nil {
+			result = strconv.Itoa(resp.Status/100) + "xx"
+		}
+		m.observer.Mirrored(route.PathPrefix, result)
+
+		if exchange == nil {
+			return
+		}
+		select {
+		case primary := <-exchange.primary:
+			m.observer.Compared(ctx, route.PathPrefix, Compare(primary, resp, shadow.Compare))
+		case <-time.After(route.Timeout + shadow.Timeout):
+			// Primary never finished (e.g. a panic); nothing to compare
+		}
+	}()
+	return exchange
+}
+
+// do sends one mirrored request. The response body is kept only for
+// comparison.
+func (m *Mirror) do(method, url string, header http.Header, body []byte, timeout time.Duration, keepBody bool) *CapturedResponse {
+	// Detached from the client request, which may finish first
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return &CapturedResponse{Err: err}
+	}
+	req.Header = header
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return &CapturedResponse{Err: err}
+	}
+	defer resp.Body.Close()
+
+	captured := &CapturedResponse{Status: resp.StatusCode, Header: resp.Header}
+	if keepBody {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, m.maxBody+1))
+		if err != nil {
+			return &CapturedResponse{Err: err}
+		}
+		captured.Truncated = int64(len(data)) > m.maxBody
+		captured.Body = data
+	}
+	io.Copy(io.Discard, resp.Body)
+	return captured
+}
+
+// captureWriter passes the primary response through while recording it
+type captureWriter struct {
+	http.ResponseWriter
+	status    int
+	header    http.Header
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	room := c.limit - int64(c.body.Len())
+	if int64(len(b)) > room {
+		c.truncated = true
+	}
+	if room > 0 {
+		c.body.Write(b[:min(int64(len(b)), room)])
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// readCloser reads from r and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type nopObserver struct{}
+
+func (nopObserver) Mirrored(string, string)                      {}
+func (nopObserver) Compared(context.Context, string, Comparison) {}
@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+type mirrorRecorder struct {
+	mu          sync.Mutex
+	results     []string
+	done        chan struct{}
+	comparisons chan Comparison
+}
+
+func newMirrorRecorder() *mirrorRecorder {
+	return &mirrorRecorder{done: make(chan struct{}, 10), comparisons: make(chan Comparison, 10)}
+}
+
+func (m *mirrorRecorder) Mirrored(service, result string) {
+	m.mu.Lock()
+	m.results = append(m.results, service+" "+result)
+	m.mu.Unlock()
+	m.done <- struct{}{}
+}
+
+func (m *mirrorRecorder) Compared(ctx context.Context, service string, c Comparison) {
+	m.comparisons <- c
+}
+
+func (m *mirrorRecorder) wait(t *testing.T) string {
+	t.Helper()
+	select {
+	case <-m.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected mirror result to be recorded")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.results[len(m.results)-1]
+}
+
+func shadowRoute(target string) *config.Route {
+	return &config.Route{
+		PathPrefix:  "/api",
+		Target:      "http://primary.invalid",
+		StripPrefix: true,
+		Shadow:      &config.Shadow{Target: target, Percent: 50, Timeout: time.Second},
+	}
+}
+
+func TestMirrorSendsCopy(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer shadow.Close()
+
+	rec := newMirrorRecorder()
+	mirror := NewMirror(10, 1024, rec)
+	mirror.roll = func() float64 { return 0.1 } // Sampled at 50%
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders?dry=1", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-User-ID", "user1")
+	mirror.Send(req, shadowRoute(shadow.URL))
+
+	// The primary request still sees the full body
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"id":1}` {
+		t.Errorf("Expected primary body intact, got %q", body)
+	}
+
+	if got := rec.wait(t); got != "/api 2xx" {
+		t.Errorf("Expected 2xx result, got %q", got)
+	}
+	r := <-received
+	if r.URL.Path != "/orders" || r.URL.RawQuery != "dry=1" {
+		t.Errorf("Expected stripped path with query, got %s?%s", r.URL.Path, r.URL.RawQuery)
+	}
+	if r.Header.Get(ShadowHeader) != "true" {
+		t.Error("Expected mirrored request to be tagged")
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		t.Error("Expected Authorization and Cookie to be removed")
+	}
+	if r.Header.Get("X-User-ID") != "user1" {
+		t.Error("Expected claim headers to be mirrored")
+	}
+	if body := <-bodies; body != `{"id":1}` {
+		t.Errorf("Expected mirrored body, got %q", body)
+	}
+}
+
+func TestMirrorForwardCredentials(t *testing.T) {
+	received := make(chan http.Header, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer shadow.Close()
+
+	rec := newMirrorRecorder()
+	mirror := NewMirror(10, 1024, rec)
+	mirror.roll = func() float64 { return 0.1 }
+
+	route := shadowRoute(shadow.URL)
+	route.Shadow.ForwardCredentials = true
+	req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=abc")
+	mirror.Send(req, route)
+
+	rec.wait(t)
+	h := <-received
+	if h.Get("Authorization") != "Bearer secret" || h.Get("Cookie") != "session=abc" {
+		t.Errorf("Expected credentials mirrored when opted in, got %q and %q", h.Get("Authorization"), h.Get("Cookie"))
+	}
+}
+
+func TestMirrorSampling(t *testing.T) {
+	rec := newMirrorRecorder()
+	mirror := NewMirror(10, 1024, rec)
+	mirror.roll = func() float64 { return 0.5 } // 50 is not below 50%
+
+	req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	mirror.Send(req, shadowRoute("http://127.0.0.1:1"))
+
+	select {
+	case <-rec.done:
+		t.Error("Expected request outside the sample not to be mirrored")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMirrorBodyLimit(t *testing.T) {
+	rec := newMirrorRecorder()
+	mirror := NewMirror(10, 4, rec)
+	mirror.roll = func() float64 { return 0 }
+
+	body := strings.Repeat("x", 10)
+	req := httptest.NewRequest(http.MethodPost, "/api/items", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1 // Unknown length: detected while buffering
+	mirror.Send(req, shadowRoute("http://127.0.0.1:1"))
+
+	if got := rec.wait(t); got != "/api "+MirrorTooLarge {
+		t.Errorf("Expected too_large result, got %q", got)
+	}
+	if got, _ := io.ReadAll(req.Body); string(got) != body {
+		t.Errorf("Expected primary body intact, got %q", got)
+	}
+}
+
+func TestMirrorTimeoutAndDrop(t *testing.T) {
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	rec := newMirrorRecorder()
+	mirror := NewMirror(1, 1024, rec)
+	mirror.roll = func() float64 { return 0 }
+	route := shadowRoute(shadow.URL)
+	route.Shadow.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	mirror.Send(httptest.NewRequest(http.MethodGet, "/api/slow", nil), route)
+	mirror.Send(httptest.NewRequest(http.MethodGet, "/api/slow", nil), route)
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("Expected Send not to wait for the shadow upstream")
+	}
+
+	if got := rec.wait(t); got != "/api "+MirrorDropped {
+		t.Errorf("Expected second mirror dropped at the in-flight limit, got %q", got)
+	}
+	if got := rec.wait(t); got != "/api "+MirrorError {
+		t.Errorf("Expected slow shadow to time out, got %q", got)
+	}
+}
+
+func TestMirrorComparesResponses(t *testing.T) {
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"b-2","name":"widget","price":5}`))
+	}))
+	defer shadow.Close()
+
+	rec := newMirrorRecorder()
+	mirror := NewMirror(10, 1024, rec)
+	mirror.roll = func() float64 { return 0 }
+	route := shadowRoute(shadow.URL)
+	route.Timeout = time.Second
+	route.Shadow.Compare = &config.ShadowCompare{IgnorePaths: []string{"id"}}
+
+	exchange := mirror.Send(httptest.NewRequest(http.MethodGet, "/api/items/1", nil), route)
+	if exchange == nil {
+		t.Fatal("Expected an exchange for a comparing route")
+	}
+
+	// Primary response written through the capture
+	w := httptest.NewRecorder()
+	cw := exchange.Capture(w)
+	cw.Header().Set("Content-Type", "application/json")
+	cw.WriteHeader(http.StatusOK)
+	cw.Write([]byte(`{"id":"a-1","name":"widget","price":4}`))
+	exchange.Done(nil)
+
+	if w.Body.String() != `{"id":"a-1","name":"widget","price":4}` {
+		t.Errorf("Expected primary response passed through, got %q", w.Body.String())
+	}
+
+	select {
+	case c := <-rec.comparisons:
+		if c.Result != CompareBodyMismatch || len(c.Differences) != 1 || c.Differences[0] != "body price: value differs" {
+			t.Errorf("Expected only price to differ, got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected comparison to be reported")
+	}
+}
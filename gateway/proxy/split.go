@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// DefaultSplitCookie names the sticky cookie when a split sets none
+const DefaultSplitCookie = "gateway_split"
+
+// SplitChoice is the upstream group picked for a request
+type SplitChoice struct {
+	Group  string
+	Target string
+	Cookie *http.Cookie // Set on the response to pin the client (sticky: cookie)
+}
+
+// ChooseSplit picks a group of the route's traffic split. Precedence: the
+// override header, then the sticky assignment, then a weighted random pick.
+// roll returns a value in [0, 1).
+func ChooseSplit(route *config.Route, r *http.Request, roll func() float64) SplitChoice {
+	split := route.Split
+
+	if split.OverrideHeader != "" {
+		if value := r.Header.Get(split.OverrideHeader); value != "" {
+			for _, g := range split.Groups {
+				if g.Override != "" && strings.EqualFold(g.Override, value) {
+					return choice(route, g)
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, g := range split.Groups {
+		total += max(g.Weight, 0)
+	}
+	if total == 0 {
+		return SplitChoice{Target: route.Target}
+	}
+
+	switch split.Sticky {
+	case config.StickyClientID:
+		// Same client, same group, on every instance
+		if id := r.Header.Get("X-Client-ID"); id != "" {
+			h := fnv.New32a()
+			h.Write([]byte(route.PathPrefix + ":" + id))
+			return choice(route, pick(split.Groups, int(h.Sum32()%uint32(total))))
+		}
+	case config.StickyCookie:
+		name := split.Cookie
+		if name == "" {
+			name = DefaultSplitCookie
+		}
+		// A group drained to weight 0 no longer keeps its sticky clients
+		if c, err := r.Cookie(name); err == nil {
+			for _, g := range split.Groups {
+				if g.Name == c.Value && g.Weight > 0 {
+					return choice(route, g)
+				}
+			}
+		}
+		sc := choice(route, pick(split.Groups, int(roll()*float64(total))))
+		sc.Cookie = &http.Cookie{
+			Name:     name,
+			Value:    sc.Group,
+			Path:     route.PathPrefix,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+		return sc
+	}
+
+	return choice(route, pick(split.Groups, int(roll()*float64(total))))
+}
+
+// pick returns the group whose cumulative weight range contains point
+func pick(groups []config.UpstreamGroup, point int) config.UpstreamGroup {
+	for _, g := range groups {
+		if g.Weight <= 0 {
+			continue
+		}
+		if point < g.Weight {
+			return g
+		}
+		point -= g.Weight
+	}
+	return groups[len(groups)-1]
+}
+
+func choice(route *config.Route, g config.UpstreamGroup) SplitChoice {
+	target := g.Target
+	if target == "" {
+		target = route.Target
+	}
+	return SplitChoice{Group: g.Name, Target: target}
+}
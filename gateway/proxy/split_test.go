@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+func canaryRoute(sticky string) *config.Route {
+	return &config.Route{
+		PathPrefix: "/service-b",
+		Target:     "http://service-b:6001",
+		Split: &config.TrafficSplit{
+			Sticky:         sticky,
+			OverrideHeader: "X-Canary",
+			Groups: []config.UpstreamGroup{
+				{Name: "stable", Weight: 95},
+				{Name: "canary", Target: "http://service-b-v2:6001", Weight: 5, Override: "true"},
+			},
+		},
+	}
+}
+
+func fixedRoll(v float64) func() float64 {
+	return func() float64 { return v }
+}
+
+func TestChooseSplitWeights(t *testing.T) {
+	route := canaryRoute(config.StickyNone)
+	req := httptest.NewRequest(http.MethodGet, "/service-b/items", nil)
+
+	stable := ChooseSplit(route, req, fixedRoll(0.5))
+	if stable.Group != "stable" || stable.Target != "http://service-b:6001" {
+		t.Errorf("Expected stable group on route target, got %+v", stable)
+	}
+
+	canary := ChooseSplit(route, req, fixedRoll(0.97))
+	if canary.Group != "canary" || canary.Target != "http://service-b-v2:6001" {
+		t.Errorf("Expected canary group, got %+v", canary)
+	}
+
+	// Roughly 5% of an even spread lands on the canary
+	hits := 0
+	for i := 0; i < 1000; i++ {
+		if ChooseSplit(route, req, fixedRoll(float64(i)/1000)).Group == "canary" {
+			hits++
+		}
+	}
+	if hits != 50 {
+		t.Errorf("Expected 50 of 1000 requests on canary, got %d", hits)
+	}
+}
+
+func TestChooseSplitOverrideHeader(t *testing.T) {
+	route := canaryRoute(config.StickyClientID)
+	route.Split.Groups[1].Weight = 0 // Canary receives no regular traffic
+
+	req := httptest.NewRequest(http.MethodGet, "/service-b/items", nil)
+	req.Header.Set("X-Canary", "TRUE")
+	if got := ChooseSplit(route, req, fixedRoll(0)); got.Group != "canary" {
+		t.Errorf("Expected override header to force canary, got %+v", got)
+	}
+
+	req.Header.Set("X-Canary", "false")
+	if got := ChooseSplit(route, req, fixedRoll(0.99)); got.Group != "stable" {
+		t.Errorf("Expected unmatched override to fall back to weights, got %+v", got)
+	}
+}
+
+func TestChooseSplitStickyClientID(t *testing.T) {
+	route := canaryRoute(config.StickyClientID)
+	groups := make(map[string]int)
+
+	for i := 0; i < 200; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/service-b/items", nil)
+		req.Header.Set("X-Client-ID", fmt.Sprintf("client-%d", i))
+
+		first := ChooseSplit(route, req, fixedRoll(0))
+		again := ChooseSplit(route, req, fixedRoll(0.99))
+		if first.Group != again.Group {
+			t.Fatalf("Expected client-%d to stay on %s, got %s", i, first.Group, again.Group)
+		}
+		groups[first.Group]++
+	}
+	if groups["stable"] == 0 || groups["canary"] == 0 {
+		t.Errorf("Expected clients spread across both groups, got %v", groups)
+	}
+}
+
+func TestChooseSplitStickyCookie(t *testing.T) {
+	route := canaryRoute(config.StickyCookie)
+
+	req := httptest.NewRequest(http.MethodGet, "/service-b/items", nil)
+	first := ChooseSplit(route, req, fixedRoll(0.99))
+	if first.Group != "canary" || first.Cookie == nil {
+		t.Fatalf("Expected canary with a sticky cookie, got %+v", first)
+	}
+	if first.Cookie.Name != DefaultSplitCookie || first.Cookie.Path != "/service-b" {
+		t.Errorf("Unexpected cookie %+v", first.Cookie)
+	}
+
+	req.AddCookie(first.Cookie)
+	again := ChooseSplit(route, req, fixedRoll(0))
+	if again.Group != "canary" || again.Cookie != nil {
+		t.Errorf("Expected cookie to keep client on canary, got %+v", again)
+	}
+
+	// Draining the canary moves sticky clients back
+	route.Split.Groups[1].Weight = 0
+	if drained := ChooseSplit(route, req, fixedRoll(0)); drained.Group != "stable" {
+		t.Errorf("Expected drained group to release sticky clients, got %+v", drained)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/distributed-api-gateway/gateway/config"
+)
+
+// runValidate implements "gateway validate": strictly checks the gateway
+// config and routes without starting the server. Exit code is 1 when any
+// error is found; warnings alone exit 0.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("GATEWAY_CONFIG"), "gateway config file")
+	routesPath := fs.String("routes", "", "routes file (default: routes_file from the config)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *configPath == "" {
+		if _, err := os.Stat(config.DefaultConfigPath); err == nil {
+			*configPath = config.DefaultConfigPath
+		}
+	}
+
+	var diags []config.Diagnostic
+	routesFile := config.DefaultRoutesPath
+	inlineRoutes := false
+
+	if *configPath != "" {
+		cfg, configDiags := config.ValidateConfigFile(*configPath)
+		diags = append(diags, configDiags...)
+		if cfg != nil {
+			routesFile = cfg.RoutesFile
+			inlineRoutes = len(cfg.Routes) > 0
+		}
+	}
+	if *routesPath != "" {
+		routesFile, inlineRoutes = *routesPath, false
+	}
+	if !inlineRoutes {
+		diags = append(diags, config.ValidateRoutesFile(routesFile)...)
+	}
+
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d.String())
+	}
+
+	if config.HasErrors(diags) {
+		fmt.Fprintln(os.Stderr, "configuration invalid")
+		return 1
+	}
+	fmt.Println("configuration OK")
+	return 0
+}